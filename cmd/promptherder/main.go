@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"flag"
@@ -8,10 +9,13 @@ import (
 	"log/slog"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 	"syscall"
+	"text/tabwriter"
 
 	"github.com/shermanhuman/promptherder/internal/app"
+	"github.com/shermanhuman/promptherder/internal/files"
 )
 
 // Set via ldflags at build time.
@@ -37,13 +41,35 @@ func main() {
 	var (
 		includeCSV  string
 		dryRun      bool
+		diff        bool
+		trust       bool
+		strict      bool
+		force       bool
 		verbose     bool
 		showVersion bool
+		output      string
+		herdSubdir  string
+		signKey     string
+		keygen      bool
+		noUser      bool
+		repoFlag    string
+		configFlag  string
 	)
 	fs.StringVar(&includeCSV, "include", "", "Comma-separated glob patterns to include (default: all)")
 	fs.BoolVar(&dryRun, "dry-run", false, "Show actions without writing files")
+	fs.BoolVar(&diff, "diff", false, "Print unified diffs of what a sync would change (implies -dry-run)")
+	fs.BoolVar(&trust, "trust", false, "Confirm a herd source that is not in trusted_sources (pull)")
+	fs.BoolVar(&strict, "strict", false, "Fail when the content scanner reports suspicious content")
+	fs.BoolVar(&force, "force", false, "Overwrite and remove outputs even if they were edited by hand")
 	fs.BoolVar(&verbose, "v", false, "Verbose logging")
 	fs.BoolVar(&showVersion, "version", false, "Print version and exit")
+	fs.StringVar(&herdSubdir, "path", "", "Repository subdirectory that holds the herd (pull)")
+	fs.StringVar(&output, "o", "", "Output file for herd pack (default: <name>-<version>.tar.gz)")
+	fs.StringVar(&signKey, "key", "", "Private key file for herd sign")
+	fs.BoolVar(&keygen, "keygen", false, "Generate a signing key pair (herd sign)")
+	fs.BoolVar(&noUser, "no-user", false, "Skip the user-global rules layer (e.g. in CI)")
+	fs.StringVar(&repoFlag, "repo", "", "Repository root (default: found from the working directory)")
+	fs.StringVar(&configFlag, "config", "", "Settings file (default: <repo>/.promptherder/settings.json)")
 
 	fs.Usage = func() {
 		fmt.Fprint(os.Stderr, `promptherder — sync agent configuration across AI coding tools
@@ -52,16 +78,47 @@ Usage:
   promptherder [flags]              Sync all targets
   promptherder <target> [flags]     Sync a single target (copilot, antigravity)
   promptherder pull <git-url>       Install a herd from a Git repository
+  promptherder pull <name>          Install a herd listed in a configured registry
+  promptherder search [term]        Search configured registries for herds
+  promptherder stats                Estimate output sizes and list the largest contributors
+  promptherder check                Fail if a sync would change files (for CI)
+  promptherder herd init <name>     Scaffold a new herd in <repo>/<name>
+  promptherder herd validate [dir]  Check a herd for structural mistakes
+  promptherder herd pack [dir]      Write a reproducible .tar.gz of a herd
+  promptherder herd sign [dir] -key <file>
+                                    Sign a herd, writing herd.sig
+  promptherder herd sign -keygen [prefix]
+                                    Create <prefix>.key and <prefix>.pub
 
 Flags:
+  -repo        Repository root (default: walk up to the nearest .promptherder/ or .git)
+  -config      Settings file to use instead of .promptherder/settings.json
   -dry-run     Show actions without writing files
+  -diff        Print unified diffs of what a sync would change (implies -dry-run)
+  -force       Overwrite and remove outputs even if they were edited by hand
   -include     Comma-separated glob patterns to include (default: all)
+  -path        Repository subdirectory that holds the herd (pull)
+  -o           Output file for herd pack
+  -key         Private key file for herd sign
+  -keygen      Generate a signing key pair instead of signing
+  -no-user     Skip the user layer in ~/.config/promptherder/agent (for reproducible CI runs)
+  -strict      Fail (instead of warn) when scanning finds suspicious content
+  -trust       Confirm a herd source not listed in trusted_sources (pull)
   -v           Verbose logging (structured output to stderr)
   -version     Print version and exit
 
 Settings (.promptherder/settings.json):
   command_prefix           Prefix for command filenames, e.g. "v-" (default: "")
   command_prefix_enabled   Enable the prefix (default: false)
+  registries               Herd index URLs or paths for search and pull <name>
+  trusted_sources          Hosts, orgs or repos pull accepts without -trust
+  trusted_keys             Base64 ed25519 public keys accepted for herd.sig
+  require_signatures       Reject herds without a valid signature (default: false)
+  suspicious_phrases       Extra phrases the content scanner should flag
+  token_budgets            Always-on token budget per target, e.g. {"copilot": 6000}
+  budget_policy            "warn" (default) or "fail" when a target is over budget
+  drift_policy             "refuse" (default), "warn" or "backup" for outputs edited by hand
+  packages                 Monorepo package dirs as globs, e.g. ["packages/*"] (default: search the repo)
 
   Example:
     {
@@ -72,8 +129,11 @@ Settings (.promptherder/settings.json):
 Examples:
   promptherder                                Sync all targets
   promptherder pull https://github.com/user/herd
+  promptherder pull https://github.com/org/mono//herds/go-backend
   promptherder copilot -dry-run               Preview copilot sync
+  promptherder -diff                          Show what a sync would change
   promptherder antigravity                    Sync antigravity only
+  promptherder herd validate ./my-herd        Check a herd before publishing
 `)
 	}
 
@@ -90,7 +150,11 @@ Examples:
 	level := slog.LevelInfo
 	if verbose {
 		level = slog.LevelDebug
+	} else if diff {
+		// The diff lists the changes; the dry-run lines would repeat them.
+		level = slog.LevelWarn
 	}
+	dryRun = dryRun || diff
 
 	var logger *slog.Logger
 	if verbose {
@@ -101,18 +165,30 @@ Examples:
 		logger = slog.New(app.NewUIHandler(os.Stdout, level))
 	}
 
-	// Always use current working directory as repo root.
 	cwd, err := os.Getwd()
 	if err != nil {
 		logger.Error("failed to get working directory", "error", err)
 		os.Exit(1)
 	}
+	repo, settingsPath, err := resolveRepo(cwd, repoFlag, configFlag)
+	if err != nil {
+		logger.Error("failed to resolve repository", "error", err)
+		os.Exit(2)
+	}
+	logger.Debug("repository", "root", repo, "settings", settingsPath)
 
 	cfg := app.Config{
-		RepoPath: cwd,
-		Include:  parseIncludePatterns(includeCSV),
-		DryRun:   dryRun,
-		Logger:   logger,
+		RepoPath:     repo,
+		SettingsPath: settingsPath,
+		Include:      parseIncludePatterns(includeCSV),
+		DryRun:       dryRun,
+		Strict:       strict,
+		Force:        force,
+		Logger:       logger,
+		NoUserLayer:  noUser,
+	}
+	if diff {
+		cfg.Diff = os.Stdout
 	}
 
 	// Build the targets registry.
@@ -137,17 +213,43 @@ Examples:
 		}
 		if gitURL == "" {
 			logger.Error("missing URL argument")
-			fmt.Fprintf(os.Stderr, "Usage: promptherder pull <git-url>\n")
+			fmt.Fprintf(os.Stderr, "Usage: promptherder pull <git-url|name>\n")
 			os.Exit(2)
 		}
 		runErr = app.Pull(ctx, gitURL, app.PullConfig{
-			RepoPath: cwd,
-			DryRun:   dryRun,
-			Logger:   logger,
+			RepoPath:     repo,
+			SettingsPath: settingsPath,
+			Path:         herdSubdir,
+			Trust:        trust,
+			Strict:       strict,
+			DryRun:       dryRun,
+			Logger:       logger,
 		})
+	case "stats":
+		// Only warnings: the dry-run lines would drown the report.
+		quiet := cfg
+		if !verbose {
+			quiet.Logger = slog.New(app.NewUIHandler(os.Stdout, slog.LevelWarn))
+		}
+		runErr = runStats(ctx, allTargets, quiet)
+	case "check":
+		quiet := cfg
+		if !verbose {
+			quiet.Logger = slog.New(app.NewUIHandler(os.Stdout, slog.LevelWarn))
+		}
+		runErr = runCheck(ctx, allTargets, quiet)
+	case "search":
+		var term string
+		if len(allPositional) > 0 {
+			term = allPositional[0]
+		}
+		runErr = runSearch(ctx, term, cfg)
+	case "herd":
+		// Herd directories are relative to the repository root, like pull's -path.
+		runErr = runHerd(allPositional, herdFlags{output: output, key: signKey, keygen: keygen}, repo, cwd, logger)
 	default:
 		logger.Error("unknown subcommand", "subcommand", subcommand)
-		fmt.Fprintf(os.Stderr, "Usage: promptherder [copilot|antigravity|pull] [flags]\n")
+		fmt.Fprintf(os.Stderr, "Usage: promptherder [copilot|antigravity|pull|search|stats|check|herd] [flags]\n")
 		os.Exit(2)
 	}
 
@@ -168,6 +270,10 @@ func extractSubcommand(args []string) (string, []string) {
 		"copilot":     true,
 		"antigravity": true,
 		"pull":        true,
+		"search":      true,
+		"stats":       true,
+		"check":       true,
+		"herd":        true,
 	}
 	if len(args) > 0 && known[args[0]] {
 		return args[0], args[1:]
@@ -175,6 +281,202 @@ func extractSubcommand(args []string) (string, []string) {
 	return "", args
 }
 
+// runSearch prints the registry entries matching term as a table on stdout.
+func runSearch(ctx context.Context, term string, cfg app.Config) error {
+	entries, err := app.Search(ctx, term, cfg)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		cfg.Logger.Info("no herds found", "name", term)
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tVERSION\tDESCRIPTION\tURL")
+	for _, e := range entries {
+		version := e.Version
+		if version == "" {
+			version = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", e.Name, version, e.Description, e.URL)
+	}
+	return tw.Flush()
+}
+
+// statsTop is how many contributors `promptherder stats` lists per target.
+const statsTop = 10
+
+// runStats prints, per target, the estimated always-on tokens against the
+// budget and the largest contributors to its outputs.
+func runStats(ctx context.Context, targets []app.Target, cfg app.Config) error {
+	stats, err := app.Stats(ctx, targets, cfg)
+	if err != nil {
+		return err
+	}
+	for i, ts := range stats {
+		if i > 0 {
+			fmt.Println()
+		}
+		budget := "no budget"
+		if ts.Budget > 0 {
+			budget = fmt.Sprintf("budget %d", ts.Budget)
+			if ts.AlwaysOnTokens > ts.Budget {
+				budget += ", OVER"
+			}
+		}
+		fmt.Printf("%s: %d outputs, ~%d always-on tokens (%s)\n", ts.Target, len(ts.Outputs), ts.AlwaysOnTokens, budget)
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "  TOKENS\tALWAYS-ON\tCONTRIBUTOR\tOUTPUT")
+		for j, c := range ts.Contributors() {
+			if j == statsTop {
+				break
+			}
+			always := "-"
+			if c.AlwaysOn {
+				always = "yes"
+			}
+			output := c.Output
+			if output == c.Name {
+				output = ""
+			}
+			fmt.Fprintf(tw, "  %d\t%s\t%s\t%s\n", c.Tokens, always, c.Name, output)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runCheck lists the files a sync would create, update or delete, and
+// fails with app.ErrOutOfDate if there are any.
+func runCheck(ctx context.Context, targets []app.Target, cfg app.Config) error {
+	changes, err := app.Check(ctx, targets, cfg)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		fmt.Println("outputs are up to date")
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, c := range changes {
+		fmt.Fprintf(tw, "  %s\t%s\n", c.Kind, c.Path)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	return fmt.Errorf("%d file(s): %w", len(changes), app.ErrOutOfDate)
+}
+
+// herdFlags are the command-line flags used by the herd subcommands.
+type herdFlags struct {
+	output string // herd pack: archive path
+	key    string // herd sign: private key file
+	keygen bool   // herd sign: generate a key pair instead of signing
+}
+
+// runHerd dispatches the herd authoring subcommands: init, validate, pack, sign.
+// Herd directories are resolved against repo; key files given on the command
+// line, like -config, against cwd.
+func runHerd(args []string, flags herdFlags, repo, cwd string, logger *slog.Logger) error {
+	output := flags.output
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "Usage: promptherder herd <init|validate|pack|sign> [args]\n")
+		return fmt.Errorf("missing herd subcommand: %w", app.ErrValidation)
+	}
+
+	// Optional directory argument for validate/pack/sign; defaults to the repo.
+	herdPath := repo
+	if len(args) > 1 {
+		herdPath = resolvePath(repo, args[1])
+	}
+
+	switch args[0] {
+	case "init":
+		if len(args) < 2 {
+			fmt.Fprintf(os.Stderr, "Usage: promptherder herd init <name>\n")
+			return fmt.Errorf("missing herd name: %w", app.ErrValidation)
+		}
+		root, err := app.HerdInit(repo, args[1])
+		if err != nil {
+			return err
+		}
+		logger.Info("herd created", "path", root)
+		return nil
+
+	case "validate":
+		issues, err := app.ValidateHerd(herdPath)
+		if err != nil {
+			return err
+		}
+		errCount := 0
+		for _, issue := range issues {
+			if issue.Severity == app.SeverityError {
+				errCount++
+				logger.Error(issue.Message, "file", issue.Path)
+			} else {
+				logger.Warn(issue.Message, "file", issue.Path)
+			}
+		}
+		if errCount > 0 {
+			return fmt.Errorf("herd has %d error(s): %w", errCount, app.ErrValidation)
+		}
+		logger.Info("herd is valid", "path", herdPath, "warnings", len(issues))
+		return nil
+
+	case "pack":
+		if output == "" {
+			name, err := app.PackFileName(herdPath)
+			if err != nil {
+				return err
+			}
+			output = filepath.Join(repo, name)
+		} else {
+			output = resolvePath(cwd, output)
+		}
+		var buf bytes.Buffer
+		if err := app.PackHerd(herdPath, &buf); err != nil {
+			return err
+		}
+		if err := (files.AtomicWriter{Path: output, Perm: 0o644}).Write(buf.Bytes()); err != nil {
+			return fmt.Errorf("write %s: %w", output, err)
+		}
+		logger.Info("packed herd", "path", output)
+		return nil
+
+	case "sign":
+		if flags.keygen {
+			prefix := filepath.Join(cwd, "herd-signing")
+			if len(args) > 1 {
+				prefix = resolvePath(cwd, args[1])
+			}
+			privPath, pubPath, err := app.GenerateSigningKey(prefix)
+			if err != nil {
+				return err
+			}
+			logger.Info("key pair created — keep the .key file secret, add the .pub line to trusted_keys", "private", privPath, "public", pubPath)
+			return nil
+		}
+		if flags.key == "" {
+			fmt.Fprintf(os.Stderr, "Usage: promptherder herd sign [dir] -key <file>\n")
+			return fmt.Errorf("missing -key: %w", app.ErrValidation)
+		}
+		sigPath, err := app.SignHerd(herdPath, resolvePath(cwd, flags.key))
+		if err != nil {
+			return err
+		}
+		logger.Info("signed herd", "path", sigPath)
+		return nil
+
+	default:
+		fmt.Fprintf(os.Stderr, "Usage: promptherder herd <init|validate|pack|sign> [args]\n")
+		return fmt.Errorf("unknown herd subcommand %q: %w", args[0], app.ErrValidation)
+	}
+}
+
 func parseIncludePatterns(csv string) []string {
 	csv = strings.TrimSpace(csv)
 	if csv == "" {
@@ -192,6 +494,42 @@ func parseIncludePatterns(csv string) []string {
 	return patterns
 }
 
+// valueFlags are flags that consume the following argument as their value.
+var valueFlags = map[string]bool{
+	"include": true,
+	"path":    true,
+	"o":       true,
+	"key":     true,
+	"repo":    true,
+	"config":  true,
+}
+
+// resolveRepo returns the repository root every command works on — repoFlag
+// if given, else the one found from cwd — and the absolute settings path
+// for configFlag ("" for the repo's own settings.json).
+func resolveRepo(cwd, repoFlag, configFlag string) (repo, settingsPath string, err error) {
+	if repoFlag != "" {
+		repo = resolvePath(cwd, repoFlag)
+		if info, err := os.Stat(repo); err != nil || !info.IsDir() {
+			return "", "", fmt.Errorf("-repo %s: not a directory: %w", repoFlag, app.ErrValidation)
+		}
+	} else if repo, err = app.FindRepoRoot(cwd); err != nil {
+		return "", "", err
+	}
+	if configFlag != "" {
+		settingsPath = resolvePath(cwd, configFlag)
+	}
+	return filepath.Clean(repo), settingsPath, nil
+}
+
+// resolvePath returns path, or path joined to base when it is relative.
+func resolvePath(base, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(base, path)
+}
+
 // splitFlagsAndArgs separates flag arguments (starting with -) from positional
 // arguments. This allows flags to appear before or after positional args
 // (e.g. "pull https://url -dry-run" works the same as "pull -dry-run https://url").
@@ -205,7 +543,7 @@ func splitFlagsAndArgs(args []string) (flags, positional []string) {
 			if !strings.Contains(a, "=") && i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
 				// Check if this flag looks like it takes a value (e.g. -include).
 				name := strings.TrimLeft(a, "-")
-				if name == "include" { // known value flags
+				if valueFlags[name] {
 					i++
 					flags = append(flags, args[i])
 				}
@@ -1,6 +1,10 @@
 package main
 
 import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 )
@@ -20,6 +24,9 @@ func TestExtractSubcommand(t *testing.T) {
 		{"copilot subcommand", []string{"copilot", "-dry-run"}, "copilot", 1},
 		{"antigravity subcommand", []string{"antigravity", "-v"}, "antigravity", 1},
 		{"pull subcommand", []string{"pull", "https://example.com/my-herd"}, "pull", 1},
+		{"herd subcommand", []string{"herd", "validate", "./my-herd"}, "herd", 2},
+		{"stats subcommand", []string{"stats", "-v"}, "stats", 1},
+		{"check subcommand", []string{"check", "-no-user"}, "check", 1},
 		{"unknown subcommand", []string{"unknown", "-v"}, "", 2},
 		{"empty args", []string{}, "", 0},
 	}
@@ -98,6 +105,8 @@ func TestSplitFlagsAndArgs(t *testing.T) {
 		{"flags after url", []string{"https://url", "-dry-run"}, []string{"-dry-run"}, []string{"https://url"}},
 		{"mixed", []string{"-v", "https://url", "-dry-run"}, []string{"-v", "-dry-run"}, []string{"https://url"}},
 		{"include with value", []string{"-include", "*.md", "https://url"}, []string{"-include", "*.md"}, []string{"https://url"}},
+		{"output with value", []string{"pack", "-o", "out.tar.gz", "./herd"}, []string{"-o", "out.tar.gz"}, []string{"pack", "./herd"}},
+		{"repo and config", []string{"copilot", "-repo", "..", "-config", "ci.json"}, []string{"-repo", "..", "-config", "ci.json"}, []string{"copilot"}},
 		{"no args", []string{}, nil, nil},
 		{"only flags", []string{"-v", "-dry-run"}, []string{"-v", "-dry-run"}, nil},
 		{"only positional", []string{"https://url"}, nil, []string{"https://url"}},
@@ -116,3 +125,61 @@ func TestSplitFlagsAndArgs(t *testing.T) {
 		})
 	}
 }
+
+// --- resolveRepo tests ---
+
+func TestResolveRepo(t *testing.T) {
+	t.Parallel()
+	repo := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(repo, ".promptherder"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(repo, "docs", "guides")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, cwd, repoFlag, configFlag string
+		wantRepo, wantSettings          string
+		wantErr                         bool
+	}{
+		{name: "found from a subdirectory", cwd: sub, wantRepo: repo},
+		{name: "relative -repo", cwd: sub, repoFlag: "..", wantRepo: filepath.Join(repo, "docs")},
+		{name: "absolute -repo", cwd: sub, repoFlag: repo, wantRepo: repo},
+		{name: "-config is relative to cwd", cwd: sub, configFlag: "ci.json", wantRepo: repo, wantSettings: filepath.Join(sub, "ci.json")},
+		{name: "-repo must exist", cwd: sub, repoFlag: "nope", wantErr: true},
+	}
+	for _, tt := range tests {
+		gotRepo, gotSettings, err := resolveRepo(tt.cwd, tt.repoFlag, tt.configFlag)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if gotRepo != tt.wantRepo || gotSettings != tt.wantSettings {
+			t.Errorf("%s: got (%s, %s), want (%s, %s)", tt.name, gotRepo, gotSettings, tt.wantRepo, tt.wantSettings)
+		}
+	}
+}
+
+// --- runHerd tests ---
+
+func TestRunHerd_ResolvesAgainstRepo(t *testing.T) {
+	t.Parallel()
+	repo, cwd := t.TempDir(), t.TempDir()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	for _, args := range [][]string{{"init", "my-herd"}, {"validate", "my-herd"}, {"pack", "my-herd"}} {
+		if err := runHerd(args, herdFlags{}, repo, cwd, logger); err != nil {
+			t.Fatalf("herd %s: %v", args[0], err)
+		}
+	}
+	for _, rel := range []string{"my-herd/herd.json", "my-herd-0.1.0.tar.gz"} {
+		if _, err := os.Stat(filepath.Join(repo, rel)); err != nil {
+			t.Errorf("%s should be in the repo: %v", rel, err)
+		}
+	}
+	if entries, _ := os.ReadDir(cwd); len(entries) != 0 {
+		t.Errorf("nothing should be written to cwd, got %v", entries)
+	}
+}
@@ -3,3 +3,5 @@ module github.com/shermanhuman/promptherder
 go 1.25
 
 require github.com/bmatcuk/doublestar/v4 v4.6.1
+
+require gopkg.in/yaml.v3 v3.0.1
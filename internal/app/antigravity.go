@@ -3,21 +3,28 @@ package app
 import (
 	"context"
 	"fmt"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 )
 
 const (
+	antigravityName   = "antigravity"
 	antigravitySource = ".promptherder/agent"
 	antigravityTarget = ".agent"
 )
 
 // AntigravityTarget implements the Target interface for Google Antigravity.
-// It copies files from .promptherder/agent/ to .agent/, preserving directory structure.
+// It copies files from .promptherder/agent/ to .agent/, preserving directory
+// structure; rule frontmatter is translated to Antigravity triggers.
 type AntigravityTarget struct{}
 
-func (t AntigravityTarget) Name() string { return "antigravity" }
+func (t AntigravityTarget) Name() string { return antigravityName }
+
+// AlwaysOnBudget is the default token budget for always_on rules combined.
+func (t AntigravityTarget) AlwaysOnBudget() int { return defaultBudget }
 
 func (t AntigravityTarget) Install(ctx context.Context, cfg TargetConfig) ([]string, error) {
 	srcRoot := filepath.Join(cfg.RepoPath, filepath.FromSlash(antigravitySource))
@@ -30,8 +37,19 @@ func (t AntigravityTarget) Install(ctx context.Context, cfg TargetConfig) ([]str
 	// Load manifest to check for generated files we must not overwrite.
 	m := readManifest(cfg.RepoPath, cfg.Logger)
 
-	var installed []string
-	err := filepath.Walk(srcRoot, func(path string, info os.FileInfo, err error) error {
+	r, err := newSourceRenderer(cfg.RepoPath, cfg.Settings, antigravityName)
+	if err != nil {
+		return nil, err
+	}
+	if r.outputs, err = antigravityOutputs(cfg.RepoPath, cfg.Settings); err != nil {
+		return nil, err
+	}
+	r.logger = cfg.Logger
+	r.user = cfg.user
+
+	// Render everything first; writeItems then writes (or dry-runs) the plan.
+	var items []planItem
+	err = filepath.Walk(srcRoot, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -39,6 +57,10 @@ func (t AntigravityTarget) Install(ctx context.Context, cfg TargetConfig) ([]str
 			return err
 		}
 		if info.IsDir() {
+			// Partials are only used through @include.
+			if path == filepath.Join(srcRoot, partialsDir) {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
@@ -49,32 +71,21 @@ func (t AntigravityTarget) Install(ctx context.Context, cfg TargetConfig) ([]str
 		}
 		relSlash := filepath.ToSlash(rel)
 		baseName := filepath.Base(rel)
-		sourceSlash := relSlash // preserve original for logging
-
-		// --- Skill variant logic ---
-		// If this file is in a skills/*/ directory, apply variant selection.
-		if isInSkillDir(relSlash) {
-			if targetName, isVariant := SkillVariantFiles[baseName]; isVariant {
-				if targetName != "antigravity" {
-					// Skip other targets' variant files (e.g. COPILOT.md).
-					return nil
-				}
-				// This is our variant — install it as SKILL.md.
-				rel = filepath.Join(filepath.Dir(rel), "SKILL.md")
-				relSlash = filepath.ToSlash(rel)
-			} else if baseName == "SKILL.md" {
-				// Check if our variant file exists; if so, skip the generic.
-				variantPath := filepath.Join(filepath.Dir(path), "ANTIGRAVITY.md")
-				if _, err := os.Stat(variantPath); err == nil {
-					cfg.Logger.Debug("skipping generic skill (variant exists)", "file", relSlash)
-					return nil
-				}
-			}
+		label := antigravitySource + "/" + relSlash
+		if r.leftover(label) {
+			return nil
 		}
 
+		outputRel, ok := antigravityOutput(srcRoot, relSlash, cfg.Settings)
+		if !ok {
+			cfg.Logger.Debug("skipping source (another variant is installed)", "file", relSlash)
+			return nil
+		}
+		targetPath := filepath.Join(cfg.RepoPath, antigravityTarget, filepath.FromSlash(outputRel))
+		targetRel := antigravityTarget + "/" + outputRel
+
 		// Skip agent-generated files (e.g. stack.md, structure.md).
 		if m.isGenerated(baseName) {
-			targetPath := filepath.Join(cfg.RepoPath, antigravityTarget, rel)
 			if _, err := os.Stat(targetPath); err == nil {
 				cfg.Logger.Debug("skipping generated file", "file", relSlash)
 				return nil
@@ -87,51 +98,151 @@ func (t AntigravityTarget) Install(ctx context.Context, cfg TargetConfig) ([]str
 		if err != nil {
 			return fmt.Errorf("read %s: %w", path, err)
 		}
-
-		// Apply command prefix to workflow files (not skills).
-		outputRel := rel
-		if isInWorkflowDir(relSlash) {
-			dir := filepath.Dir(rel)
-			base := filepath.Base(rel)
-			outputRel = filepath.Join(dir, cfg.Settings.PrefixCommand(base))
+		if strings.HasSuffix(baseName, ".md") {
+			if data, err = r.render(data, label, relSlash); err != nil {
+				return err
+			}
+			data = r.rewriteRefs(data, label, targetRel)
 		}
 
-		targetPath := filepath.Join(cfg.RepoPath, antigravityTarget, outputRel)
-		targetRel := filepath.ToSlash(filepath.Join(antigravityTarget, filepath.ToSlash(outputRel)))
-
-		if cfg.DryRun {
-			cfg.Logger.Info("dry-run", "target", targetRel, "source", sourceSlash)
-		} else {
-			if err := writeFile(targetPath, data); err != nil {
+		// Translate rule triggers into Antigravity frontmatter.
+		alwaysOn := false
+		if isInRuleDir(relSlash) {
+			var scope ruleScope
+			if data, scope, err = renderAntigravityRule(data, label, false, "", cfg); err != nil {
 				return err
 			}
-			cfg.Logger.Info("synced", "target", targetRel, "source", sourceSlash)
+			alwaysOn = scope.Trigger == triggerAlways
 		}
 
-		installed = append(installed, targetRel)
+		items = append(items, planItem{Target: targetPath, Content: data, Sources: []string{relSlash}, AlwaysOn: alwaysOn, Local: r.personal(label)})
 		return nil
 	})
 	if err != nil {
-		return installed, err
+		return nil, err
 	}
 
-	// Copy hard-rules.md if it exists.
+	// Install hard-rules.md, always on, if it exists.
 	hardRulesPath := filepath.Join(cfg.RepoPath, filepath.FromSlash(hardRulesFile))
 	if data, err := os.ReadFile(hardRulesPath); err == nil {
-		targetPath := filepath.Join(cfg.RepoPath, antigravityTarget, "rules", "hard-rules.md")
 		targetRel := filepath.ToSlash(filepath.Join(antigravityTarget, "rules", "hard-rules.md"))
-		if cfg.DryRun {
-			cfg.Logger.Info("dry-run", "target", targetRel, "source", hardRulesFile)
-		} else {
-			if err := writeFile(targetPath, data); err != nil {
-				return installed, err
+		data, err := r.render(data, hardRulesFile, "")
+		if err != nil {
+			return nil, err
+		}
+		data = r.rewriteRefs(data, hardRulesFile, targetRel)
+		if data, _, err = renderAntigravityRule(data, hardRulesFile, true, "", cfg); err != nil {
+			return nil, err
+		}
+		targetPath := filepath.Join(cfg.RepoPath, filepath.FromSlash(targetRel))
+		items = append(items, planItem{Target: targetPath, Content: data, Sources: []string{hardRulesFile}, AlwaysOn: true})
+	}
+
+	return writeItems(ctx, cfg, items, nil)
+}
+
+// InstallPackage syncs the rules of a nested package to .agent/rules/,
+// named after the package and scoped to its subtree (always-on rules and
+// hard-rules.md apply to pkg/**).
+func (t AntigravityTarget) InstallPackage(ctx context.Context, cfg TargetConfig, pkg string) ([]string, error) {
+	r, err := newSourceRenderer(cfg.RepoPath, cfg.Settings, antigravityName)
+	if err != nil {
+		return nil, err
+	}
+	r.root = pkg + "/" + antigravitySource
+	if r.outputs, err = antigravityOutputs(cfg.RepoPath, cfg.Settings); err != nil {
+		return nil, err
+	}
+	r.logger = cfg.Logger
+	warnPackageCommands(cfg, antigravityName, pkg)
+
+	// Map every rule first, so rules can link to each other.
+	var labels []string
+	rulesDir := r.root + "/rules"
+	err = filepath.WalkDir(filepath.Join(cfg.RepoPath, filepath.FromSlash(rulesDir)), func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return filepath.SkipDir
 			}
-			cfg.Logger.Info("synced", "target", targetRel, "source", hardRulesFile)
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".md") {
+			return nil
+		}
+		rel, err := filepath.Rel(cfg.RepoPath, p)
+		if err != nil {
+			return fmt.Errorf("rel path: %w", err)
 		}
-		installed = append(installed, targetRel)
+		labels = append(labels, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if fileExists(filepath.Join(cfg.RepoPath, filepath.FromSlash(pkg+"/"+hardRulesFile))) {
+		labels = append(labels, pkg+"/"+hardRulesFile)
+	}
+	for _, label := range labels {
+		r.outputs[label] = antigravityPackageOutput(pkg, label)
 	}
 
-	return installed, err
+	var items []planItem
+	for _, label := range labels {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(filepath.Join(cfg.RepoPath, filepath.FromSlash(label)))
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", label, err)
+		}
+		targetRel := r.outputs[label]
+		if data, err = r.render(data, label, ""); err != nil {
+			return nil, err
+		}
+		data = r.rewriteRefs(data, label, targetRel)
+		if data, _, err = renderAntigravityRule(data, label, label == pkg+"/"+hardRulesFile, pkg, cfg); err != nil {
+			return nil, err
+		}
+		targetPath := filepath.Join(cfg.RepoPath, filepath.FromSlash(targetRel))
+		items = append(items, planItem{Target: targetPath, Content: data, Sources: []string{label}})
+	}
+	return writeItems(ctx, cfg, items, nil)
+}
+
+// antigravityPackageOutput returns where a rule (or hard-rules.md) of a
+// nested package is installed: .agent/rules/, with the package slug
+// prefixed to the file name.
+func antigravityPackageOutput(pkg, label string) string {
+	rel, ok := strings.CutPrefix(label, pkg+"/"+antigravitySource+"/rules/")
+	if !ok {
+		rel = "hard-rules.md"
+	}
+	dir, base := path.Split(rel)
+	return antigravityTarget + "/rules/" + dir + packageSlug(pkg) + "-" + base
+}
+
+// antigravityOutput returns where the source at relSlash (relative to
+// .promptherder/agent) is installed, relative to .agent. ok is false when
+// another file is installed in its place: a skill variant for some other
+// target, or SKILL.md when ANTIGRAVITY.md exists. Workflows get the command
+// prefix.
+func antigravityOutput(srcRoot, relSlash string, settings Settings) (out string, ok bool) {
+	dir, base := path.Split(relSlash)
+	if isInSkillDir(relSlash) {
+		if targetName, isVariant := SkillVariantFiles[base]; isVariant {
+			if targetName != antigravityName {
+				return "", false
+			}
+			return dir + "SKILL.md", true
+		}
+		if base == "SKILL.md" && fileExists(filepath.Join(srcRoot, filepath.FromSlash(dir), "ANTIGRAVITY.md")) {
+			return "", false
+		}
+	}
+	if isInWorkflowDir(relSlash) {
+		return dir + settings.PrefixCommand(base), true
+	}
+	return relSlash, true
 }
 
 // isInSkillDir returns true if the slash-separated relative path is inside a
@@ -140,6 +251,38 @@ func isInSkillDir(relSlash string) bool {
 	return strings.HasPrefix(relSlash, "skills/") && strings.Count(relSlash, "/") >= 2
 }
 
+// isInRuleDir returns true if the slash-separated relative path is inside
+// the rules/ directory (e.g. "rules/web.md").
+func isInRuleDir(relSlash string) bool {
+	return strings.HasPrefix(relSlash, "rules/")
+}
+
+// renderAntigravityRule translates a rule's frontmatter into Antigravity's
+// trigger form; keys Antigravity doesn't read are dropped. label names the
+// source in errors. Hard rules always apply, whatever their frontmatter says,
+// and rules of a nested package (pkg, "" at the root) are scoped to it.
+// The rule's resolved scope is returned with the rendered file.
+func renderAntigravityRule(data []byte, label string, hardRule bool, pkg string, cfg TargetConfig) ([]byte, ruleScope, error) {
+	meta, body, err := parseFrontmatter(data)
+	if err != nil {
+		return nil, ruleScope{}, fmt.Errorf("%s: frontmatter %v: %w", label, err, ErrValidation)
+	}
+	scope, err := parseRuleScope(meta)
+	if err != nil {
+		return nil, ruleScope{}, fmt.Errorf("%s: frontmatter %v: %w", label, err, ErrValidation)
+	}
+	if hardRule {
+		scope.Trigger, scope.ApplyTo = triggerAlways, nil
+	}
+	if pkg != "" {
+		scope = scopeToPackage(scope, pkg)
+	}
+	if len(scope.ExcludeFrom) > 0 {
+		cfg.Logger.Warn("antigravity has no exclude globs — excludeFrom ignored", "rule", label, "excludeFrom", scope.ExcludeFrom)
+	}
+	return append([]byte(antigravityRuleHeader(scope)), body...), scope, nil
+}
+
 // isInWorkflowDir returns true if the slash-separated relative path is inside
 // the workflows/ directory (e.g. "workflows/plan.md").
 func isInWorkflowDir(relSlash string) bool {
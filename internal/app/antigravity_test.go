@@ -495,3 +495,43 @@ func TestAntigravityTarget_NoHardRules(t *testing.T) {
 		t.Error("hard-rules.md should not exist when source is missing")
 	}
 }
+
+func TestAntigravityTarget_GlobRule(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, ".promptherder/agent/rules/web.md",
+		"---\napplyTo: [\"**/*.ts\", \"**/*.tsx\"]\nexcludeFrom: \"**/*.gen.ts\"\ndescription: Web rules\n---\n# Web\n")
+	createTestFile(t, dir, ".promptherder/agent/rules/general.md", "---\ntrigger: always_on\n---\n# General\n")
+
+	if _, err := (AntigravityTarget{}).Install(context.Background(), TargetConfig{RepoPath: dir, Logger: testLogger(t)}); err != nil {
+		t.Fatal(err)
+	}
+
+	web, err := os.ReadFile(filepath.Join(dir, ".agent", "rules", "web.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "---\ntrigger: glob\nglobs: **/*.ts,**/*.tsx\ndescription: \"Web rules\"\n---\n# Web\n"
+	if string(web) != want {
+		t.Errorf("web.md =\n%s\nwant\n%s", web, want)
+	}
+
+	general, err := os.ReadFile(filepath.Join(dir, ".agent", "rules", "general.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(general) != "---\ntrigger: always_on\n---\n# General\n" {
+		t.Errorf("rules without applyTo should be copied verbatim, got %q", general)
+	}
+}
+
+func TestAntigravityTarget_InvalidGlob(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, ".promptherder/agent/rules/bad.md", "---\napplyTo: \"src/[a-\"\n---\n# Bad\n")
+
+	_, err := (AntigravityTarget{}).Install(context.Background(), TargetConfig{RepoPath: dir, Logger: testLogger(t)})
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("expected ErrValidation for an invalid glob, got %v", err)
+	}
+}
@@ -0,0 +1,220 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"unicode/utf8"
+)
+
+// Tokenizer estimates how many model tokens a text takes. promptherder only
+// needs estimates for budgets and stats, so the default is a heuristic;
+// set Config.Tokenizer to plug in a real one.
+type Tokenizer interface {
+	Tokens(text []byte) int
+}
+
+// charTokenizer counts one token per four characters, a common rule of
+// thumb for English prose and code.
+type charTokenizer struct{}
+
+func (charTokenizer) Tokens(text []byte) int {
+	return (utf8.RuneCount(text) + 3) / 4
+}
+
+// defaultBudget is the always-on token budget of the built-in targets when
+// settings don't set one. It is a soft limit: past it, instructions start
+// crowding out the code the agent is working on.
+const defaultBudget = 8000
+
+// Budget policies for settings budget_policy.
+const (
+	budgetWarn = "warn"
+	budgetFail = "fail"
+)
+
+// ErrOverBudget is returned (wrapped with ErrValidation) when budget_policy
+// is "fail" and a target's always-on outputs exceed its token budget.
+var ErrOverBudget = fmt.Errorf("over token budget: %w", ErrValidation)
+
+// OutputStat is the estimated size of one output file.
+type OutputStat struct {
+	Path     string     // repo-relative
+	Tokens   int        // estimated tokens
+	AlwaysOn bool       // loaded into every request, so it counts against the budget
+	Parts    []PartStat // sources concatenated into the output, in order
+}
+
+// PartStat is one source's share of a concatenated output.
+type PartStat struct {
+	Source string
+	Tokens int
+}
+
+// TargetStats summarizes the outputs of one target.
+type TargetStats struct {
+	Target         string
+	Outputs        []OutputStat // largest first
+	AlwaysOnTokens int
+	Budget         int // 0 when the target has no budget
+}
+
+// outputStats collects the outputs a target writes, or would write in a
+// dry run. A nil *outputStats records nothing.
+type outputStats struct {
+	tokenizer Tokenizer
+	outputs   []OutputStat
+}
+
+// outputPart is a source's content within a concatenated output.
+type outputPart struct {
+	Source  string
+	Content []byte
+}
+
+func (s *outputStats) record(rel string, content []byte, alwaysOn bool, parts []outputPart) {
+	if s == nil {
+		return
+	}
+	stat := OutputStat{Path: rel, Tokens: s.tokenizer.Tokens(content), AlwaysOn: alwaysOn}
+	for _, p := range parts {
+		stat.Parts = append(stat.Parts, PartStat{Source: p.Source, Tokens: s.tokenizer.Tokens(p.Content)})
+	}
+	s.outputs = append(s.outputs, stat)
+}
+
+// fill measures installed files the target didn't record itself, such as
+// those of targets outside this package, from disk.
+func (s *outputStats) fill(repoPath string, installed []string) {
+	for _, rel := range installed {
+		if slices.ContainsFunc(s.outputs, func(o OutputStat) bool { return o.Path == rel }) {
+			continue
+		}
+		if data, err := os.ReadFile(filepath.Join(repoPath, filepath.FromSlash(rel))); err == nil {
+			s.record(rel, data, false, nil)
+		}
+	}
+}
+
+// summarize sorts the outputs largest first and totals the always-on ones.
+func (s *outputStats) summarize(target string, budget int) TargetStats {
+	ts := TargetStats{Target: target, Outputs: s.outputs, Budget: budget}
+	slices.SortStableFunc(ts.Outputs, func(a, b OutputStat) int {
+		return cmp.Or(cmp.Compare(b.Tokens, a.Tokens), cmp.Compare(a.Path, b.Path))
+	})
+	for _, o := range ts.Outputs {
+		if o.AlwaysOn {
+			ts.AlwaysOnTokens += o.Tokens
+		}
+	}
+	return ts
+}
+
+// targetBudget returns the always-on token budget of t: settings
+// token_budgets first (0 turns the check off), then the target's own
+// AlwaysOnBudget, if it has one.
+func targetBudget(t Target, settings Settings) int {
+	if b, ok := settings.TokenBudgets[t.Name()]; ok {
+		return b
+	}
+	if b, ok := t.(interface{ AlwaysOnBudget() int }); ok {
+		return b.AlwaysOnBudget()
+	}
+	return 0
+}
+
+// checkBudget reports a target whose always-on outputs exceed its budget,
+// naming the largest contributors. It returns ErrOverBudget under the fail
+// policy and nil (after a warning) otherwise.
+func checkBudget(ts TargetStats, policy string, logger *slog.Logger) error {
+	if ts.Budget <= 0 || ts.AlwaysOnTokens <= ts.Budget {
+		return nil
+	}
+	var largest []string
+	for _, c := range ts.Contributors() {
+		if !c.AlwaysOn || len(largest) == 3 {
+			continue
+		}
+		largest = append(largest, fmt.Sprintf("%s (%d)", c.Name, c.Tokens))
+	}
+	if policy == budgetFail {
+		return fmt.Errorf("target %s: always-on instructions are ~%d tokens, budget %d: %w", ts.Target, ts.AlwaysOnTokens, ts.Budget, ErrOverBudget)
+	}
+	logger.Warn("always-on instructions over token budget", "target", ts.Target, "tokens", ts.AlwaysOnTokens, "budget", ts.Budget, "largest", largest)
+	return nil
+}
+
+// Contributor is a source or output file and its estimated size.
+type Contributor struct {
+	Name     string // source name for parts of a concatenated output, else the output path
+	Output   string // output the contributor ends up in
+	Tokens   int
+	AlwaysOn bool
+}
+
+// Contributors lists what makes up a target's outputs, largest first:
+// each source of a concatenated output, and every other output whole.
+func (ts TargetStats) Contributors() []Contributor {
+	var cs []Contributor
+	for _, o := range ts.Outputs {
+		if len(o.Parts) == 0 {
+			cs = append(cs, Contributor{Name: o.Path, Output: o.Path, Tokens: o.Tokens, AlwaysOn: o.AlwaysOn})
+			continue
+		}
+		for _, p := range o.Parts {
+			cs = append(cs, Contributor{Name: p.Source, Output: o.Path, Tokens: p.Tokens, AlwaysOn: o.AlwaysOn})
+		}
+	}
+	slices.SortStableFunc(cs, func(a, b Contributor) int { return cmp.Compare(b.Tokens, a.Tokens) })
+	return cs
+}
+
+// Stats dry-runs every target and returns the estimated size of its
+// outputs, for `promptherder stats`. Nothing is written.
+func Stats(ctx context.Context, targets []Target, cfg Config) ([]TargetStats, error) {
+	cfg.DryRun = true
+	_, prev, tcfg, err := setupRunner(&cfg)
+	if err != nil {
+		return nil, err
+	}
+	tcfg.guard = nil // sizes only; hand edits are reported by a sync
+	tcfg.user = userSources(prev, prev.Targets[userTarget], false)
+	var all []TargetStats
+	for _, t := range targets {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		_, ts, err := installMeasured(ctx, t, tcfg, cfg.Tokenizer)
+		if err != nil {
+			return nil, fmt.Errorf("target %s: %w", t.Name(), err)
+		}
+		all = append(all, ts)
+	}
+	return all, nil
+}
+
+// installMeasured runs t.Install, then installs each nested package,
+// while recording the size of the outputs.
+func installMeasured(ctx context.Context, t Target, tcfg TargetConfig, tokenizer Tokenizer) ([]string, TargetStats, error) {
+	if tokenizer == nil {
+		tokenizer = charTokenizer{}
+	}
+	tcfg.stats = &outputStats{tokenizer: tokenizer}
+	installed, err := t.Install(ctx, tcfg)
+	if err != nil {
+		return installed, TargetStats{}, err
+	}
+	for _, pkg := range tcfg.packages {
+		pkgInstalled, err := installPackage(ctx, t, tcfg, pkg)
+		installed = append(installed, pkgInstalled...)
+		if err != nil {
+			return installed, TargetStats{}, fmt.Errorf("package %s: %w", pkg, err)
+		}
+	}
+	tcfg.stats.fill(tcfg.RepoPath, installed)
+	return installed, tcfg.stats.summarize(t.Name(), targetBudget(t, tcfg.Settings)), nil
+}
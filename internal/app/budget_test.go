@@ -0,0 +1,201 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCharTokenizer(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"abc", 1},
+		{"abcd", 1},
+		{"abcde", 2},
+		{"héllo wörld!", 3}, // runes, not bytes
+	}
+	for _, tt := range tests {
+		if got := (charTokenizer{}).Tokens([]byte(tt.text)); got != tt.want {
+			t.Errorf("Tokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestTargetBudget(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		target   Target
+		settings Settings
+		want     int
+	}{
+		{"built-in default", CopilotTarget{}, Settings{}, defaultBudget},
+		{"settings override", CopilotTarget{}, Settings{TokenBudgets: map[string]int{"copilot": 500}}, 500},
+		{"zero turns it off", AntigravityTarget{}, Settings{TokenBudgets: map[string]int{"antigravity": 0}}, 0},
+		{"target without a budget", targetFunc{name: "custom"}, Settings{}, 0},
+		{"settings budget for custom target", targetFunc{name: "custom"}, Settings{TokenBudgets: map[string]int{"custom": 10}}, 10},
+	}
+	for _, tt := range tests {
+		if got := targetBudget(tt.target, tt.settings); got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckBudget(t *testing.T) {
+	t.Parallel()
+	ts := TargetStats{
+		Target:         "copilot",
+		AlwaysOnTokens: 120,
+		Budget:         100,
+		Outputs: []OutputStat{
+			{Path: ".github/copilot-instructions.md", Tokens: 120, AlwaysOn: true, Parts: []PartStat{{"big", 90}, {"small", 30}}},
+			{Path: ".github/prompts/plan.prompt.md", Tokens: 500},
+		},
+	}
+
+	var logs bytes.Buffer
+	if err := checkBudget(ts, budgetWarn, slog.New(slog.NewTextHandler(&logs, nil))); err != nil {
+		t.Fatalf("warn policy should not fail: %v", err)
+	}
+	if !strings.Contains(logs.String(), "over token budget") || !strings.Contains(logs.String(), "big (90)") {
+		t.Errorf("warning should name the largest always-on contributors, got %q", logs.String())
+	}
+	if strings.Contains(logs.String(), "plan.prompt.md") {
+		t.Errorf("prompts are not always on and should not be blamed: %q", logs.String())
+	}
+
+	err := checkBudget(ts, budgetFail, testLogger(t))
+	if !errors.Is(err, ErrOverBudget) || !errors.Is(err, ErrValidation) {
+		t.Errorf("fail policy: err = %v, want ErrOverBudget", err)
+	}
+
+	ts.Budget = 0
+	if err := checkBudget(ts, budgetFail, testLogger(t)); err != nil {
+		t.Errorf("no budget: err = %v", err)
+	}
+}
+
+func TestStats_Contributors(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, ".promptherder/hard-rules.md", "# Hard\n")
+	createTestFile(t, dir, ".promptherder/agent/rules/big.md", "# Big\n"+strings.Repeat("word ", 200))
+	createTestFile(t, dir, ".promptherder/agent/rules/go.md", "---\napplyTo: \"**/*.go\"\n---\n# Go\n")
+	createTestFile(t, dir, ".promptherder/agent/workflows/plan.md", "---\ndescription: Plan\n---\n# Plan\n")
+
+	stats, err := Stats(context.Background(), []Target{CopilotTarget{}, AntigravityTarget{}}, Config{RepoPath: dir, Logger: testLogger(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 2 {
+		t.Fatalf("got %d targets, want 2", len(stats))
+	}
+	if isDirectory(filepath.Join(dir, ".github")) || isDirectory(filepath.Join(dir, ".agent")) {
+		t.Error("stats must not write outputs")
+	}
+
+	copilot := stats[0]
+	if copilot.Budget != defaultBudget {
+		t.Errorf("copilot budget = %d", copilot.Budget)
+	}
+	top := copilot.Contributors()[0]
+	if top.Name != "big" || top.Output != copilotTarget || !top.AlwaysOn {
+		t.Errorf("largest copilot contributor = %+v, want rule big in copilot-instructions.md", top)
+	}
+	if copilot.AlwaysOnTokens < top.Tokens {
+		t.Errorf("always-on total %d is below its largest part %d", copilot.AlwaysOnTokens, top.Tokens)
+	}
+
+	antigravity := stats[1]
+	var alwaysOn []string
+	for _, o := range antigravity.Outputs {
+		if o.AlwaysOn {
+			alwaysOn = append(alwaysOn, o.Path)
+		}
+	}
+	if strings.Join(alwaysOn, ",") != ".agent/rules/big.md,.agent/rules/hard-rules.md" {
+		t.Errorf("antigravity always-on outputs = %v", alwaysOn)
+	}
+}
+
+func TestStats_UserLayer(t *testing.T) {
+	t.Parallel()
+	repo, user := userLayerRepo(t)
+	if err := RunAll(context.Background(), []Target{CopilotTarget{}}, Config{RepoPath: repo, UserDir: user, Logger: testLogger(t)}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Stats measures the outputs a sync writes: the personal rule gets its
+	// own instructions file rather than a part of copilot-instructions.md.
+	stats, err := Stats(context.Background(), []Target{CopilotTarget{}}, Config{RepoPath: repo, Logger: testLogger(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range stats[0].Contributors() {
+		if c.Name == "personal" && c.Output == copilotTarget {
+			t.Errorf("user rule counted in %s: %+v", copilotTarget, c)
+		}
+	}
+	if !slices.ContainsFunc(stats[0].Outputs, func(o OutputStat) bool {
+		return o.Path == ".github/instructions/personal.instructions.md"
+	}) {
+		t.Errorf("outputs = %+v, want the personal instructions file", stats[0].Outputs)
+	}
+}
+
+func TestRunAll_BudgetMeasuresKeptFile(t *testing.T) {
+	t.Parallel()
+	dir, cfg := driftRepo(t, "")
+	createTestFile(t, dir, ".promptherder/agent/rules/general.md", "# General\n"+strings.Repeat("word ", 50))
+	createTestFile(t, dir, filepath.Join(manifestDir, settingsFile), `{"token_budgets": {"copilot": 40}, "budget_policy": "fail"}`)
+
+	// The hand-edited file is kept, and it is what the agent reads.
+	cfg.Tokenizer = wordTokenizer{}
+	err := RunAll(context.Background(), []Target{CopilotTarget{}}, cfg)
+	if !errors.Is(err, ErrDrift) {
+		t.Fatalf("expected ErrDrift, got %v", err)
+	}
+	if errors.Is(err, ErrOverBudget) {
+		t.Errorf("the budget should measure the kept file, got %v", err)
+	}
+}
+
+// wordTokenizer counts whitespace-separated words, to check that
+// Config.Tokenizer is used.
+type wordTokenizer struct{}
+
+func (wordTokenizer) Tokens(text []byte) int { return len(bytes.Fields(text)) }
+
+func TestRunAll_BudgetPolicy(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, ".promptherder/agent/rules/big.md", "# Big\n"+strings.Repeat("word ", 50))
+	createTestFile(t, dir, filepath.Join(manifestDir, settingsFile), `{"token_budgets": {"copilot": 40}, "budget_policy": "fail"}`)
+
+	cfg := Config{RepoPath: dir, Logger: testLogger(t), Tokenizer: wordTokenizer{}}
+	err := RunAll(context.Background(), []Target{CopilotTarget{}}, cfg)
+	if !errors.Is(err, ErrOverBudget) {
+		t.Fatalf("err = %v, want ErrOverBudget", err)
+	}
+	// The sync itself completed and is tracked.
+	m := readManifest(dir, testLogger(t))
+	if len(m.Targets["copilot"]) != 1 {
+		t.Errorf("manifest should track the written outputs, got %v", m.Targets)
+	}
+
+	// Under budget with the same tokenizer passes.
+	createTestFile(t, dir, filepath.Join(manifestDir, settingsFile), `{"token_budgets": {"copilot": 100}, "budget_policy": "fail"}`)
+	if err := RunTarget(context.Background(), CopilotTarget{}, cfg); err != nil {
+		t.Errorf("under budget: %v", err)
+	}
+}
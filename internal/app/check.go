@@ -0,0 +1,96 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+)
+
+// Kinds of Change.
+const (
+	ChangeCreate    = "create"    // the output doesn't exist yet
+	ChangeUpdate    = "update"    // the output exists with other content
+	ChangeDelete    = "delete"    // a stale output the sync would remove
+	ChangeUntracked = "untracked" // up to date on disk, but the manifest doesn't record it
+)
+
+// ErrOutOfDate is returned by `promptherder check` when a sync would change
+// files.
+var ErrOutOfDate = errors.New("outputs are out of date — run promptherder and commit the result")
+
+// Change is a file a sync would create, update or delete.
+type Change struct {
+	Path string // repo-relative, slash-separated
+	Kind string // ChangeCreate, ChangeUpdate, ChangeDelete or ChangeUntracked
+}
+
+// outputRecorder collects the files a dry run would write, by
+// repo-relative path, for check and -diff. A nil *outputRecorder records
+// nothing.
+type outputRecorder struct {
+	outputs  map[string][]byte
+	changes  []Change // set by finishRecording once every target has run
+	skipUser bool     // leave out files from the user layer, which are never committed
+}
+
+func newOutputRecorder() *outputRecorder {
+	return &outputRecorder{outputs: make(map[string][]byte)}
+}
+
+func (r *outputRecorder) record(rel string, content []byte) {
+	if r == nil {
+		return
+	}
+	r.outputs[rel] = content
+}
+
+// compare lists how the recorded outputs differ from disk and from prev,
+// and which files tracked by prev but not cur a sync would delete.
+func (r *outputRecorder) compare(repoPath string, prev, cur manifest, guard *driftGuard) []Change {
+	tracked := prev.allFiles()
+	var changes []Change
+	for rel, content := range r.outputs {
+		disk, err := os.ReadFile(filepath.Join(repoPath, filepath.FromSlash(rel)))
+		switch {
+		case err != nil:
+			changes = append(changes, Change{rel, ChangeCreate})
+		case !bytes.Equal(disk, content):
+			changes = append(changes, Change{rel, ChangeUpdate})
+		case !slices.Contains(tracked, rel):
+			changes = append(changes, Change{rel, ChangeUntracked})
+		}
+	}
+	for _, rel := range staleFiles(repoPath, prev, cur) {
+		if guard.keepsStale(repoPath, rel, prev) || (r.skipUser && (slices.Contains(prev.Targets[userTarget], rel) || slices.Contains(prev.Local, rel))) {
+			continue
+		}
+		changes = append(changes, Change{rel, ChangeDelete})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// Check renders every target in memory, as a bare `promptherder -no-user`
+// would sync them, and returns the files the sync would create, update or
+// delete. Nothing is written, so CI can use it to catch sources that were
+// edited without re-running promptherder. The user layer is left out: its
+// files are never committed, and CI machines don't have one.
+//
+// Herd merges are recorded, not written, so the targets render from the
+// agent dir on disk. When a herd changed, check lists the merged files
+// under .promptherder/agent but not the outputs built from them; the repo
+// is reported out of date either way.
+func Check(ctx context.Context, targets []Target, cfg Config) ([]Change, error) {
+	// Hand-edited outputs are out of date like any other.
+	cfg.DryRun, cfg.Force, cfg.NoUserLayer = true, true, true
+	rec := newOutputRecorder()
+	rec.skipUser = true
+	if err := runAll(ctx, targets, cfg, rec); err != nil {
+		return nil, err
+	}
+	return rec.changes, nil
+}
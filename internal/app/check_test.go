@@ -0,0 +1,127 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, ".promptherder/agent/rules/general.md", "# General\n")
+	createTestFile(t, dir, ".promptherder/agent/rules/shell.md", "---\napplyTo: \"**/*.sh\"\n---\n# Shell\n")
+	targets := []Target{CopilotTarget{}, AntigravityTarget{}}
+	cfg := Config{RepoPath: dir, Logger: testLogger(t)}
+
+	changes, err := Check(context.Background(), targets, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) == 0 || !slices.ContainsFunc(changes, func(c Change) bool {
+		return c == Change{".github/copilot-instructions.md", ChangeCreate}
+	}) {
+		t.Errorf("a fresh repo should list outputs to create, got %v", changes)
+	}
+	if fileExists(filepath.Join(dir, ".github", "copilot-instructions.md")) || fileExists(filepath.Join(dir, manifestDir, manifestFile)) {
+		t.Fatal("check should not write anything")
+	}
+
+	if err := RunAll(context.Background(), targets, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if changes, err := Check(context.Background(), targets, cfg); err != nil || len(changes) != 0 {
+		t.Fatalf("after a sync: changes = %v, err = %v", changes, err)
+	}
+
+	// Edit one source, remove another, and touch an output by hand.
+	createTestFile(t, dir, ".promptherder/agent/rules/general.md", "# General, updated\n")
+	if err := os.Remove(filepath.Join(dir, ".promptherder", "agent", "rules", "shell.md")); err != nil {
+		t.Fatal(err)
+	}
+	createTestFile(t, dir, ".agent/rules/general.md", "edited\n")
+	before := readOutput(t, dir, ".github/copilot-instructions.md")
+
+	changes, err = Check(context.Background(), targets, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Change{
+		{".agent/rules/general.md", ChangeUpdate},
+		{".agent/rules/shell.md", ChangeDelete},
+		{".github/copilot-instructions.md", ChangeUpdate},
+		{".github/instructions/shell.instructions.md", ChangeDelete},
+	}
+	if !slices.Equal(changes, want) {
+		t.Errorf("changes = %v, want %v", changes, want)
+	}
+	if got := readOutput(t, dir, ".github/copilot-instructions.md"); string(got) != string(before) {
+		t.Error("check should not update outputs")
+	}
+	if !fileExists(filepath.Join(dir, ".agent", "rules", "shell.md")) {
+		t.Error("check should not delete stale outputs")
+	}
+}
+
+func TestCheck_Untracked(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, ".promptherder/agent/rules/general.md", "# General\n")
+	cfg := Config{RepoPath: dir, Logger: testLogger(t)}
+	if err := RunTarget(context.Background(), CopilotTarget{}, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, manifestDir, manifestFile)); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := Check(context.Background(), []Target{CopilotTarget{}}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Change{{".github/copilot-instructions.md", ChangeUntracked}}; !slices.Equal(changes, want) {
+		t.Errorf("changes = %v, want %v", changes, want)
+	}
+}
+
+func TestCheck_LeavesOutUserLayer(t *testing.T) {
+	t.Parallel()
+	repo, user := userLayerRepo(t)
+	targets := []Target{CopilotTarget{}, AntigravityTarget{}}
+	cfg := Config{RepoPath: repo, UserDir: user, Logger: testLogger(t)}
+	if err := RunAll(context.Background(), targets, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// Neither the merged user files nor new ones count as changes.
+	createTestFile(t, user, "rules/later.md", "# Later\n")
+	if changes, err := Check(context.Background(), targets, cfg); err != nil || len(changes) != 0 {
+		t.Errorf("the user layer should not affect check: changes = %v, err = %v", changes, err)
+	}
+}
+
+func TestCheck_HerdChange(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, ".promptherder/herds/team/herd.json", `{"name":"team"}`)
+	createTestFile(t, dir, ".promptherder/herds/team/rules/team.md", "# Team\n")
+	targets := []Target{CopilotTarget{}, AntigravityTarget{}}
+	cfg := Config{RepoPath: dir, Logger: testLogger(t)}
+	if err := RunAll(context.Background(), targets, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// The targets render from the agent dir on disk, so only the merge
+	// shows up until it is synced — enough to fail the check.
+	createTestFile(t, dir, ".promptherder/herds/team/rules/team.md", "# Team v2\n")
+	changes, err := Check(context.Background(), targets, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Change{{".promptherder/agent/rules/team.md", ChangeUpdate}}; !slices.Equal(changes, want) {
+		t.Errorf("changes = %v, want %v", changes, want)
+	}
+	assertContains(t, readOutput(t, dir, ".promptherder/agent/rules/team.md"), "# Team\n")
+}
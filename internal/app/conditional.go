@@ -0,0 +1,156 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Conditional blocks let one source carry target-specific sections:
+//
+//	<!-- promptherder:if target=copilot -->
+//	Copilot-only text.
+//	<!-- promptherder:else -->
+//	Everyone else.
+//	<!-- promptherder:endif -->
+//
+// Conditions are target=a,b or target!=a,b; blocks nest, and elif is
+// supported. A directive alone on its line is removed with its line.
+var (
+	directiveRe        = regexp.MustCompile(`<!--\s*promptherder:(\S*)\s*(.*?)\s*-->`)
+	directiveCommentRe = regexp.MustCompile(`^promptherder:(?:(?:if|elif)\s+target\s*!?=\s*[\w.-]+(?:\s*,\s*[\w.-]+)*|else|endif)$`)
+)
+
+// isDirectiveComment reports whether an HTML comment body is a well-formed
+// conditional directive, which the content scanner doesn't flag.
+func isDirectiveComment(body string) bool {
+	return directiveCommentRe.MatchString(strings.TrimSpace(body))
+}
+
+// condFrame is one open if/elif/else chain.
+type condFrame struct {
+	line    int
+	parent  bool // enclosing sections are kept
+	active  bool // the current branch is kept
+	matched bool // some branch of the chain was taken
+	inElse  bool
+}
+
+// renderConditionals keeps the sections of data meant for target and drops
+// the rest. Errors name the 1-based line of the offending directive.
+func renderConditionals(data []byte, target string) ([]byte, error) {
+	if !bytes.Contains(data, []byte("promptherder:")) {
+		return data, nil
+	}
+	matches := directiveRe.FindAllSubmatchIndex(data, -1)
+	if len(matches) == 0 {
+		return data, nil
+	}
+
+	var out bytes.Buffer
+	var stack []condFrame
+	keep := func() bool { return len(stack) == 0 || stack[len(stack)-1].active }
+	pos := 0
+	for _, m := range matches {
+		line := bytes.Count(data[:m[0]], []byte("\n")) + 1
+		verb := string(data[m[2]:m[3]])
+		arg := string(data[m[4]:m[5]])
+
+		// A directive alone on its line takes the whole line with it.
+		start, end := m[0], m[1]
+		lineStart := bytes.LastIndexByte(data[:start], '\n') + 1
+		lineEnd := len(data)
+		if i := bytes.IndexByte(data[end:], '\n'); i >= 0 {
+			lineEnd = end + i + 1
+		}
+		if len(bytes.TrimSpace(data[lineStart:start])) == 0 && len(bytes.TrimSpace(data[end:lineEnd])) == 0 {
+			start, end = lineStart, lineEnd
+		}
+		if keep() {
+			out.Write(data[pos:start])
+		}
+		pos = end
+
+		switch verb {
+		case "if":
+			ok, err := evalCondition(arg, target)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			parent := keep()
+			stack = append(stack, condFrame{line: line, parent: parent, active: parent && ok, matched: ok})
+		case "elif", "else":
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("line %d: %s without if", line, verb)
+			}
+			f := &stack[len(stack)-1]
+			if f.inElse {
+				return nil, fmt.Errorf("line %d: %s after else (if on line %d)", line, verb, f.line)
+			}
+			ok := true
+			if verb == "elif" {
+				var err error
+				if ok, err = evalCondition(arg, target); err != nil {
+					return nil, fmt.Errorf("line %d: %v", line, err)
+				}
+			} else if arg != "" {
+				return nil, fmt.Errorf("line %d: else takes no condition", line)
+			}
+			f.active = f.parent && !f.matched && ok
+			f.matched = f.matched || ok
+			f.inElse = verb == "else"
+		case "endif":
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("line %d: endif without if", line)
+			}
+			if arg != "" {
+				return nil, fmt.Errorf("line %d: endif takes no condition", line)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			return nil, fmt.Errorf("line %d: unknown directive promptherder:%s", line, verb)
+		}
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("line %d: if without endif", stack[len(stack)-1].line)
+	}
+	out.Write(data[pos:])
+	return out.Bytes(), nil
+}
+
+// evalCondition evaluates "target=a,b" or "target!=a,b".
+func evalCondition(cond, target string) (bool, error) {
+	key, values, negate := "", "", false
+	if k, v, ok := strings.Cut(cond, "!="); ok {
+		key, values, negate = k, v, true
+	} else if k, v, ok := strings.Cut(cond, "="); ok {
+		key, values = k, v
+	} else {
+		return false, fmt.Errorf("condition %q: want target=<name>[,<name>]", cond)
+	}
+	if strings.TrimSpace(key) != "target" {
+		return false, fmt.Errorf("condition %q: only target is supported", cond)
+	}
+	var names []string
+	for _, name := range strings.Split(values, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return false, fmt.Errorf("condition %q: no target named", cond)
+	}
+	return slices.Contains(names, target) != negate, nil
+}
+
+// renderSource applies conditional blocks for target to a source file.
+// label names the file in errors.
+func renderSource(data []byte, label, target string) ([]byte, error) {
+	out, err := renderConditionals(data, target)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v: %w", label, err, ErrValidation)
+	}
+	return out, nil
+}
@@ -6,6 +6,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log/slog"
 	"os"
 	"path/filepath"
@@ -20,6 +22,7 @@ import (
 var ErrValidation = errors.New("validation error")
 
 const (
+	copilotName       = "copilot"
 	defaultSourceDir  = ".promptherder/agent/rules"
 	workflowSourceDir = ".promptherder/agent/workflows"
 	skillSourceDir    = ".promptherder/agent/skills"
@@ -34,22 +37,39 @@ type Config struct {
 	SourceDir string // defaults to ".promptherder/agent/rules" if empty
 	Include   []string
 	DryRun    bool
+	Strict    bool      // fail instead of warning when the content scanner finds something
+	Force     bool      // overwrite and delete outputs even if they were edited by hand
+	Diff      io.Writer // in a dry run, print unified diffs of the changes here
 	Logger    *slog.Logger
+	Tokenizer Tokenizer // estimates output sizes for budgets and stats; nil for ~4 chars per token
+
+	SettingsPath string // alternate settings file; defaults to .promptherder/settings.json
+	UserDir      string // user-level source layer; defaults to <user config dir>/promptherder/agent
+	NoUserLayer  bool   // skip the user layer, e.g. for reproducible CI runs
 }
 
 // sourceFile represents a parsed rule from the source directory.
 type sourceFile struct {
-	Path    string // absolute path
-	Name    string // stem without extension, e.g. "00-breakdown-infra"
-	ApplyTo string // from frontmatter; empty means repo-wide
-	Body    []byte // content after frontmatter is stripped
+	Path    string      // absolute path
+	Name    string      // stem without extension, e.g. "00-breakdown-infra"
+	Scope   ruleScope   // trigger and globs from frontmatter
+	Order   int         // position in concatenated outputs; see sortRules
+	Herd    string      // name of the herd that shipped the rule; "" for local rules
+	Package string      // nested package the rule belongs to; "" at the repo root
+	Local   bool        // merged from the user layer; never concatenated into shared outputs
+	Meta    frontmatter // every frontmatter key, typed
+	Body    []byte      // content after frontmatter is stripped
 }
 
 // planItem represents a single output file to write.
 type planItem struct {
-	Target  string
-	Content []byte
-	Sources []string // names, for logging
+	Target   string
+	Content  []byte
+	Sources  []string     // names, for logging
+	AlwaysOn bool         // loaded into every request; counts against the token budget
+	Parts    []outputPart // concatenated sources, for stats
+	Herd     string       // herd that shipped the file, for merged herd content
+	Local    bool         // built from the user layer; git-excluded, never committed
 }
 
 // CopilotTarget implements the Target interface for GitHub Copilot.
@@ -58,7 +78,10 @@ type CopilotTarget struct {
 	Include   []string // glob patterns for source files
 }
 
-func (t CopilotTarget) Name() string { return "copilot" }
+func (t CopilotTarget) Name() string { return copilotName }
+
+// AlwaysOnBudget is the default token budget for copilot-instructions.md.
+func (t CopilotTarget) AlwaysOnBudget() int { return defaultBudget }
 
 func (t CopilotTarget) Install(ctx context.Context, cfg TargetConfig) ([]string, error) {
 	srcDir := t.SourceDir
@@ -66,55 +89,51 @@ func (t CopilotTarget) Install(ctx context.Context, cfg TargetConfig) ([]string,
 		srcDir = defaultSourceDir
 	}
 
-	var written []string
+	r, err := newSourceRenderer(cfg.RepoPath, cfg.Settings, copilotName)
+	if err != nil {
+		return nil, err
+	}
+	r.user = cfg.user
 
 	// 1. Rules → copilot-instructions.md + instruction files.
-	sources, err := readSources(cfg.RepoPath, srcDir, t.Include)
+	sources, hardRules, err := t.readRules(r, cfg, srcDir, hardRulesFile)
 	if err != nil {
 		return nil, err
 	}
 
-	// Inject hard-rules.md as the first source (always-on, no applyTo).
-	hardRulesInjected := false
-	hardRulesPath := filepath.Join(cfg.RepoPath, filepath.FromSlash(hardRulesFile))
-	if data, readErr := os.ReadFile(hardRulesPath); readErr == nil {
-		_, body := parseFrontmatter(data)
-		hardRule := sourceFile{
-			Path:    hardRulesPath,
-			Name:    "hard-rules",
-			ApplyTo: "",
-			Body:    body,
-		}
-		sources = append([]sourceFile{hardRule}, sources...)
-		hardRulesInjected = true
+	// Point cross-references at Copilot's output paths.
+	if r.outputs, err = copilotOutputs(cfg.RepoPath, cfg.Settings, sources); err != nil {
+		return nil, err
 	}
+	r.logger = cfg.Logger
 
-	if len(sources) > 0 {
-		plan := buildCopilotPlan(cfg.RepoPath, srcDir, sources)
-		cfg.Logger.Info("plan", "target", "copilot/rules", "sources", len(sources), "hard-rules", hardRulesInjected, "outputs", len(plan))
-		written, err = writeItems(ctx, cfg, plan, written)
-		if err != nil {
-			return written, err
-		}
-	} else {
-		cfg.Logger.Debug("no source files found", "dir", srcDir)
+	written, err := writeCopilotRules(ctx, cfg, r, srcDir, sources, hardRules)
+	if err != nil {
+		return written, err
 	}
 
 	// 2. Workflows → .github/prompts/*.prompt.md.
-	promptItems, err := buildCopilotPrompts(cfg.RepoPath, cfg.Settings)
+	promptItems, err := buildCopilotPrompts(cfg.RepoPath, cfg.Settings, r)
 	if err != nil {
 		return written, err
 	}
 
 	// 3. Skills → .github/prompts/*.prompt.md.
-	skillItems, err := buildCopilotSkillPrompts(cfg.RepoPath)
+	skillItems, err := buildCopilotSkillPrompts(cfg.RepoPath, r)
 	if err != nil {
 		return written, err
 	}
 	promptItems = append(promptItems, skillItems...)
 
+	// 4. Skill assets → .github/prompts/<skill>/.
+	assetItems, err := buildCopilotSkillAssets(cfg.RepoPath, r)
+	if err != nil {
+		return written, err
+	}
+	promptItems = append(promptItems, assetItems...)
+
 	if len(promptItems) > 0 {
-		cfg.Logger.Info("plan", "target", "copilot/prompts", "workflows", len(promptItems)-len(skillItems), "skills", len(skillItems))
+		cfg.Logger.Info("plan", "target", "copilot/prompts", "workflows", len(promptItems)-len(skillItems)-len(assetItems), "skills", len(skillItems), "assets", len(assetItems))
 	}
 	written, err = writeItems(ctx, cfg, promptItems, written)
 	if err != nil {
@@ -124,6 +143,97 @@ func (t CopilotTarget) Install(ctx context.Context, cfg TargetConfig) ([]string,
 	return written, nil
 }
 
+// InstallPackage syncs the rules of a nested package: each becomes an
+// instructions file named after the package, scoped to its subtree
+// (always-on rules and hard-rules.md apply to pkg/**).
+func (t CopilotTarget) InstallPackage(ctx context.Context, cfg TargetConfig, pkg string) ([]string, error) {
+	srcDir := t.SourceDir
+	if srcDir == "" {
+		srcDir = defaultSourceDir
+	}
+	srcDir = pkg + "/" + srcDir
+
+	r, err := newSourceRenderer(cfg.RepoPath, cfg.Settings, copilotName)
+	if err != nil {
+		return nil, err
+	}
+	r.root = pkg + "/" + agentDir
+
+	sources, hardRules, err := t.readRules(r, cfg, srcDir, pkg+"/"+hardRulesFile)
+	if err != nil {
+		return nil, err
+	}
+	for i, s := range sources {
+		sources[i].Package = pkg
+		sources[i].Scope = scopeToPackage(s.Scope, pkg)
+	}
+	warnPackageCommands(cfg, copilotName, pkg)
+
+	if r.outputs, err = copilotOutputs(cfg.RepoPath, cfg.Settings, sources); err != nil {
+		return nil, err
+	}
+	r.logger = cfg.Logger
+
+	return writeCopilotRules(ctx, cfg, r, srcDir, sources, hardRules)
+}
+
+// readRules reads the rules under srcDir in concatenation order, with the
+// hard-rules file at hardRules (both repo-relative) first, always on, if it
+// exists. injected reports whether it did.
+func (t CopilotTarget) readRules(r sourceRenderer, cfg TargetConfig, srcDir, hardRules string) (sources []sourceFile, injected bool, err error) {
+	sources, err = readSources(cfg.RepoPath, srcDir, t.Include, r)
+	if err != nil {
+		return nil, false, err
+	}
+
+	sortRules(sources, cfg.Settings.RuleOrder)
+
+	// Inject hard-rules.md as the first source (always-on, no applyTo).
+	hardRulesPath := filepath.Join(cfg.RepoPath, filepath.FromSlash(hardRules))
+	if data, readErr := os.ReadFile(hardRulesPath); readErr == nil {
+		data, err := r.render(data, hardRules, "")
+		if err != nil {
+			return nil, false, err
+		}
+		meta, body, err := parseFrontmatter(data)
+		if err != nil {
+			return nil, false, fmt.Errorf("%s: frontmatter %v: %w", hardRules, err, ErrValidation)
+		}
+		hardRule := sourceFile{
+			Path:  hardRulesPath,
+			Name:  "hard-rules",
+			Scope: ruleScope{Trigger: triggerAlways},
+			Meta:  meta,
+			Body:  body,
+		}
+		sources = append([]sourceFile{hardRule}, sources...)
+		injected = true
+	}
+	return sources, injected, nil
+}
+
+// writeCopilotRules rewrites cross-references in rules and writes
+// copilot-instructions.md and the instruction files.
+func writeCopilotRules(ctx context.Context, cfg TargetConfig, r sourceRenderer, srcDir string, sources []sourceFile, hardRulesInjected bool) ([]string, error) {
+	if len(sources) == 0 {
+		cfg.Logger.Debug("no source files found", "dir", srcDir)
+		return nil, nil
+	}
+
+	for i, s := range sources {
+		if len(s.Scope.ExcludeFrom) > 0 {
+			cfg.Logger.Warn("copilot has no exclude globs — excludeFrom ignored", "rule", s.Name, "excludeFrom", s.Scope.ExcludeFrom)
+		}
+		if rel, err := filepath.Rel(cfg.RepoPath, s.Path); err == nil {
+			sources[i].Body = r.rewriteRefs(s.Body, filepath.ToSlash(rel), copilotRuleOutput(sources[i]))
+		}
+	}
+
+	plan := buildCopilotPlan(cfg.RepoPath, srcDir, sources, cfg.Settings.SectionHeaders)
+	cfg.Logger.Info("plan", "target", "copilot/rules", "sources", len(sources), "hard-rules", hardRulesInjected, "outputs", len(plan))
+	return writeItems(ctx, cfg, plan, nil)
+}
+
 // RunCopilot is the legacy entry point that runs the Copilot target with
 // manifest management. Preserved for backward compatibility.
 func RunCopilot(ctx context.Context, cfg Config) error {
@@ -169,11 +279,12 @@ func RunCopilot(ctx context.Context, cfg Config) error {
 		}
 	}
 
-	return cleanStale(repoPath, prevManifest, curManifest, cfg.DryRun, cfg.Logger)
+	return cleanStale(repoPath, prevManifest, curManifest, nil, cfg.DryRun, cfg.Logger)
 }
 
-// readSources discovers and parses all rule files under the given source directory.
-func readSources(repoPath, srcDir string, include []string) ([]sourceFile, error) {
+// readSources discovers, renders and parses all rule files under the given
+// source directory.
+func readSources(repoPath, srcDir string, include []string, r sourceRenderer) ([]sourceFile, error) {
 	root := filepath.Join(repoPath, filepath.FromSlash(srcDir))
 
 	info, err := os.Stat(root)
@@ -212,57 +323,103 @@ func readSources(repoPath, srcDir string, include []string) ([]sourceFile, error
 			continue
 		}
 
+		label := srcDir + "/" + match
+		if r.leftover(label) {
+			continue
+		}
 		data, err := os.ReadFile(absPath)
 		if err != nil {
 			return nil, fmt.Errorf("read %s: %w", absPath, err)
 		}
+		if data, err = r.render(data, label, agentRelPath(label)); err != nil {
+			return nil, err
+		}
 
-		applyTo, body := parseFrontmatter(data)
+		meta, body, err := parseFrontmatter(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s/%s: frontmatter %v: %w", srcDir, match, err, ErrValidation)
+		}
+		scope, err := parseRuleScope(meta)
+		if err != nil {
+			return nil, fmt.Errorf("%s/%s: frontmatter %v: %w", srcDir, match, err, ErrValidation)
+		}
+		order, err := parseRuleOrder(meta)
+		if err != nil {
+			return nil, fmt.Errorf("%s/%s: frontmatter %v: %w", srcDir, match, err, ErrValidation)
+		}
 		name := strings.TrimSuffix(filepath.Base(match), filepath.Ext(match))
 
 		sources = append(sources, sourceFile{
-			Path:    absPath,
-			Name:    name,
-			ApplyTo: applyTo,
-			Body:    body,
+			Path:  absPath,
+			Name:  name,
+			Scope: scope,
+			Order: order,
+			Herd:  r.herdFor(agentRelPath(label)).Name,
+			Local: r.personal(label),
+			Meta:  meta,
+			Body:  body,
 		})
 	}
 
 	return sources, nil
 }
 
-// buildCopilotPlan creates output plan items for Copilot targets.
-func buildCopilotPlan(repoPath, srcDir string, sources []sourceFile) []planItem {
+// buildCopilotPlan creates output plan items for Copilot targets. With
+// sections, each always-on rule in copilot-instructions.md is wrapped in
+// comments naming its source and herd, under a table of contents. Always-on
+// rules from the user layer get an instructions file applied to every file
+// instead, so copilot-instructions.md can be committed.
+func buildCopilotPlan(repoPath, srcDir string, sources []sourceFile, sections bool) []planItem {
 	var plan []planItem
 
-	// .github/copilot-instructions.md — sources WITHOUT applyTo.
-	var copilotParts [][]byte
+	// .github/copilot-instructions.md — always-on sources.
+	var always []sourceFile
 	var copilotSources []string
+	var parts []outputPart
 	for _, s := range sources {
-		if s.ApplyTo == "" {
-			copilotParts = append(copilotParts, s.Body)
+		if s.Scope.Trigger == triggerAlways && !s.Local {
+			always = append(always, s)
 			copilotSources = append(copilotSources, s.Name)
+			parts = append(parts, outputPart{Source: s.Name, Content: s.Body})
 		}
 	}
-	if len(copilotParts) > 0 {
+	if len(always) > 0 {
+		header := fmt.Sprintf("<!-- Auto-generated by promptherder from %s/ — do not edit -->\n", srcDir)
+		var content []byte
+		if sections {
+			content = concatWithSections(header, repoPath, always)
+		} else {
+			parts := make([][]byte, len(always))
+			for i, s := range always {
+				parts[i] = s.Body
+			}
+			content = concatWithHeader(header, parts)
+		}
 		plan = append(plan, planItem{
-			Target: filepath.Join(repoPath, filepath.FromSlash(copilotTarget)),
-			Content: concatWithHeader(
-				fmt.Sprintf("<!-- Auto-generated by promptherder from %s/ — do not edit -->\n", srcDir),
-				copilotParts,
-			),
-			Sources: copilotSources,
+			Target:   filepath.Join(repoPath, filepath.FromSlash(copilotTarget)),
+			Content:  content,
+			Sources:  copilotSources,
+			AlwaysOn: true,
+			Parts:    parts,
 		})
 	}
 
-	// .github/instructions/<name>.instructions.md — each source WITH applyTo.
+	// .github/instructions/<name>.instructions.md — every other trigger.
 	for _, s := range sources {
-		if s.ApplyTo == "" {
-			continue
+		scope := s.Scope
+		if scope.Trigger == triggerAlways {
+			if !s.Local {
+				continue
+			}
+			scope.Trigger, scope.ApplyTo = triggerGlob, []string{"**"}
 		}
 
-		header := fmt.Sprintf("---\napplyTo: %q\n---\n<!-- Auto-generated by promptherder from %s/%s.md — do not edit -->\n",
-			s.ApplyTo, srcDir, s.Name)
+		src := srcDir + "/" + s.Name + ".md"
+		if rel, err := filepath.Rel(repoPath, s.Path); err == nil && s.Package != "" {
+			src = filepath.ToSlash(rel) // hard-rules.md lives outside srcDir
+		}
+		header := copilotRuleHeader(scope) +
+			fmt.Sprintf("<!-- Auto-generated by promptherder from %s — do not edit -->\n", src)
 
 		var buf bytes.Buffer
 		buf.WriteString(header)
@@ -271,9 +428,11 @@ func buildCopilotPlan(repoPath, srcDir string, sources []sourceFile) []planItem
 		buf.WriteByte('\n')
 
 		plan = append(plan, planItem{
-			Target:  filepath.Join(repoPath, filepath.FromSlash(copilotInstDir), s.Name+".instructions.md"),
-			Content: buf.Bytes(),
-			Sources: []string{s.Name},
+			Target:   filepath.Join(repoPath, filepath.FromSlash(copilotRuleOutput(s))),
+			Content:  buf.Bytes(),
+			Sources:  []string{s.Name},
+			AlwaysOn: s.Scope.Trigger == triggerAlways,
+			Local:    s.Local,
 		})
 	}
 
@@ -287,7 +446,7 @@ func buildCopilotPlan(repoPath, srcDir string, sources []sourceFile) []planItem
 //   - Antigravity frontmatter (description) → Copilot frontmatter (mode, description)
 //   - Strips Antigravity-specific annotations (// turbo, // turbo-all)
 //   - Renames: brainstorm.md → brainstorm.prompt.md
-func buildCopilotPrompts(repoPath string, settings Settings) ([]planItem, error) {
+func buildCopilotPrompts(repoPath string, settings Settings, r sourceRenderer) ([]planItem, error) {
 	wfRoot := filepath.Join(repoPath, filepath.FromSlash(workflowSourceDir))
 
 	if _, err := os.Stat(wfRoot); os.IsNotExist(err) {
@@ -305,18 +464,32 @@ func buildCopilotPrompts(repoPath string, settings Settings) ([]planItem, error)
 			continue
 		}
 
+		label := workflowSourceDir + "/" + entry.Name()
+		if r.leftover(label) {
+			continue
+		}
 		data, err := os.ReadFile(filepath.Join(wfRoot, entry.Name()))
 		if err != nil {
 			return nil, fmt.Errorf("read workflow %s: %w", entry.Name(), err)
 		}
+		if data, err = r.render(data, label, agentRelPath(label)); err != nil {
+			return nil, err
+		}
 
-		promptContent := convertWorkflowToPrompt(workflowSourceDir, entry.Name(), data)
 		stem := strings.TrimSuffix(entry.Name(), ".md")
+		out := copilotPromptsDir + "/" + settings.PrefixCommand(stem+".prompt.md")
+		data = r.rewriteRefs(data, label, out)
+
+		promptContent, err := convertWorkflowToPrompt(workflowSourceDir, entry.Name(), data)
+		if err != nil {
+			return nil, err
+		}
 
 		plan = append(plan, planItem{
-			Target:  filepath.Join(repoPath, filepath.FromSlash(copilotPromptsDir), settings.PrefixCommand(stem+".prompt.md")),
+			Target:  filepath.Join(repoPath, filepath.FromSlash(out)),
 			Content: promptContent,
 			Sources: []string{stem},
+			Local:   r.personal(label),
 		})
 	}
 
@@ -329,7 +502,7 @@ func buildCopilotPrompts(repoPath string, settings Settings) ([]planItem, error)
 // Each skill directory may contain a COPILOT.md (target-specific variant) or
 // SKILL.md (generic). COPILOT.md takes priority when present.
 // The directory name becomes the prompt file name (e.g., compound-v-tdd → compound-v-tdd.prompt.md).
-func buildCopilotSkillPrompts(repoPath string) ([]planItem, error) {
+func buildCopilotSkillPrompts(repoPath string, r sourceRenderer) ([]planItem, error) {
 	skillsRoot := filepath.Join(repoPath, filepath.FromSlash(skillSourceDir))
 
 	if _, err := os.Stat(skillsRoot); os.IsNotExist(err) {
@@ -355,6 +528,10 @@ func buildCopilotSkillPrompts(repoPath string) ([]planItem, error) {
 			sourceLabel = entry.Name() + "/SKILL.md"
 		}
 
+		label := skillSourceDir + "/" + sourceLabel
+		if r.leftover(label) {
+			continue
+		}
 		data, err := os.ReadFile(skillFile)
 		if err != nil {
 			if os.IsNotExist(err) {
@@ -362,25 +539,127 @@ func buildCopilotSkillPrompts(repoPath string) ([]planItem, error) {
 			}
 			return nil, fmt.Errorf("read skill %s: %w", entry.Name(), err)
 		}
+		if data, err = r.render(data, label, agentRelPath(label)); err != nil {
+			return nil, err
+		}
 
-		promptContent := convertWorkflowToPrompt(skillSourceDir, sourceLabel, data)
+		// Links to assets now resolve to the copies next to the prompt
+		// (see buildCopilotSkillAssets).
+		out := copilotPromptsDir + "/" + entry.Name() + ".prompt.md"
+		data = r.rewriteRefs(data, label, out)
+
+		promptContent, err := convertWorkflowToPrompt(skillSourceDir, sourceLabel, data)
+		if err != nil {
+			return nil, err
+		}
 
 		plan = append(plan, planItem{
-			Target:  filepath.Join(repoPath, filepath.FromSlash(copilotPromptsDir), entry.Name()+".prompt.md"),
+			Target:  filepath.Join(repoPath, filepath.FromSlash(out)),
 			Content: promptContent,
 			Sources: []string{entry.Name()},
+			Local:   r.personal(label),
 		})
 	}
 
 	return plan, nil
 }
 
+// buildCopilotSkillAssets copies the other files in each skill directory
+// (scripts, checklists, templates) to .github/prompts/<skill>/, next to the
+// skill's prompt file. Markdown assets are rendered like any source.
+func buildCopilotSkillAssets(repoPath string, r sourceRenderer) ([]planItem, error) {
+	skillsRoot := filepath.Join(repoPath, filepath.FromSlash(skillSourceDir))
+	entries, err := os.ReadDir(skillsRoot)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read skills dir: %w", err)
+	}
+
+	var plan []planItem
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		skillDir := filepath.Join(skillsRoot, entry.Name())
+		if !fileExists(filepath.Join(skillDir, "SKILL.md")) && !fileExists(filepath.Join(skillDir, "COPILOT.md")) {
+			continue // no prompt, so nothing links to the assets
+		}
+		assets, err := skillAssets(skillDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, rel := range assets {
+			label := skillSourceDir + "/" + entry.Name() + "/" + rel
+			if r.leftover(label) {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(skillDir, filepath.FromSlash(rel)))
+			if err != nil {
+				return nil, fmt.Errorf("read skill asset %s/%s: %w", entry.Name(), rel, err)
+			}
+			out := copilotPromptsDir + "/" + entry.Name() + "/" + rel
+			if strings.HasSuffix(rel, ".md") {
+				if data, err = r.render(data, label, agentRelPath(label)); err != nil {
+					return nil, err
+				}
+				data = r.rewriteRefs(data, label, out)
+			}
+			plan = append(plan, planItem{
+				Target:  filepath.Join(repoPath, filepath.FromSlash(out)),
+				Content: data,
+				Sources: []string{entry.Name() + "/" + rel},
+				Local:   r.personal(label),
+			})
+		}
+	}
+	return plan, nil
+}
+
+// skillAssets lists the files in a skill directory other than SKILL.md and
+// its variants, as sorted slash paths. Hidden files and dirs are skipped.
+func skillAssets(skillDir string) ([]string, error) {
+	var assets []string
+	err := filepath.WalkDir(skillDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != skillDir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(skillDir, path)
+		if err != nil {
+			return fmt.Errorf("rel path: %w", err)
+		}
+		rel = filepath.ToSlash(rel)
+		if _, variant := SkillVariantFiles[rel]; variant || rel == "SKILL.md" {
+			return nil
+		}
+		assets = append(assets, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list skill assets %s: %w", filepath.Base(skillDir), err)
+	}
+	return assets, nil
+}
+
 // convertWorkflowToPrompt transforms an Antigravity workflow or skill file
 // into a Copilot .prompt.md file.
-func convertWorkflowToPrompt(sourceDir, filename string, data []byte) []byte {
+func convertWorkflowToPrompt(sourceDir, filename string, data []byte) ([]byte, error) {
 	// Parse frontmatter to extract description.
-	_, body := parseFrontmatter(data)
-	desc := extractDescription(data)
+	meta, body, err := parseFrontmatter(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s/%s: frontmatter %v: %w", sourceDir, filename, err, ErrValidation)
+	}
+	desc := strings.TrimSpace(meta.String("description"))
 
 	// Strip Antigravity-specific annotations.
 	body = stripAntigravityAnnotations(body)
@@ -398,7 +677,7 @@ func convertWorkflowToPrompt(sourceDir, filename string, data []byte) []byte {
 	buf.Write(bytes.TrimSpace(body))
 	buf.WriteByte('\n')
 
-	return buf.Bytes()
+	return buf.Bytes(), nil
 }
 
 // writeItems writes a batch of plan items, respecting dry-run and context cancellation.
@@ -410,38 +689,49 @@ func writeItems(ctx context.Context, cfg TargetConfig, items []planItem, written
 		}
 		rel, _ := filepath.Rel(cfg.RepoPath, item.Target)
 		relSlash := filepath.ToSlash(rel)
-		if cfg.DryRun {
-			cfg.Logger.Info("dry-run", "target", relSlash, "sources", item.Sources)
-		} else {
-			if err := writeFile(item.Target, item.Content); err != nil {
-				return written, err
-			}
-			cfg.Logger.Info("synced", "target", relSlash, "sources", item.Sources)
+		emitted, err := writeOutput(cfg, relSlash, item.Content, "sources", item.Sources)
+		if err != nil {
+			return written, err
+		}
+		if emitted {
+			cfg.stats.record(relSlash, item.Content, item.AlwaysOn, item.Parts)
+		} else if kept, err := os.ReadFile(item.Target); err == nil {
+			// The agent reads the hand-edited file, so that is what counts.
+			cfg.stats.record(relSlash, kept, item.AlwaysOn, nil)
+		}
+		if item.Local && cfg.local != nil {
+			*cfg.local = append(*cfg.local, relSlash)
 		}
 		written = append(written, relSlash)
 	}
 	return written, nil
 }
 
-// extractDescription pulls the description value from YAML frontmatter.
-func extractDescription(data []byte) string {
-	scanner := bufio.NewScanner(bytes.NewReader(data))
-	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != "---" {
-		return ""
+// writeOutput writes one target output at relSlash, like emitFile. A file
+// edited by hand since the last sync is handled by cfg.guard first, and may
+// be left alone; emitted reports whether content was written.
+func writeOutput(cfg TargetConfig, relSlash string, content []byte, attrs ...any) (emitted bool, err error) {
+	ok, err := cfg.guard.allow(relSlash, content)
+	if err != nil || !ok {
+		return false, err
 	}
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "---" {
-			break
-		}
-		if strings.HasPrefix(line, "description:") {
-			val := strings.TrimPrefix(line, "description:")
-			val = strings.TrimSpace(val)
-			val = strings.Trim(val, `"'`)
-			return val
-		}
+	return true, emitFile(cfg, relSlash, content, attrs...)
+}
+
+// emitFile writes content to relSlash, or in a dry run logs it and hands
+// it to cfg.rec for check. attrs name its sources in the log.
+func emitFile(cfg TargetConfig, relSlash string, content []byte, attrs ...any) error {
+	attrs = append([]any{"target", relSlash}, attrs...)
+	if cfg.DryRun {
+		cfg.Logger.Info("dry-run", attrs...)
+		cfg.rec.record(relSlash, content)
+		return nil
+	}
+	if err := writeFile(filepath.Join(cfg.RepoPath, filepath.FromSlash(relSlash)), content); err != nil {
+		return err
 	}
-	return ""
+	cfg.Logger.Info("synced", attrs...)
+	return nil
 }
 
 // stripAntigravityAnnotations removes lines like "// turbo" and "// turbo-all"
@@ -460,48 +750,6 @@ func stripAntigravityAnnotations(body []byte) []byte {
 	return []byte(strings.Join(lines, "\n"))
 }
 
-// parseFrontmatter extracts the applyTo value from YAML frontmatter.
-func parseFrontmatter(data []byte) (applyTo string, body []byte) {
-	scanner := bufio.NewScanner(bytes.NewReader(data))
-
-	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != "---" {
-		return "", data
-	}
-
-	var fmLines []string
-	closed := false
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.TrimSpace(line) == "---" {
-			closed = true
-			break
-		}
-		fmLines = append(fmLines, line)
-	}
-
-	if !closed {
-		return "", data
-	}
-
-	for _, line := range fmLines {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "applyTo:") {
-			val := strings.TrimPrefix(line, "applyTo:")
-			val = strings.TrimSpace(val)
-			val = strings.Trim(val, `"'`)
-			applyTo = val
-		}
-	}
-
-	var bodyBuf bytes.Buffer
-	for scanner.Scan() {
-		bodyBuf.WriteString(scanner.Text())
-		bodyBuf.WriteByte('\n')
-	}
-
-	return applyTo, bodyBuf.Bytes()
-}
-
 // concatWithHeader joins body parts with a leading header comment.
 func concatWithHeader(header string, parts [][]byte) []byte {
 	var buf bytes.Buffer
@@ -519,6 +767,38 @@ func concatWithHeader(header string, parts [][]byte) []byte {
 	return buf.Bytes()
 }
 
+// concatWithSections joins rule bodies like concatWithHeader, but lists
+// the sources in a contents comment after the header and wraps each body in
+// begin/end comments carrying its number, source path and herd.
+func concatWithSections(header, repoPath string, sources []sourceFile) []byte {
+	titles := make([]string, len(sources))
+	for i, s := range sources {
+		rel, err := filepath.Rel(repoPath, s.Path)
+		if err != nil {
+			rel = s.Path
+		}
+		titles[i] = fmt.Sprintf("%d. %s — %s", i+1, s.Name, filepath.ToSlash(rel))
+		if s.Herd != "" {
+			titles[i] += fmt.Sprintf(" (herd %s)", s.Herd)
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	buf.WriteString("<!-- Contents:\n")
+	for _, title := range titles {
+		fmt.Fprintf(&buf, "  %s\n", title)
+	}
+	buf.WriteString("-->\n")
+
+	for i, s := range sources {
+		fmt.Fprintf(&buf, "\n<!-- begin %s -->\n", titles[i])
+		buf.Write(bytes.TrimSpace(s.Body))
+		fmt.Fprintf(&buf, "\n<!-- end %d. %s -->\n", i+1, s.Name)
+	}
+	return buf.Bytes()
+}
+
 func writeFile(target string, content []byte) error {
 	writer := files.AtomicWriter{Path: target, Perm: 0o644}
 	if err := writer.Write(content); err != nil {
@@ -535,6 +815,11 @@ func isDirectory(path string) bool {
 	return info.IsDir()
 }
 
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
 func dedupeStrings(items []string) []string {
 	seen := make(map[string]bool, len(items))
 	result := make([]string, 0, len(items))
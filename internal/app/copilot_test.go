@@ -3,9 +3,11 @@ package app
 import (
 	"bytes"
 	"context"
+	"errors"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -14,9 +16,12 @@ import (
 
 func TestParseFrontmatter_WithApplyTo(t *testing.T) {
 	input := []byte("---\napplyTo: \"**/*.sh\"\n---\n# Shell rules\n\nDo the thing.\n")
-	applyTo, body := parseFrontmatter(input)
+	meta, body, err := parseFrontmatter(input)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	if applyTo != "**/*.sh" {
+	if applyTo := meta.String("applyTo"); applyTo != "**/*.sh" {
 		t.Errorf("applyTo = %q, want %q", applyTo, "**/*.sh")
 	}
 	if !bytes.Contains(body, []byte("# Shell rules")) {
@@ -29,10 +34,13 @@ func TestParseFrontmatter_WithApplyTo(t *testing.T) {
 
 func TestParseFrontmatter_WithoutFrontmatter(t *testing.T) {
 	input := []byte("# Just a doc\n\nNo frontmatter here.\n")
-	applyTo, body := parseFrontmatter(input)
+	meta, body, err := parseFrontmatter(input)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	if applyTo != "" {
-		t.Errorf("applyTo = %q, want empty", applyTo)
+	if len(meta) != 0 {
+		t.Errorf("meta = %v, want empty", meta)
 	}
 	if !bytes.Equal(body, input) {
 		t.Errorf("body should equal input verbatim")
@@ -41,9 +49,12 @@ func TestParseFrontmatter_WithoutFrontmatter(t *testing.T) {
 
 func TestParseFrontmatter_UnclosedFrontmatter(t *testing.T) {
 	input := []byte("---\napplyTo: \"**/*.yaml\"\n# No closing delimiter\n")
-	applyTo, body := parseFrontmatter(input)
+	meta, body, err := parseFrontmatter(input)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	if applyTo != "" {
+	if applyTo := meta.String("applyTo"); applyTo != "" {
 		t.Errorf("unclosed frontmatter should return empty applyTo, got %q", applyTo)
 	}
 	if !bytes.Equal(body, input) {
@@ -53,26 +64,35 @@ func TestParseFrontmatter_UnclosedFrontmatter(t *testing.T) {
 
 func TestParseFrontmatter_SingleQuotes(t *testing.T) {
 	input := []byte("---\napplyTo: '**/*.yaml'\n---\n# Content\n")
-	applyTo, _ := parseFrontmatter(input)
+	meta, _, err := parseFrontmatter(input)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	if applyTo != "**/*.yaml" {
+	if applyTo := meta.String("applyTo"); applyTo != "**/*.yaml" {
 		t.Errorf("applyTo = %q, want %q", applyTo, "**/*.yaml")
 	}
 }
 
 func TestParseFrontmatter_NoQuotes(t *testing.T) {
 	input := []byte("---\napplyTo: **/*.yaml\n---\n# Content\n")
-	applyTo, _ := parseFrontmatter(input)
+	meta, _, err := parseFrontmatter(input)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	if applyTo != "**/*.yaml" {
+	if applyTo := meta.String("applyTo"); applyTo != "**/*.yaml" {
 		t.Errorf("applyTo = %q, want %q", applyTo, "**/*.yaml")
 	}
 }
 
 func TestParseFrontmatter_EmptyFile(t *testing.T) {
-	applyTo, body := parseFrontmatter([]byte{})
-	if applyTo != "" {
-		t.Errorf("applyTo = %q, want empty", applyTo)
+	meta, body, err := parseFrontmatter([]byte{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(meta) != 0 {
+		t.Errorf("meta = %v, want empty", meta)
 	}
 	if len(body) != 0 {
 		t.Errorf("body should be empty, got %q", body)
@@ -89,7 +109,7 @@ func TestReadSources_Basic(t *testing.T) {
 	mustWrite(t, filepath.Join(rulesDir, "00-general.md"), "# General\n\nBe good.\n")
 	mustWrite(t, filepath.Join(rulesDir, "01-shell.md"), "---\napplyTo: \"**/*.sh\"\n---\n# Shell\n\nSafe bash.\n")
 
-	sources, err := readSources(dir, ".promptherder/agent/rules", nil)
+	sources, err := readSources(dir, ".promptherder/agent/rules", nil, sourceRenderer{target: copilotName})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -101,20 +121,20 @@ func TestReadSources_Basic(t *testing.T) {
 	if sources[0].Name != "00-general" {
 		t.Errorf("sources[0].Name = %q, want %q", sources[0].Name, "00-general")
 	}
-	if sources[0].ApplyTo != "" {
-		t.Errorf("sources[0].ApplyTo = %q, want empty", sources[0].ApplyTo)
+	if len(sources[0].Scope.ApplyTo) != 0 {
+		t.Errorf("sources[0].Scope.ApplyTo = %q, want empty", sources[0].Scope.ApplyTo)
 	}
 	if sources[1].Name != "01-shell" {
 		t.Errorf("sources[1].Name = %q, want %q", sources[1].Name, "01-shell")
 	}
-	if sources[1].ApplyTo != "**/*.sh" {
-		t.Errorf("sources[1].ApplyTo = %q, want %q", sources[1].ApplyTo, "**/*.sh")
+	if strings.Join(sources[1].Scope.ApplyTo, ",") != "**/*.sh" {
+		t.Errorf("sources[1].Scope.ApplyTo = %q, want %q", sources[1].Scope.ApplyTo, "**/*.sh")
 	}
 }
 
 func TestReadSources_MissingDir(t *testing.T) {
 	dir := t.TempDir()
-	sources, err := readSources(dir, ".promptherder/agent/rules", nil)
+	sources, err := readSources(dir, ".promptherder/agent/rules", nil, sourceRenderer{target: copilotName})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -131,7 +151,7 @@ func TestReadSources_IncludeFilter(t *testing.T) {
 	mustWrite(t, filepath.Join(rulesDir, "keep.md"), "# Keep\n")
 	mustWrite(t, filepath.Join(rulesDir, "skip.txt"), "skip\n")
 
-	sources, err := readSources(dir, ".promptherder/agent/rules", []string{"**/*.md"})
+	sources, err := readSources(dir, ".promptherder/agent/rules", []string{"**/*.md"}, sourceRenderer{target: copilotName})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -148,11 +168,11 @@ func TestReadSources_IncludeFilter(t *testing.T) {
 func TestBuildCopilotPlan_AllRepoWide(t *testing.T) {
 	repoPath := "/repo"
 	sources := []sourceFile{
-		{Name: "00-general", Body: []byte("# General\n")},
-		{Name: "01-ops", Body: []byte("# Ops\n")},
+		{Name: "00-general", Scope: ruleScope{Trigger: triggerAlways}, Body: []byte("# General\n")},
+		{Name: "01-ops", Scope: ruleScope{Trigger: triggerAlways}, Body: []byte("# Ops\n")},
 	}
 
-	plan := buildCopilotPlan(repoPath, ".promptherder/agent/rules", sources)
+	plan := buildCopilotPlan(repoPath, ".promptherder/agent/rules", sources, false)
 
 	if len(plan) != 1 {
 		t.Fatalf("expected 1 plan item, got %d", len(plan))
@@ -167,11 +187,11 @@ func TestBuildCopilotPlan_AllRepoWide(t *testing.T) {
 func TestBuildCopilotPlan_WithApplyTo(t *testing.T) {
 	repoPath := "/repo"
 	sources := []sourceFile{
-		{Name: "00-general", Body: []byte("# General\n")},
-		{Name: "01-shell", ApplyTo: "**/*.sh", Body: []byte("# Shell\n")},
+		{Name: "00-general", Scope: ruleScope{Trigger: triggerAlways}, Body: []byte("# General\n")},
+		{Name: "01-shell", Scope: ruleScope{Trigger: triggerGlob, ApplyTo: []string{"**/*.sh"}}, Body: []byte("# Shell\n")},
 	}
 
-	plan := buildCopilotPlan(repoPath, ".promptherder/agent/rules", sources)
+	plan := buildCopilotPlan(repoPath, ".promptherder/agent/rules", sources, false)
 
 	if len(plan) != 2 {
 		t.Fatalf("expected 2 plan items, got %d", len(plan))
@@ -189,11 +209,11 @@ func TestBuildCopilotPlan_WithApplyTo(t *testing.T) {
 func TestBuildCopilotPlan_AllWithApplyTo(t *testing.T) {
 	repoPath := "/repo"
 	sources := []sourceFile{
-		{Name: "00-yaml", ApplyTo: "**/*.yaml", Body: []byte("# YAML\n")},
-		{Name: "01-shell", ApplyTo: "**/*.sh", Body: []byte("# Shell\n")},
+		{Name: "00-yaml", Scope: ruleScope{Trigger: triggerGlob, ApplyTo: []string{"**/*.yaml"}}, Body: []byte("# YAML\n")},
+		{Name: "01-shell", Scope: ruleScope{Trigger: triggerGlob, ApplyTo: []string{"**/*.sh"}}, Body: []byte("# Shell\n")},
 	}
 
-	plan := buildCopilotPlan(repoPath, ".promptherder/agent/rules", sources)
+	plan := buildCopilotPlan(repoPath, ".promptherder/agent/rules", sources, false)
 
 	if len(plan) != 2 {
 		t.Fatalf("expected 2 plan items, got %d", len(plan))
@@ -203,13 +223,26 @@ func TestBuildCopilotPlan_AllWithApplyTo(t *testing.T) {
 	assertTarget(t, plan[1], filepath.Join(repoPath, ".github", "instructions", "01-shell.instructions.md"))
 }
 
+func TestBuildCopilotPlan_MultipleGlobs(t *testing.T) {
+	sources := []sourceFile{
+		{Name: "web", Scope: ruleScope{Trigger: triggerGlob, ApplyTo: []string{"**/*.ts", "**/*.tsx"}}, Body: []byte("# Web\n")},
+	}
+
+	plan := buildCopilotPlan("/repo", ".promptherder/agent/rules", sources, false)
+
+	if len(plan) != 1 {
+		t.Fatalf("expected 1 plan item, got %d", len(plan))
+	}
+	assertContains(t, plan[0].Content, `applyTo: "**/*.ts,**/*.tsx"`)
+}
+
 func TestBuildCopilotPlan_GeneratedHeaders(t *testing.T) {
 	repoPath := "/repo"
 	sources := []sourceFile{
-		{Name: "00-general", Body: []byte("# Rules\n")},
+		{Name: "00-general", Scope: ruleScope{Trigger: triggerAlways}, Body: []byte("# Rules\n")},
 	}
 
-	plan := buildCopilotPlan(repoPath, ".promptherder/agent/rules", sources)
+	plan := buildCopilotPlan(repoPath, ".promptherder/agent/rules", sources, false)
 
 	if len(plan) != 1 {
 		t.Fatalf("expected 1 plan item, got %d", len(plan))
@@ -218,6 +251,49 @@ func TestBuildCopilotPlan_GeneratedHeaders(t *testing.T) {
 	assertContains(t, plan[0].Content, "do not edit")
 }
 
+func TestBuildCopilotPlan_Sections(t *testing.T) {
+	repoPath := "/repo"
+	sources := []sourceFile{
+		{Name: "hard-rules", Path: "/repo/.promptherder/hard-rules.md", Scope: ruleScope{Trigger: triggerAlways}, Body: []byte("# Hard\n")},
+		{Name: "compound-v", Path: "/repo/.promptherder/agent/rules/compound-v.md", Herd: "compound-v", Scope: ruleScope{Trigger: triggerAlways}, Body: []byte("# Compound V\n")},
+		{Name: "shell", Path: "/repo/.promptherder/agent/rules/shell.md", Scope: ruleScope{Trigger: triggerGlob, ApplyTo: []string{"**/*.sh"}}, Body: []byte("# Shell\n")},
+	}
+
+	plan := buildCopilotPlan(repoPath, ".promptherder/agent/rules", sources, true)
+
+	want := "<!-- Auto-generated by promptherder from .promptherder/agent/rules/ — do not edit -->\n" +
+		"<!-- Contents:\n" +
+		"  1. hard-rules — .promptherder/hard-rules.md\n" +
+		"  2. compound-v — .promptherder/agent/rules/compound-v.md (herd compound-v)\n" +
+		"-->\n" +
+		"\n<!-- begin 1. hard-rules — .promptherder/hard-rules.md -->\n# Hard\n<!-- end 1. hard-rules -->\n" +
+		"\n<!-- begin 2. compound-v — .promptherder/agent/rules/compound-v.md (herd compound-v) -->\n# Compound V\n<!-- end 2. compound-v -->\n"
+	if got := string(plan[0].Content); got != want {
+		t.Errorf("copilot-instructions.md =\n%s\nwant\n%s", got, want)
+	}
+	assertNotContains(t, plan[1].Content, "<!-- begin")
+}
+
+func TestCopilotTarget_SectionsNameHerds(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, ".promptherder/herds/compound-v/herd.json", `{"name":"compound-v"}`)
+	createTestFile(t, dir, ".promptherder/herds/compound-v/rules/compound-v.md", "# Compound V\n")
+	createTestFile(t, dir, ".promptherder/agent/rules/compound-v.md", "# Compound V\n")
+	createTestFile(t, dir, ".promptherder/agent/rules/local.md", "# Local\n")
+
+	cfg := TargetConfig{RepoPath: dir, Logger: testLogger(t), Settings: Settings{SectionHeaders: true}}
+	if _, err := (CopilotTarget{}).Install(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, ".github", "copilot-instructions.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, data, "1. compound-v — .promptherder/agent/rules/compound-v.md (herd compound-v)\n")
+	assertContains(t, data, "2. local — .promptherder/agent/rules/local.md\n")
+}
+
 // --- RunCopilot integration ---
 
 func TestRunCopilot_DryRun(t *testing.T) {
@@ -356,7 +432,7 @@ func TestCleanStale_RemovesStaleFromOldManifest(t *testing.T) {
 	}
 	new := manifest{Version: 2}
 
-	err := cleanStale(dir, old, new, false, logger)
+	err := cleanStale(dir, old, new, nil, false, logger)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -385,7 +461,7 @@ func TestCleanStale_KeepsFilesInBothManifests(t *testing.T) {
 	cur := manifest{Version: 2}
 	cur.setTarget("copilot", []string{".github/instructions/01-shell.instructions.md"})
 
-	err := cleanStale(dir, old, cur, false, logger)
+	err := cleanStale(dir, old, cur, nil, false, logger)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -408,7 +484,7 @@ func TestCleanStale_DryRunDoesNotDelete(t *testing.T) {
 	old := manifest{Version: 1, Files: []string{".github/instructions/old-rule.instructions.md"}}
 	new := manifest{Version: 2}
 
-	err := cleanStale(dir, old, new, true, logger)
+	err := cleanStale(dir, old, new, nil, true, logger)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -422,7 +498,7 @@ func TestCleanStale_EmptyOldManifest(t *testing.T) {
 	dir := t.TempDir()
 	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
 
-	err := cleanStale(dir, manifest{}, manifest{}, false, logger)
+	err := cleanStale(dir, manifest{}, manifest{}, nil, false, logger)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -446,8 +522,8 @@ func TestManifest_RoundTrip(t *testing.T) {
 
 	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
 	got := readManifest(dir, logger)
-	if got.Version != 2 {
-		t.Errorf("version = %d, want 2", got.Version)
+	if got.Version != manifestVersion {
+		t.Errorf("version = %d, want %d", got.Version, manifestVersion)
 	}
 	copilotFiles := got.Targets["copilot"]
 	if len(copilotFiles) != 2 {
@@ -519,8 +595,8 @@ func TestRunCopilot_WritesManifest(t *testing.T) {
 	}
 
 	m := readManifest(dir, slog.New(slog.NewTextHandler(os.Stderr, nil)))
-	if m.Version != 2 {
-		t.Errorf("manifest version = %d, want 2", m.Version)
+	if m.Version != manifestVersion {
+		t.Errorf("manifest version = %d, want %d", m.Version, manifestVersion)
 	}
 	copilotFiles := m.Targets["copilot"]
 	if len(copilotFiles) != 1 {
@@ -605,7 +681,10 @@ func TestRunCopilot_IdempotentCleanup(t *testing.T) {
 
 func TestConvertWorkflowToPrompt_Basic(t *testing.T) {
 	input := []byte("---\ndescription: Run a review pass.\n---\n\n# Review\n\nDo the review.\n")
-	result := convertWorkflowToPrompt("test/workflows", "review.md", input)
+	result, err := convertWorkflowToPrompt("test/workflows", "review.md", input)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	assertContains(t, result, `mode: "agent"`)
 	assertContains(t, result, `description: "Run a review pass."`)
@@ -616,7 +695,10 @@ func TestConvertWorkflowToPrompt_Basic(t *testing.T) {
 
 func TestConvertWorkflowToPrompt_StripsTurbo(t *testing.T) {
 	input := []byte("---\ndescription: Execute plan.\n---\n\n// turbo-all\n\n# Execute\n\nDo stuff.\n")
-	result := convertWorkflowToPrompt("test/workflows", "execute.md", input)
+	result, err := convertWorkflowToPrompt("test/workflows", "execute.md", input)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	assertNotContains(t, result, "// turbo-all")
 	assertContains(t, result, "# Execute")
@@ -635,19 +717,29 @@ func TestStripAntigravityAnnotations(t *testing.T) {
 	}
 }
 
-func TestExtractDescription(t *testing.T) {
-	input := []byte("---\ndescription: My workflow.\n---\n# Body\n")
-	desc := extractDescription(input)
-	if desc != "My workflow." {
-		t.Errorf("desc = %q, want %q", desc, "My workflow.")
+func TestConvertWorkflowToPrompt_FoldedDescription(t *testing.T) {
+	input := []byte("---\ndescription: >\n  Plan the work,\n  then execute it.\n---\n# Body\n")
+	result, err := convertWorkflowToPrompt("test/workflows", "plan.md", input)
+	if err != nil {
+		t.Fatal(err)
 	}
+	assertContains(t, result, `description: "Plan the work, then execute it."`)
 }
 
-func TestExtractDescription_NoFrontmatter(t *testing.T) {
+func TestConvertWorkflowToPrompt_NoFrontmatter(t *testing.T) {
 	input := []byte("# Just body\n")
-	desc := extractDescription(input)
-	if desc != "" {
-		t.Errorf("desc = %q, want empty", desc)
+	result, err := convertWorkflowToPrompt("test/workflows", "plain.md", input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertNotContains(t, result, "description:")
+}
+
+func TestConvertWorkflowToPrompt_InvalidFrontmatter(t *testing.T) {
+	input := []byte("---\ndescription: [unclosed\n---\n# Body\n")
+	_, err := convertWorkflowToPrompt("test/workflows", "bad.md", input)
+	if !errors.Is(err, ErrValidation) || !strings.Contains(err.Error(), "bad.md") {
+		t.Errorf("expected validation error naming the file, got %v", err)
 	}
 }
 
@@ -658,7 +750,7 @@ func TestBuildCopilotPrompts_Basic(t *testing.T) {
 	mustWrite(t, filepath.Join(wfDir, "brainstorm.md"), "---\ndescription: Brainstorm.\n---\n\n# Brainstorm\n\nDo it.\n")
 	mustWrite(t, filepath.Join(wfDir, "review.md"), "---\ndescription: Review.\n---\n\n# Review\n\nCheck.\n")
 
-	items, err := buildCopilotPrompts(dir, DefaultSettings())
+	items, err := buildCopilotPrompts(dir, DefaultSettings(), sourceRenderer{target: copilotName})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -673,7 +765,7 @@ func TestBuildCopilotPrompts_Basic(t *testing.T) {
 
 func TestBuildCopilotPrompts_MissingDir(t *testing.T) {
 	dir := t.TempDir()
-	items, err := buildCopilotPrompts(dir, DefaultSettings())
+	items, err := buildCopilotPrompts(dir, DefaultSettings(), sourceRenderer{target: copilotName})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -737,7 +829,7 @@ func TestBuildCopilotSkillPrompts_Basic(t *testing.T) {
 	mustMkdir(t, skillDir)
 	mustWrite(t, filepath.Join(skillDir, "SKILL.md"), "---\nname: compound-v-tdd\ndescription: TDD skill.\n---\n\n# TDD\n\nRed green refactor.\n")
 
-	items, err := buildCopilotSkillPrompts(dir)
+	items, err := buildCopilotSkillPrompts(dir, sourceRenderer{target: copilotName})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -754,7 +846,7 @@ func TestBuildCopilotSkillPrompts_Basic(t *testing.T) {
 
 func TestBuildCopilotSkillPrompts_MissingDir(t *testing.T) {
 	dir := t.TempDir()
-	items, err := buildCopilotSkillPrompts(dir)
+	items, err := buildCopilotSkillPrompts(dir, sourceRenderer{target: copilotName})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -769,7 +861,7 @@ func TestBuildCopilotSkillPrompts_SkipsDirWithoutSKILLmd(t *testing.T) {
 	mustMkdir(t, skillDir)
 	mustWrite(t, filepath.Join(skillDir, "README.md"), "# Not a skill\n")
 
-	items, err := buildCopilotSkillPrompts(dir)
+	items, err := buildCopilotSkillPrompts(dir, sourceRenderer{target: copilotName})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -828,7 +920,7 @@ func TestBuildCopilotSkillPrompts_PrefersCopilotVariant(t *testing.T) {
 	mustWrite(t, filepath.Join(skillDir, "SKILL.md"), "---\nname: my-skill\ndescription: Generic skill.\n---\n\n# Generic\n\nGeneric content.\n")
 	mustWrite(t, filepath.Join(skillDir, "COPILOT.md"), "---\nname: my-skill\ndescription: Copilot skill.\n---\n\n# Copilot\n\nCopilot-specific content.\n")
 
-	items, err := buildCopilotSkillPrompts(dir)
+	items, err := buildCopilotSkillPrompts(dir, sourceRenderer{target: copilotName})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -852,7 +944,7 @@ func TestBuildCopilotSkillPrompts_FallsBackToGeneric(t *testing.T) {
 	mustMkdir(t, skillDir)
 	mustWrite(t, filepath.Join(skillDir, "SKILL.md"), "---\nname: my-skill\ndescription: Generic skill.\n---\n\n# Generic\n\nGeneric content.\n")
 
-	items, err := buildCopilotSkillPrompts(dir)
+	items, err := buildCopilotSkillPrompts(dir, sourceRenderer{target: copilotName})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -873,7 +965,7 @@ func TestBuildCopilotSkillPrompts_IgnoresAntigravityVariant(t *testing.T) {
 	mustWrite(t, filepath.Join(skillDir, "SKILL.md"), "---\nname: my-skill\ndescription: Generic.\n---\n\n# Generic\n")
 	mustWrite(t, filepath.Join(skillDir, "ANTIGRAVITY.md"), "---\nname: my-skill\ndescription: Antigravity.\n---\n\n# Antigravity\n")
 
-	items, err := buildCopilotSkillPrompts(dir)
+	items, err := buildCopilotSkillPrompts(dir, sourceRenderer{target: copilotName})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -968,3 +1060,63 @@ func TestCopilotTarget_NoHardRules(t *testing.T) {
 		t.Error("should not contain hard-rules reference when file is missing")
 	}
 }
+
+func TestCopilotTarget_SkillAssets(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, ".promptherder/agent/skills/release/SKILL.md",
+		"---\nname: release\n---\n# Release\n\nRun [the check](scripts/check.sh), then follow [the checklist](./checklist.md#steps).\nSee [docs](https://example.com) and [missing](nope.md).\n")
+	createTestFile(t, dir, ".promptherder/agent/skills/release/ANTIGRAVITY.md", "# Antigravity variant\n")
+	createTestFile(t, dir, ".promptherder/agent/skills/release/scripts/check.sh", "#!/bin/sh\nexit 0\n")
+	createTestFile(t, dir, ".promptherder/agent/skills/release/checklist.md", "## Steps\n\n- Run `{{.TestCommand}}`.\n")
+	createTestFile(t, dir, ".promptherder/agent/skills/release/.DS_Store", "junk")
+
+	cfg := Config{RepoPath: dir, Logger: testLogger(t)}
+	createTestFile(t, dir, filepath.Join(manifestDir, settingsFile), `{"test_command": "make check"}`)
+	if err := RunTarget(context.Background(), CopilotTarget{}, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	prompt, err := os.ReadFile(filepath.Join(dir, ".github", "prompts", "release.prompt.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, prompt, "[the check](release/scripts/check.sh)")
+	assertContains(t, prompt, "[the checklist](release/checklist.md#steps)")
+	assertContains(t, prompt, "[docs](https://example.com)")
+	assertContains(t, prompt, "[missing](nope.md)")
+
+	script, err := os.ReadFile(filepath.Join(dir, ".github", "prompts", "release", "scripts", "check.sh"))
+	if err != nil {
+		t.Fatal("script asset should be copied next to the prompt")
+	}
+	if string(script) != "#!/bin/sh\nexit 0\n" {
+		t.Errorf("script = %q, want a verbatim copy", script)
+	}
+	checklist, err := os.ReadFile(filepath.Join(dir, ".github", "prompts", "release", "checklist.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, checklist, "Run `make check`.")
+	for _, skipped := range []string{"ANTIGRAVITY.md", "SKILL.md", ".DS_Store"} {
+		if _, err := os.Stat(filepath.Join(dir, ".github", "prompts", "release", skipped)); err == nil {
+			t.Errorf("%s should not be copied as an asset", skipped)
+		}
+	}
+
+	m := readManifest(dir, testLogger(t))
+	if !slices.Contains(m.Targets["copilot"], ".github/prompts/release/scripts/check.sh") {
+		t.Errorf("manifest should track skill assets, got %v", m.Targets["copilot"])
+	}
+
+	// Removing an asset from the source cleans up its copy.
+	if err := os.Remove(filepath.Join(dir, ".promptherder", "agent", "skills", "release", "scripts", "check.sh")); err != nil {
+		t.Fatal(err)
+	}
+	if err := RunTarget(context.Background(), CopilotTarget{}, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".github", "prompts", "release", "scripts", "check.sh")); !os.IsNotExist(err) {
+		t.Error("stale skill asset should be removed")
+	}
+}
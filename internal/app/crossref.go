@@ -0,0 +1,184 @@
+package app
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// outputMap maps a source's repo-relative slash path (a file, or a skill
+// directory) to the repo-relative path a target writes it to.
+type outputMap map[string]string
+
+// rewriteRefs points links in data, the rendered source at label, at what
+// the target emits, as seen from out (data's repo-relative output path).
+// Links to other sources go to their outputs; links to any other repo file
+// are re-anchored; links that resolve to nothing are logged and left alone.
+// Agent-relative mentions in prose, like `skills/x/SKILL.md`, are replaced
+// with the output's repo-relative path. Without outputs data is unchanged.
+func (r sourceRenderer) rewriteRefs(data []byte, label, out string) []byte {
+	if r.outputs == nil {
+		return data
+	}
+	srcDir, outDir := path.Dir(label), path.Dir(out)
+	data = rewriteLinks(data, func(dest string) (string, bool) {
+		p, suffix, ok := splitLinkDest(dest)
+		if !ok {
+			return "", false
+		}
+		target := path.Join(srcDir, p)
+		if o, ok := r.outputs[target]; ok {
+			return relLink(outDir, o) + suffix, true
+		}
+		if target != ".." && !strings.HasPrefix(target, "../") {
+			if _, err := os.Stat(filepath.Join(r.repoPath, filepath.FromSlash(target))); err == nil {
+				return relLink(outDir, target) + suffix, true
+			}
+		}
+		if r.logger != nil {
+			r.logger.Warn("broken link", "file", label, "link", dest)
+		}
+		return "", false
+	})
+	return rewriteMentions(data, func(p string) (string, bool) {
+		if !strings.HasPrefix(p, agentDir+"/") {
+			p = agentDir + "/" + p
+		}
+		o, ok := r.outputs[p]
+		return o, ok
+	})
+}
+
+// copilotOutputs maps every source the Copilot target emits: rules (given
+// already parsed, hard-rules.md included), workflows, skills and their
+// assets.
+func copilotOutputs(repoPath string, settings Settings, rules []sourceFile) (outputMap, error) {
+	outputs := outputMap{}
+	for _, s := range rules {
+		rel, err := filepath.Rel(repoPath, s.Path)
+		if err != nil {
+			continue
+		}
+		outputs[filepath.ToSlash(rel)] = copilotRuleOutput(s)
+	}
+
+	if entries, err := os.ReadDir(filepath.Join(repoPath, filepath.FromSlash(workflowSourceDir))); err == nil {
+		for _, e := range entries {
+			if e.IsDir() || !strings.HasSuffix(e.Name(), ".md") {
+				continue
+			}
+			stem := strings.TrimSuffix(e.Name(), ".md")
+			outputs[workflowSourceDir+"/"+e.Name()] = copilotPromptsDir + "/" + settings.PrefixCommand(stem+".prompt.md")
+		}
+	}
+
+	skillsRoot := filepath.Join(repoPath, filepath.FromSlash(skillSourceDir))
+	entries, err := os.ReadDir(skillsRoot)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return outputs, nil
+		}
+		return nil, err
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		skillDir := filepath.Join(skillsRoot, e.Name())
+		src := skillSourceDir + "/" + e.Name()
+		prompt := copilotPromptsDir + "/" + e.Name() + ".prompt.md"
+		found := false
+		for _, name := range []string{"SKILL.md", "COPILOT.md"} {
+			if fileExists(filepath.Join(skillDir, name)) {
+				outputs[src+"/"+name] = prompt
+				found = true
+			}
+		}
+		if !found {
+			continue
+		}
+		outputs[src] = prompt
+		assets, err := skillAssets(skillDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range assets {
+			outputs[src+"/"+a] = copilotPromptsDir + "/" + e.Name() + "/" + a
+		}
+	}
+	return outputs, nil
+}
+
+// copilotRuleOutput is the repo-relative file a rule is written to. Rules
+// of nested packages are named after the package.
+func copilotRuleOutput(s sourceFile) string {
+	if s.Scope.Trigger == triggerAlways && !s.Local {
+		return copilotTarget
+	}
+	if s.Package != "" {
+		return copilotInstDir + "/" + packageSlug(s.Package) + "-" + s.Name + ".instructions.md"
+	}
+	return copilotInstDir + "/" + s.Name + ".instructions.md"
+}
+
+// antigravityOutputs maps every source the Antigravity target emits, plus
+// each skill directory. Every file of a skill's SKILL.md family maps to the
+// one that is installed.
+func antigravityOutputs(repoPath string, settings Settings) (outputMap, error) {
+	srcRoot := filepath.Join(repoPath, filepath.FromSlash(antigravitySource))
+	outputs := outputMap{}
+	err := filepath.Walk(srcRoot, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && p == filepath.Join(srcRoot, partialsDir) {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(srcRoot, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		relSlash := filepath.ToSlash(rel)
+		if info.IsDir() {
+			if isInSkillDir(relSlash + "/") {
+				outputs[antigravitySource+"/"+relSlash] = antigravityTarget + "/" + relSlash
+			}
+			return nil
+		}
+		out, ok := antigravityOutput(srcRoot, relSlash, settings)
+		if !ok {
+			// A skipped skill file still names the skill that is installed.
+			out = path.Dir(relSlash) + "/SKILL.md"
+		}
+		outputs[antigravitySource+"/"+relSlash] = antigravityTarget + "/" + out
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if fileExists(filepath.Join(repoPath, filepath.FromSlash(hardRulesFile))) {
+		outputs[hardRulesFile] = antigravityTarget + "/rules/hard-rules.md"
+	}
+	return outputs, nil
+}
+
+// brokenLinks returns the relative link destinations in data, the file at
+// label (a slash path under dir), that resolve to nothing under dir.
+func brokenLinks(data []byte, label, dir string) []string {
+	var broken []string
+	rewriteLinks(data, func(dest string) (string, bool) {
+		p, _, ok := splitLinkDest(dest)
+		if !ok {
+			return "", false
+		}
+		target := path.Join(path.Dir(label), p)
+		if target == ".." || strings.HasPrefix(target, "../") {
+			broken = append(broken, dest)
+		} else if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(target))); err != nil {
+			broken = append(broken, dest)
+		}
+		return "", false
+	})
+	return broken
+}
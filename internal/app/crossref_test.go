@@ -0,0 +1,107 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// crossRefRepo creates sources that link to each other, with the command
+// prefix enabled.
+func crossRefRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	createTestFile(t, dir, filepath.Join(manifestDir, settingsFile), `{"command_prefix": "v-", "command_prefix_enabled": true}`)
+	createTestFile(t, dir, ".promptherder/agent/rules/core.md",
+		"# Core\n\nStart with [plan](../workflows/plan.md); see skills/tdd/SKILL.md.\n")
+	createTestFile(t, dir, ".promptherder/agent/rules/go.md",
+		"---\napplyTo: \"**/*.go\"\n---\nFollow [core](core.md) and [the guide](../../../docs/guide.md#style).\n")
+	createTestFile(t, dir, ".promptherder/agent/workflows/plan.md",
+		"---\ndescription: Plan\n---\nUse [tdd](../skills/tdd/SKILL.md), then [execute](execute.md). Ignore [gone](missing.md).\n")
+	createTestFile(t, dir, ".promptherder/agent/workflows/execute.md",
+		"---\ndescription: Execute\n---\nBack to [plan](./plan.md#steps).\n")
+	createTestFile(t, dir, ".promptherder/agent/skills/tdd/SKILL.md",
+		"---\nname: tdd\n---\nRun [the check](scripts/check.sh) after `workflows/plan.md`.\n")
+	createTestFile(t, dir, ".promptherder/agent/skills/tdd/scripts/check.sh", "#!/bin/sh\n")
+	createTestFile(t, dir, "docs/guide.md", "# Guide\n")
+	return dir
+}
+
+func readOutput(t *testing.T, dir, rel string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(rel)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestCopilotTarget_CrossReferences(t *testing.T) {
+	t.Parallel()
+	dir := crossRefRepo(t)
+	var logs bytes.Buffer
+	cfg := Config{RepoPath: dir, Logger: slog.New(slog.NewTextHandler(&logs, nil))}
+	if err := RunTarget(context.Background(), CopilotTarget{}, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	instructions := readOutput(t, dir, ".github/copilot-instructions.md")
+	assertContains(t, instructions, "[plan](prompts/v-plan.prompt.md)")
+	assertContains(t, instructions, "see .github/prompts/tdd.prompt.md.")
+
+	goRule := readOutput(t, dir, ".github/instructions/go.instructions.md")
+	assertContains(t, goRule, "[core](../copilot-instructions.md)")
+	assertContains(t, goRule, "[the guide](../../docs/guide.md#style)")
+
+	plan := readOutput(t, dir, ".github/prompts/v-plan.prompt.md")
+	assertContains(t, plan, "[tdd](tdd.prompt.md)")
+	assertContains(t, plan, "[execute](v-execute.prompt.md)")
+	assertContains(t, plan, "[gone](missing.md)")
+	assertContains(t, readOutput(t, dir, ".github/prompts/v-execute.prompt.md"), "[plan](v-plan.prompt.md#steps)")
+
+	skill := readOutput(t, dir, ".github/prompts/tdd.prompt.md")
+	assertContains(t, skill, "[the check](tdd/scripts/check.sh)")
+	assertContains(t, skill, "after `.github/prompts/v-plan.prompt.md`")
+
+	if !strings.Contains(logs.String(), "broken link") || !strings.Contains(logs.String(), "link=missing.md") {
+		t.Errorf("expected a broken link warning, got:\n%s", logs.String())
+	}
+}
+
+func TestAntigravityTarget_CrossReferences(t *testing.T) {
+	t.Parallel()
+	dir := crossRefRepo(t)
+	createTestFile(t, dir, ".promptherder/agent/skills/tdd/ANTIGRAVITY.md",
+		"---\nname: tdd\n---\nBack to [plan](../../workflows/plan.md) and the generic [skill](SKILL.md).\n")
+	cfg := Config{RepoPath: dir, Logger: testLogger(t)}
+	if err := RunTarget(context.Background(), AntigravityTarget{}, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	core := readOutput(t, dir, ".agent/rules/core.md")
+	assertContains(t, core, "[plan](../workflows/v-plan.md)")
+	assertContains(t, core, "see .agent/skills/tdd/SKILL.md.")
+	assertContains(t, readOutput(t, dir, ".agent/rules/go.md"), "[the guide](../../docs/guide.md#style)")
+
+	plan := readOutput(t, dir, ".agent/workflows/v-plan.md")
+	assertContains(t, plan, "[tdd](../skills/tdd/SKILL.md)")
+	assertContains(t, plan, "[execute](v-execute.md)")
+	assertContains(t, readOutput(t, dir, ".agent/workflows/v-execute.md"), "[plan](v-plan.md#steps)")
+
+	skill := readOutput(t, dir, ".agent/skills/tdd/SKILL.md")
+	assertContains(t, skill, "[plan](../../workflows/v-plan.md)")
+	assertContains(t, skill, "[skill](SKILL.md)")
+}
+
+func TestRewriteRefs_WithoutOutputs(t *testing.T) {
+	t.Parallel()
+	in := []byte("[plan](../workflows/plan.md) and skills/tdd/SKILL.md\n")
+	r := sourceRenderer{target: copilotName}
+	if got := r.rewriteRefs(in, ".promptherder/agent/rules/a.md", copilotTarget); !bytes.Equal(got, in) {
+		t.Errorf("got %q, want input unchanged", got)
+	}
+}
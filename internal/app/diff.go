@@ -0,0 +1,196 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines around each hunk.
+const diffContext = 3
+
+// maxDiffCells bounds the LCS table; larger changes are shown as a
+// replacement of the whole changed region.
+const maxDiffCells = 4 << 20
+
+// diffLine is one line of an edit script: ' ' kept, '-' removed, '+' added.
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// splitLines splits data into lines, keeping their newlines.
+func splitLines(data []byte) []string {
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns an edit script turning a into b: the common prefix and
+// suffix are kept, and the rest is matched by longest common subsequence.
+func diffLines(a, b []string) []diffLine {
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+
+	var script []diffLine
+	for _, l := range a[:pre] {
+		script = append(script, diffLine{' ', l})
+	}
+	script = append(script, lcsScript(a[pre:len(a)-suf], b[pre:len(b)-suf])...)
+	for _, l := range a[len(a)-suf:] {
+		script = append(script, diffLine{' ', l})
+	}
+	return script
+}
+
+func lcsScript(a, b []string) []diffLine {
+	var script []diffLine
+	if len(a)*len(b) > maxDiffCells {
+		for _, l := range a {
+			script = append(script, diffLine{'-', l})
+		}
+		for _, l := range b {
+			script = append(script, diffLine{'+', l})
+		}
+		return script
+	}
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:].
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			script = append(script, diffLine{' ', a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			script = append(script, diffLine{'-', a[i]})
+			i++
+		default:
+			script = append(script, diffLine{'+', b[j]})
+			j++
+		}
+	}
+	return script
+}
+
+// unifiedDiff returns a unified diff from old to new for the file at rel,
+// or "" if they are equal. A nil old or new is a created or deleted file.
+func unifiedDiff(rel string, old, new []byte) string {
+	script := diffLines(splitLines(old), splitLines(new))
+
+	// before[k] counts the old and new lines ahead of script[k].
+	type counts struct{ a, b int }
+	before := make([]counts, len(script)+1)
+	for k, l := range script {
+		before[k+1] = before[k]
+		if l.op != '+' {
+			before[k+1].a++
+		}
+		if l.op != '-' {
+			before[k+1].b++
+		}
+	}
+
+	var out strings.Builder
+	for k := 0; k < len(script); {
+		for k < len(script) && script[k].op == ' ' {
+			k++
+		}
+		if k == len(script) {
+			break
+		}
+		// Extend the hunk while the next change is close enough to share context.
+		last := k
+		for j := k; j < len(script); j++ {
+			if script[j].op != ' ' {
+				if j-last > 2*diffContext {
+					break
+				}
+				last = j
+			}
+		}
+		start, end := max(k-diffContext, 0), min(last+diffContext+1, len(script))
+
+		if out.Len() == 0 {
+			oldName, newName := "a/"+rel, "b/"+rel
+			if old == nil {
+				oldName = "/dev/null"
+			}
+			if new == nil {
+				newName = "/dev/null"
+			}
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n",
+			hunkRange(before[start].a, before[end].a-before[start].a),
+			hunkRange(before[start].b, before[end].b-before[start].b))
+		for _, l := range script[start:end] {
+			out.WriteByte(l.op)
+			out.WriteString(l.text)
+			if !strings.HasSuffix(l.text, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		k = end
+	}
+	return out.String()
+}
+
+// hunkRange formats the start,count of a hunk side; an empty side starts at
+// the line before it.
+func hunkRange(before, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	if count == 1 {
+		return fmt.Sprint(before + 1)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
+
+// writeDiffs prints a unified diff for each change in rec: its recorded
+// content against the file on disk, and stale files against nothing.
+func writeDiffs(w io.Writer, repoPath string, rec *outputRecorder) error {
+	for _, c := range rec.changes {
+		var old, new []byte
+		if c.Kind != ChangeCreate {
+			data, err := os.ReadFile(filepath.Join(repoPath, filepath.FromSlash(c.Path)))
+			if err != nil {
+				return fmt.Errorf("read %s: %w", c.Path, err)
+			}
+			old = data
+		}
+		if c.Kind != ChangeDelete {
+			new = rec.outputs[c.Path]
+		}
+		if _, err := io.WriteString(w, unifiedDiff(c.Path, old, new)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		old, new []byte
+		want     string
+	}{
+		{"equal", []byte("a\nb\n"), []byte("a\nb\n"), ""},
+		{
+			"changed line",
+			[]byte("a\nb\nc\n"), []byte("a\nB\nc\n"),
+			"--- a/f.md\n+++ b/f.md\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			"distant changes get separate hunks",
+			[]byte("1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"), []byte("one\n2\n3\n4\n5\n6\n7\n8\n9\nten\n"),
+			"--- a/f.md\n+++ b/f.md\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+ten\n",
+		},
+		{
+			"created",
+			nil, []byte("a\n"),
+			"--- /dev/null\n+++ b/f.md\n@@ -0,0 +1 @@\n+a\n",
+		},
+		{
+			"deleted",
+			[]byte("a\nb\n"), nil,
+			"--- a/f.md\n+++ /dev/null\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			"missing final newline",
+			[]byte("a\n"), []byte("a\nb"),
+			"--- a/f.md\n+++ b/f.md\n@@ -1 +1,2 @@\n a\n+b\n\\ No newline at end of file\n",
+		},
+	}
+	for _, tt := range tests {
+		if got := unifiedDiff("f.md", tt.old, tt.new); got != tt.want {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRunAll_Diff(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, ".promptherder/agent/rules/general.md", "# General\n\nKeep it short.\n")
+	createTestFile(t, dir, ".promptherder/agent/rules/shell.md", "---\napplyTo: \"**/*.sh\"\n---\n# Shell\n")
+	createTestFile(t, dir, ".promptherder/herds/team/herd.json", `{"name":"team"}`)
+	createTestFile(t, dir, ".promptherder/herds/team/rules/team.md", "# Team\n")
+	targets := []Target{CopilotTarget{}, AntigravityTarget{}}
+	cfg := Config{RepoPath: dir, Logger: testLogger(t)}
+	if err := RunAll(context.Background(), targets, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	createTestFile(t, dir, ".promptherder/agent/rules/general.md", "# General\n\nKeep it very short.\n")
+	createTestFile(t, dir, ".promptherder/herds/team/rules/team.md", "# Team v2\n")
+	if err := os.Remove(filepath.Join(dir, ".promptherder", "agent", "rules", "shell.md")); err != nil {
+		t.Fatal(err)
+	}
+	before := readOutput(t, dir, ".github/copilot-instructions.md")
+
+	var out bytes.Buffer
+	cfg.DryRun, cfg.Diff = true, &out
+	if err := RunAll(context.Background(), targets, cfg); err != nil {
+		t.Fatal(err)
+	}
+	diff := out.String()
+	for _, want := range []string{
+		"--- a/.github/copilot-instructions.md\n+++ b/.github/copilot-instructions.md\n",
+		"-Keep it short.\n+Keep it very short.\n",
+		"--- a/.agent/rules/general.md\n+++ b/.agent/rules/general.md\n",
+		"--- a/.agent/rules/shell.md\n+++ /dev/null\n",
+		"--- a/.github/instructions/shell.instructions.md\n+++ /dev/null\n",
+		"--- a/.promptherder/agent/rules/team.md\n+++ b/.promptherder/agent/rules/team.md\n@@ -1 +1 @@\n-# Team\n+# Team v2\n",
+	} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("diff should contain %q, got:\n%s", want, diff)
+		}
+	}
+
+	if got := readOutput(t, dir, ".github/copilot-instructions.md"); !bytes.Equal(got, before) {
+		t.Error("a diff run should not write outputs")
+	}
+	if !fileExists(filepath.Join(dir, ".agent", "rules", "shell.md")) {
+		t.Error("a diff run should not delete stale outputs")
+	}
+	assertContains(t, readOutput(t, dir, ".promptherder/agent/rules/team.md"), "# Team\n")
+}
+
+func TestRunTarget_Diff(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, ".promptherder/agent/rules/general.md", "# General\n")
+	var out bytes.Buffer
+	cfg := Config{RepoPath: dir, DryRun: true, Diff: &out, Logger: testLogger(t)}
+	if err := RunTarget(context.Background(), AntigravityTarget{}, cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, out.Bytes(), "--- /dev/null\n+++ b/.agent/rules/general.md\n")
+	if fileExists(filepath.Join(dir, ".agent", "rules", "general.md")) {
+		t.Error("a diff run should not write outputs")
+	}
+}
@@ -0,0 +1,152 @@
+package app
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Drift policies for settings drift_policy: what a sync does with an output
+// that was edited by hand after promptherder wrote it.
+const (
+	driftRefuse = "refuse" // leave the file alone and fail the run (default)
+	driftWarn   = "warn"   // overwrite or delete it with a warning
+	driftBackup = "backup" // copy it to .promptherder/backup/ first
+)
+
+// backupDir holds copies of hand-edited outputs under the backup policy.
+const backupDir = manifestDir + "/backup"
+
+// ErrDrift is returned (wrapped with ErrValidation) when the refuse policy
+// kept hand-edited outputs from being overwritten.
+var ErrDrift = fmt.Errorf("outputs edited by hand: %w", ErrValidation)
+
+// fileHash is the content hash recorded in the manifest.
+func fileHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// driftGuard checks outputs against the hashes of the previous manifest
+// before they are overwritten or deleted. A nil *driftGuard applies the
+// refuse policy to deletions and doesn't check writes.
+type driftGuard struct {
+	repoPath string
+	hashes   map[string]string // repo-relative path → hash at the last sync
+	policy   string
+	force    bool // overwrite and delete regardless of hand edits
+	dryRun   bool
+	logger   *slog.Logger
+	kept     []string // outputs left alone under the refuse policy
+}
+
+// drifted reports whether the file at rel was changed since promptherder
+// last wrote it, and returns its current content.
+func drifted(repoPath, rel string, hashes map[string]string) (bool, []byte) {
+	want, tracked := hashes[rel]
+	if !tracked {
+		return false, nil
+	}
+	data, err := os.ReadFile(filepath.Join(repoPath, filepath.FromSlash(rel)))
+	if err != nil {
+		return false, nil
+	}
+	return fileHash(data) != want, data
+}
+
+// allow reports whether the output at rel may be overwritten with content.
+// Hand-edited files are overwritten with a warning, backed up first, or
+// kept, according to the policy.
+func (g *driftGuard) allow(rel string, content []byte) (bool, error) {
+	if g == nil || g.force {
+		return true, nil
+	}
+	changed, disk := drifted(g.repoPath, rel, g.hashes)
+	if !changed || bytes.Equal(disk, content) {
+		return true, nil
+	}
+	switch g.policy {
+	case driftWarn:
+		g.logger.Warn("overwriting file edited by hand", "file", rel)
+		return true, nil
+	case driftBackup:
+		return true, backupFile(g.repoPath, rel, disk, g.dryRun, g.logger)
+	default:
+		g.logger.Warn("file edited by hand — not overwritten", "file", rel)
+		g.kept = append(g.kept, rel)
+		return false, nil
+	}
+}
+
+// removable reports whether cleanStale may delete the stale file rel,
+// which prev tracked. A hand-edited file is kept under the refuse policy;
+// it is no longer tracked, so it is the user's from then on.
+func (g *driftGuard) removable(repoPath, rel string, prev manifest, dryRun bool, logger *slog.Logger) (bool, error) {
+	policy := driftRefuse
+	if g != nil {
+		if g.force {
+			return true, nil
+		}
+		policy = g.policy
+	}
+	changed, disk := drifted(repoPath, rel, prev.Hashes)
+	if !changed {
+		return true, nil
+	}
+	switch policy {
+	case driftWarn:
+		logger.Warn("removing stale file edited by hand", "file", rel)
+		return true, nil
+	case driftBackup:
+		return true, backupFile(repoPath, rel, disk, dryRun, logger)
+	default:
+		logger.Warn("stale file edited by hand — kept and no longer tracked", "file", rel)
+		return false, nil
+	}
+}
+
+// keepsStale reports whether cleanStale would keep the stale file rel
+// because it was edited by hand and the policy is refuse.
+func (g *driftGuard) keepsStale(repoPath, rel string, prev manifest) bool {
+	if g != nil && (g.force || g.policy == driftWarn || g.policy == driftBackup) {
+		return false
+	}
+	changed, _ := drifted(repoPath, rel, prev.Hashes)
+	return changed
+}
+
+// wasKept reports whether the refuse policy kept rel from being written.
+func (g *driftGuard) wasKept(rel string) bool {
+	return g != nil && slices.Contains(g.kept, rel)
+}
+
+// err returns ErrDrift naming the kept files, or nil.
+func (g *driftGuard) err() error {
+	if g == nil || len(g.kept) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s — move the changes into the sources, or rerun with -force (or set drift_policy to warn or backup): %w",
+		strings.Join(g.kept, ", "), ErrDrift)
+}
+
+// backupFile copies data, the hand-edited content of rel, to
+// .promptherder/backup/<rel>.<timestamp>.
+func backupFile(repoPath, rel string, data []byte, dryRun bool, logger *slog.Logger) error {
+	backupRel := backupDir + "/" + rel + "." + time.Now().UTC().Format("20060102T150405Z")
+	if dryRun {
+		logger.Info("dry-run: would back up file edited by hand", "file", rel, "backup", backupRel)
+		return nil
+	}
+	if err := writeFile(filepath.Join(repoPath, filepath.FromSlash(backupRel)), data); err != nil {
+		return fmt.Errorf("back up %s: %w", rel, err)
+	}
+	logger.Warn("file edited by hand — backed up before replacing", "file", rel, "backup", backupRel)
+	return nil
+}
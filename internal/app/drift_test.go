@@ -0,0 +1,160 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const instructionsRel = ".github/copilot-instructions.md"
+
+// driftRepo syncs a repo with one rule and then edits its output by hand.
+func driftRepo(t *testing.T, policy string) (string, Config) {
+	t.Helper()
+	dir := t.TempDir()
+	createTestFile(t, dir, ".promptherder/agent/rules/general.md", "# General\n")
+	if policy != "" {
+		createTestFile(t, dir, ".promptherder/settings.json", `{"drift_policy": "`+policy+`"}`)
+	}
+	cfg := Config{RepoPath: dir, Logger: testLogger(t)}
+	if err := RunAll(context.Background(), []Target{CopilotTarget{}}, cfg); err != nil {
+		t.Fatal(err)
+	}
+	m := readManifest(dir, testLogger(t))
+	if m.Hashes[instructionsRel] != fileHash(readOutput(t, dir, instructionsRel)) {
+		t.Fatalf("manifest hashes = %v", m.Hashes)
+	}
+	createTestFile(t, dir, instructionsRel, "# Edited by hand\n")
+	createTestFile(t, dir, ".promptherder/agent/rules/general.md", "# General, updated\n")
+	return dir, cfg
+}
+
+func TestRunAll_DriftRefuse(t *testing.T) {
+	t.Parallel()
+	dir, cfg := driftRepo(t, "")
+
+	err := RunAll(context.Background(), []Target{CopilotTarget{}}, cfg)
+	if !errors.Is(err, ErrDrift) || !errors.Is(err, ErrValidation) || !strings.Contains(err.Error(), instructionsRel) {
+		t.Fatalf("expected ErrDrift naming %s, got %v", instructionsRel, err)
+	}
+	assertContains(t, readOutput(t, dir, instructionsRel), "Edited by hand")
+
+	// The file stays tracked with its old hash, so the next run refuses too.
+	if err := RunAll(context.Background(), []Target{CopilotTarget{}}, cfg); !errors.Is(err, ErrDrift) {
+		t.Fatalf("second run: expected ErrDrift, got %v", err)
+	}
+
+	cfg.Force = true
+	if err := RunAll(context.Background(), []Target{CopilotTarget{}}, cfg); err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, readOutput(t, dir, instructionsRel), "General, updated")
+	cfg.Force = false
+	if err := RunAll(context.Background(), []Target{CopilotTarget{}}, cfg); err != nil {
+		t.Errorf("after -force the output is clean again, got %v", err)
+	}
+}
+
+func TestRunAll_DriftWarnAndBackup(t *testing.T) {
+	t.Parallel()
+	for _, policy := range []string{driftWarn, driftBackup} {
+		dir, cfg := driftRepo(t, policy)
+		if err := RunAll(context.Background(), []Target{CopilotTarget{}}, cfg); err != nil {
+			t.Fatalf("%s: %v", policy, err)
+		}
+		assertContains(t, readOutput(t, dir, instructionsRel), "General, updated")
+
+		backups, _ := filepath.Glob(filepath.Join(dir, filepath.FromSlash(backupDir), ".github", "copilot-instructions.md.*"))
+		if policy == driftBackup {
+			if len(backups) != 1 {
+				t.Fatalf("backup: got backups %v", backups)
+			}
+			assertContains(t, readOutput(t, dir, mustRel(t, dir, backups[0])), "Edited by hand")
+		} else if len(backups) != 0 {
+			t.Errorf("warn should not back up, got %v", backups)
+		}
+	}
+}
+
+func TestRunAll_DriftDryRun(t *testing.T) {
+	t.Parallel()
+	dir, cfg := driftRepo(t, "")
+	cfg.DryRun = true
+	if err := RunAll(context.Background(), []Target{CopilotTarget{}}, cfg); !errors.Is(err, ErrDrift) {
+		t.Errorf("dry run should report drift, got %v", err)
+	}
+	assertContains(t, readOutput(t, dir, instructionsRel), "Edited by hand")
+}
+
+func TestCleanStale_Drift(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		guard  *driftGuard
+		remove bool
+		backup bool
+	}{
+		{"nil guard refuses", nil, false, false},
+		{"refuse", &driftGuard{policy: driftRefuse}, false, false},
+		{"warn", &driftGuard{policy: driftWarn}, true, false},
+		{"backup", &driftGuard{policy: driftBackup}, true, true},
+		{"force", &driftGuard{force: true}, true, false},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		createTestFile(t, dir, ".github/instructions/old.instructions.md", "# Edited\n")
+		createTestFile(t, dir, ".github/instructions/clean.instructions.md", "# Clean\n")
+		prev := manifest{Targets: map[string][]string{"copilot": {
+			".github/instructions/clean.instructions.md",
+			".github/instructions/old.instructions.md",
+		}}, Hashes: map[string]string{
+			".github/instructions/clean.instructions.md": fileHash([]byte("# Clean\n")),
+			".github/instructions/old.instructions.md":   fileHash([]byte("# Generated\n")),
+		}}
+
+		if err := cleanStale(dir, prev, manifest{}, tt.guard, false, testLogger(t)); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if fileExists(filepath.Join(dir, ".github", "instructions", "clean.instructions.md")) {
+			t.Errorf("%s: unmodified stale file should be removed", tt.name)
+		}
+		if got := fileExists(filepath.Join(dir, ".github", "instructions", "old.instructions.md")); got == tt.remove {
+			t.Errorf("%s: edited stale file exists = %v, want %v", tt.name, got, !tt.remove)
+		}
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(backupDir))); (err == nil) != tt.backup {
+			t.Errorf("%s: backup dir exists = %v, want %v", tt.name, err == nil, tt.backup)
+		}
+	}
+}
+
+func TestRecordHashes(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, "a.md", "new a\n")
+	createTestFile(t, dir, "b.md", "edited b\n")
+	prev := manifest{Hashes: map[string]string{"a.md": "old", "b.md": "written b", "gone.md": "x"}}
+	cur := manifest{Targets: map[string][]string{"t": {"a.md", "b.md", "c.md"}}}
+
+	cur.recordHashes(dir, prev, []string{"a.md"})
+	want := map[string]string{"a.md": fileHash([]byte("new a\n")), "b.md": "written b"}
+	if len(cur.Hashes) != len(want) {
+		t.Errorf("hashes = %v, want %v", cur.Hashes, want)
+	}
+	for f, h := range want {
+		if cur.Hashes[f] != h {
+			t.Errorf("hash of %s = %q, want %q", f, cur.Hashes[f], h)
+		}
+	}
+}
+
+func mustRel(t *testing.T, base, path string) string {
+	t.Helper()
+	rel, err := filepath.Rel(base, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.ToSlash(rel)
+}
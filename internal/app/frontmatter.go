@@ -0,0 +1,280 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"gopkg.in/yaml.v3"
+)
+
+// frontmatter holds the parsed YAML frontmatter of a source file. Values are
+// string, bool, int, nil, []any or map[string]any.
+// Every key is kept so targets can read metadata they understand and pass
+// through what they don't.
+type frontmatter map[string]any
+
+// String returns a scalar value as a string, or "" if the key is missing or
+// holds a list or map.
+func (f frontmatter) String(key string) string {
+	return scalarString(f[key])
+}
+
+func scalarString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case nil, []any, map[string]any:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Strings returns a list value as strings. A scalar is returned as a
+// one-element list, so `applyTo: "*.go"` and `applyTo: ["*.go"]` read the same.
+func (f frontmatter) Strings(key string) []string {
+	switch v := f[key].(type) {
+	case nil:
+		return nil
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s := scalarString(item); s != "" {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		if s := f.String(key); s != "" {
+			return []string{s}
+		}
+		return nil
+	}
+}
+
+// Bool returns a boolean value and whether the key held one.
+func (f frontmatter) Bool(key string) (bool, bool) {
+	v, ok := f[key].(bool)
+	return v, ok
+}
+
+// Int returns an integer value and whether the key held one.
+func (f frontmatter) Int(key string) (int, bool) {
+	v, ok := f[key].(int)
+	return v, ok
+}
+
+// Has reports whether key is present, even with a null value.
+func (f frontmatter) Has(key string) bool {
+	_, ok := f[key]
+	return ok
+}
+
+// Keys returns the keys in sorted order.
+func (f frontmatter) Keys() []string {
+	keys := make([]string, 0, len(f))
+	for k := range f {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Globs returns a glob list from key. The value may be a YAML list or a
+// comma-separated string (Copilot's applyTo form); commas inside {} braces
+// don't split. Invalid patterns are an error.
+func (f frontmatter) Globs(key string) ([]string, error) {
+	var globs []string
+	for _, item := range f.Strings(key) {
+		depth, start := 0, 0
+		for i := 0; i <= len(item); i++ {
+			if i < len(item) {
+				switch item[i] {
+				case '{':
+					depth++
+				case '}':
+					depth--
+				}
+				if item[i] != ',' || depth > 0 {
+					continue
+				}
+			}
+			if g := strings.TrimSpace(item[start:i]); g != "" {
+				if !doublestar.ValidatePattern(g) {
+					return nil, fmt.Errorf("%s: invalid glob %q", key, g)
+				}
+				globs = append(globs, g)
+			}
+			start = i + 1
+		}
+	}
+	return globs, nil
+}
+
+// splitFrontmatter separates a leading "---" delimited block from the body.
+// found is false when the file doesn't start with "---"; closed is false when
+// it does but no closing "---" follows. In both cases body is data.
+func splitFrontmatter(data []byte) (block, body []byte, found, closed bool) {
+	first, rest, _ := bytes.Cut(data, []byte("\n"))
+	if string(bytes.TrimSpace(first)) != "---" {
+		return nil, data, false, false
+	}
+	for offset := 0; offset < len(rest); {
+		line, _, _ := bytes.Cut(rest[offset:], []byte("\n"))
+		if string(bytes.TrimSpace(line)) == "---" {
+			end := offset + len(line)
+			if end < len(rest) {
+				end++ // the newline after the closing delimiter
+			}
+			return rest[:offset], rest[end:], true, true
+		}
+		offset += len(line) + 1
+	}
+	return nil, data, true, false
+}
+
+// parseFrontmatter parses the YAML frontmatter at the top of data and returns
+// it with the remaining body. Files without frontmatter, or whose opening
+// "---" is never closed, have no metadata and are returned whole as body.
+// Malformed YAML inside a closed block is an error naming the line.
+func parseFrontmatter(data []byte) (frontmatter, []byte, error) {
+	block, body, _, closed := splitFrontmatter(data)
+	if !closed {
+		return nil, data, nil
+	}
+	meta, err := parseYAML(block, 2)
+	if err != nil {
+		return nil, data, err
+	}
+	return meta, body, nil
+}
+
+// checkFrontmatter reports structural problems with a YAML frontmatter block:
+// a missing closing delimiter or YAML the frontmatter parser rejects.
+func checkFrontmatter(data []byte) error {
+	_, _, found, closed := splitFrontmatter(data)
+	if !found {
+		return nil
+	}
+	if !closed {
+		return fmt.Errorf("frontmatter is not closed with ---")
+	}
+	_, _, err := parseFrontmatter(data)
+	return err
+}
+
+// parseYAML parses a YAML block mapping into frontmatter. firstLine is the
+// file line number of the block's first line, for error messages.
+//
+// Scalars resolve to bool, int, nil or string. Numbers that would lose digits
+// as an int, like 1.0 or 007, stay strings. Duplicate keys are an error. A
+// plain value starting with "*" is a string rather than an alias, so unquoted
+// globs like **/*.go work; frontmatter has no use for anchors.
+func parseYAML(block []byte, firstLine int) (frontmatter, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(quoteGlobValues(block), &doc); err != nil {
+		return nil, yamlError(err, firstLine)
+	}
+	if doc.Kind == 0 || len(doc.Content) == 0 {
+		return frontmatter{}, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: frontmatter must be a mapping of keys to values", firstLine+root.Line-1)
+	}
+	v, err := yamlValue(root, firstLine)
+	if err != nil {
+		return nil, err
+	}
+	return frontmatter(v.(map[string]any)), nil
+}
+
+// yamlValue converts a parsed node into the value types frontmatter holds.
+func yamlValue(n *yaml.Node, firstLine int) (any, error) {
+	switch n.Kind {
+	case yaml.AliasNode:
+		return yamlValue(n.Alias, firstLine)
+	case yaml.SequenceNode:
+		list := make([]any, 0, len(n.Content))
+		for _, item := range n.Content {
+			v, err := yamlValue(item, firstLine)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case yaml.MappingNode:
+		m := make(map[string]any, len(n.Content)/2)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, val := n.Content[i], n.Content[i+1]
+			line := firstLine + k.Line - 1
+			if k.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("line %d: keys must be plain strings", line)
+			}
+			if _, dup := m[k.Value]; dup {
+				return nil, fmt.Errorf("line %d: duplicate key %q", line, k.Value)
+			}
+			v, err := yamlValue(val, firstLine)
+			if err != nil {
+				return nil, err
+			}
+			m[k.Value] = v
+		}
+		return m, nil
+	}
+
+	switch n.ShortTag() {
+	case "!!null":
+		return nil, nil
+	case "!!bool":
+		var b bool
+		if err := n.Decode(&b); err != nil {
+			return nil, fmt.Errorf("line %d: %v", firstLine+n.Line-1, err)
+		}
+		return b, nil
+	case "!!int":
+		if i, err := strconv.Atoi(n.Value); err == nil && strconv.Itoa(i) == n.Value {
+			return i, nil
+		}
+	}
+	return n.Value, nil
+}
+
+// globValue matches a block mapping value or sequence item that starts with
+// "*", up to an optional trailing comment.
+var globValue = regexp.MustCompile(`^(\s*(?:-\s+)?[\w.-]+:\s+|\s*-\s+)(\*.*?)(\s+#.*)?$`)
+
+// quoteGlobValues single-quotes plain values that start with "*" so YAML
+// doesn't read them as aliases.
+func quoteGlobValues(block []byte) []byte {
+	lines := strings.Split(string(block), "\n")
+	for i, line := range lines {
+		text := strings.TrimRight(line, "\r")
+		if m := globValue.FindStringSubmatch(text); m != nil {
+			lines[i] = m[1] + "'" + strings.ReplaceAll(m[2], "'", "''") + "'" + m[3] + line[len(text):]
+		}
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+var yamlErrorLine = regexp.MustCompile(`^yaml: line (\d+): `)
+
+// yamlError rewrites a yaml.v3 error to name the file line rather than the
+// line within the frontmatter block. yaml.v3 leaves the line out for problems
+// on the block's first line.
+func yamlError(err error, firstLine int) error {
+	msg := strings.TrimPrefix(err.Error(), "yaml: ")
+	line := firstLine
+	if m := yamlErrorLine.FindStringSubmatch(err.Error()); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		line += n - 1
+		msg = err.Error()[len(m[0]):]
+	}
+	return fmt.Errorf("line %d: %s", line, msg)
+}
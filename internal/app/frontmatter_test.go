@@ -0,0 +1,274 @@
+package app
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		yaml string
+		want frontmatter
+	}{
+		{
+			name: "scalars",
+			yaml: "name: tdd\ncount: 3\nenabled: true\noff: False\nnothing: ~\nversion: 1.0\npadded: 007\n",
+			want: frontmatter{"name": "tdd", "count": 3, "enabled": true, "off": false, "nothing": nil, "version": "1.0", "padded": "007"},
+		},
+		{
+			name: "quoted strings",
+			yaml: "a: \"x: y # not a comment\"\nb: 'it''s'\nc: \"tab\\tnew\\nline \\u00e9\"\n",
+			want: frontmatter{"a": "x: y # not a comment", "b": "it's", "c": "tab\tnew\nline é"},
+		},
+		{
+			name: "comments",
+			yaml: "# leading comment\nname: tdd # trailing comment\nurl: http://example.com/#anchor\n",
+			want: frontmatter{"name": "tdd", "url": "http://example.com/#anchor"},
+		},
+		{
+			name: "unquoted glob",
+			yaml: "applyTo: **/*.go\n",
+			want: frontmatter{"applyTo": "**/*.go"},
+		},
+		{
+			name: "unquoted glob items",
+			yaml: "applyTo:\n  - **/*.ts # web\n  - '*.md'\n",
+			want: frontmatter{"applyTo": []any{"**/*.ts", "*.md"}},
+		},
+		{
+			name: "flow list",
+			yaml: "applyTo: [\"**/*.go\", \"**/*.mod\", plain]\n",
+			want: frontmatter{"applyTo": []any{"**/*.go", "**/*.mod", "plain"}},
+		},
+		{
+			name: "flow list across lines",
+			yaml: "applyTo: [\n  \"**/*.ts\",\n  \"**/*.tsx\"\n]\nnext: 1\n",
+			want: frontmatter{"applyTo": []any{"**/*.ts", "**/*.tsx"}, "next": 1},
+		},
+		{
+			name: "empty flow list",
+			yaml: "tools: []\n",
+			want: frontmatter{"tools": []any{}},
+		},
+		{
+			name: "flow map",
+			yaml: "model: {name: gpt, \"temp\": 0}\n",
+			want: frontmatter{"model": map[string]any{"name": "gpt", "temp": 0}},
+		},
+		{
+			name: "block list",
+			yaml: "applyTo:\n  - \"**/*.ts\"\n  - '**/*.tsx'\ntrigger: glob\n",
+			want: frontmatter{"applyTo": []any{"**/*.ts", "**/*.tsx"}, "trigger": "glob"},
+		},
+		{
+			name: "block list at key indentation",
+			yaml: "tools:\n- read\n- edit\nmodel: x\n",
+			want: frontmatter{"tools": []any{"read", "edit"}, "model": "x"},
+		},
+		{
+			name: "list of maps",
+			yaml: "inputs:\n  - name: args\n    required: true\n  - name: target\n",
+			want: frontmatter{"inputs": []any{
+				map[string]any{"name": "args", "required": true},
+				map[string]any{"name": "target"},
+			}},
+		},
+		{
+			name: "nested map",
+			yaml: "copilot:\n  mode: agent\n  tools:\n    - search\n",
+			want: frontmatter{"copilot": map[string]any{"mode": "agent", "tools": []any{"search"}}},
+		},
+		{
+			name: "multi-line plain scalar",
+			yaml: "description: Plan the work\n  and then\n  execute it.\nnext: 1\n",
+			want: frontmatter{"description": "Plan the work and then execute it.", "next": 1},
+		},
+		{
+			name: "literal block",
+			yaml: "description: |\n  line one\n    indented\n\n  line three\nnext: 1\n",
+			want: frontmatter{"description": "line one\n  indented\n\nline three\n", "next": 1},
+		},
+		{
+			name: "folded block strip",
+			yaml: "description: >-\n  folded\n  text\n\n  new paragraph\n",
+			want: frontmatter{"description": "folded text\nnew paragraph"},
+		},
+		{
+			name: "empty value",
+			yaml: "description:\nname: x\n",
+			want: frontmatter{"description": nil, "name": "x"},
+		},
+		{
+			name: "crlf line endings",
+			yaml: "name: tdd\r\napplyTo: [a, b]\r\n",
+			want: frontmatter{"name": "tdd", "applyTo": []any{"a", "b"}},
+		},
+		{
+			name: "empty block",
+			yaml: "\n# only a comment\n",
+			want: frontmatter{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := parseYAML([]byte(tt.yaml), 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseYAML =\n  %#v\nwant\n  %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseYAML_Errors(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		yaml     string
+		wantLine string
+	}{
+		{"not a mapping", "name: x\nthis is not yaml\n", "line 3"},
+		{"bad indentation", "name: x\n  nested: y\n", "line 3"},
+		{"duplicate key", "name: x\nname: y\n", "line 3"},
+		{"unclosed flow", "applyTo: [a, b\nnext: 1\n", "line 2"},
+		{"unterminated quote", "name: \"abc\n", "line 3"}, // where the block ends
+		{"tab indentation", "copilot:\n\tmode: agent\n", "line 3"},
+		{"junk after quote", "name: \"a\" b\n", "line 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := parseYAML([]byte(tt.yaml), 2)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tt.wantLine) {
+				t.Errorf("error %q should mention %s", err, tt.wantLine)
+			}
+		})
+	}
+}
+
+func TestFrontmatterAccessors(t *testing.T) {
+	t.Parallel()
+	meta, _, err := parseFrontmatter([]byte("---\nsingle: \"*.go\"\nlist: [\"*.ts\", \"*.tsx\"]\nn: 5\nflag: true\nnested: {a: b}\n---\nbody\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := meta.Strings("single"); !reflect.DeepEqual(got, []string{"*.go"}) {
+		t.Errorf("Strings(single) = %v", got)
+	}
+	if got := meta.Strings("list"); !reflect.DeepEqual(got, []string{"*.ts", "*.tsx"}) {
+		t.Errorf("Strings(list) = %v", got)
+	}
+	if got := meta.Strings("missing"); got != nil {
+		t.Errorf("Strings(missing) = %v, want nil", got)
+	}
+	if got := meta.String("list"); got != "" {
+		t.Errorf("String(list) = %q, want empty", got)
+	}
+	if got := meta.String("n"); got != "5" {
+		t.Errorf("String(n) = %q, want 5", got)
+	}
+	if n, ok := meta.Int("n"); !ok || n != 5 {
+		t.Errorf("Int(n) = %d, %v", n, ok)
+	}
+	if b, ok := meta.Bool("flag"); !ok || !b {
+		t.Errorf("Bool(flag) = %v, %v", b, ok)
+	}
+	if _, ok := meta.Bool("n"); ok {
+		t.Error("Bool(n) should report a non-bool")
+	}
+	if !meta.Has("nested") || meta.Has("missing") {
+		t.Error("Has reports wrong presence")
+	}
+	if got := meta.Keys(); !reflect.DeepEqual(got, []string{"flag", "list", "n", "nested", "single"}) {
+		t.Errorf("Keys = %v", got)
+	}
+}
+
+func TestFrontmatterGlobs(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		yaml    string
+		want    []string
+		wantErr bool
+	}{
+		{"single", "applyTo: \"**/*.go\"\n", []string{"**/*.go"}, false},
+		{"list", "applyTo: [\"**/*.ts\", \"**/*.tsx\"]\n", []string{"**/*.ts", "**/*.tsx"}, false},
+		{"comma string", "applyTo: \"**/*.ts, **/*.tsx\"\n", []string{"**/*.ts", "**/*.tsx"}, false},
+		{"braces keep commas", "applyTo: \"**/*.{ts,tsx}\"\n", []string{"**/*.{ts,tsx}"}, false},
+		{"missing", "name: x\n", nil, false},
+		{"invalid", "applyTo: \"src/[a-\"\n", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			meta, err := parseYAML([]byte(tt.yaml), 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := meta.Globs("applyTo")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Globs = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFrontmatter_BodyPreserved(t *testing.T) {
+	t.Parallel()
+	input := []byte("---\nname: x\n---\n# Title\r\n\r\nKeep --- this\n")
+	_, body, err := parseFrontmatter(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "# Title\r\n\r\nKeep --- this\n" {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestReadSources_ApplyToList(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, ".promptherder/agent/rules/web.md", "---\napplyTo:\n  - \"**/*.ts\"\n  - \"**/*.tsx\"\nexcludeFrom: \"**/*.gen.ts\"\nowner: web-team\n---\n# Web\n")
+
+	sources, err := readSources(dir, defaultSourceDir, nil, sourceRenderer{target: copilotName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != 1 {
+		t.Fatalf("expected 1 source, got %d", len(sources))
+	}
+	if !reflect.DeepEqual(sources[0].Scope.ApplyTo, []string{"**/*.ts", "**/*.tsx"}) {
+		t.Errorf("ApplyTo = %q, want both globs", sources[0].Scope.ApplyTo)
+	}
+	if !reflect.DeepEqual(sources[0].Scope.ExcludeFrom, []string{"**/*.gen.ts"}) {
+		t.Errorf("ExcludeFrom = %q", sources[0].Scope.ExcludeFrom)
+	}
+	if sources[0].Meta.String("owner") != "web-team" {
+		t.Errorf("Meta should keep unknown keys, got %v", sources[0].Meta)
+	}
+}
+
+func TestReadSources_InvalidFrontmatter(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, ".promptherder/agent/rules/bad.md", "---\napplyTo: [\"*.go\"\n---\n# Bad\n")
+
+	_, err := readSources(dir, defaultSourceDir, nil, sourceRenderer{target: copilotName})
+	if err == nil || !strings.Contains(err.Error(), "bad.md") || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected error naming bad.md line 2, got %v", err)
+	}
+}
@@ -21,14 +21,34 @@ const (
 // herdContentDirs are the only top-level directories merged from a herd.
 // Files outside these dirs (README.md, LICENSE, etc.) are not copied.
 var herdContentDirs = map[string]bool{
+	partialsDir: true,
 	"rules":     true,
 	"skills":    true,
 	"workflows": true,
 }
 
+// herdMerges reports whether the herd file at rel (slash-separated,
+// relative to the herd root) is merged into the agent dir: it sits under a
+// content dir and no element of its path is hidden. HerdTreeHash covers
+// every such file, so a signature vouches for all merged content.
+func herdMerges(rel string) bool {
+	parts := strings.Split(rel, "/")
+	if len(parts) < 2 || !herdContentDirs[parts[0]] {
+		return false
+	}
+	for _, p := range parts {
+		if strings.HasPrefix(p, ".") {
+			return false
+		}
+	}
+	return true
+}
+
 // HerdMeta is the metadata parsed from a herd's herd.json file.
 type HerdMeta struct {
-	Name string `json:"name"`
+	Name        string `json:"name"`
+	Version     string `json:"version,omitempty"`
+	Description string `json:"description,omitempty"`
 }
 
 // herdOnDisk pairs metadata with its filesystem location.
@@ -37,6 +57,16 @@ type herdOnDisk struct {
 	Path string // absolute path to the herd root (e.g. .promptherder/herds/compound-v)
 }
 
+// checkHerdName rejects herd names that are not a single, visible path
+// element. Names become directories under .promptherder/herds/, so a name
+// like "../src" would replace files outside it.
+func checkHerdName(name string) error {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("invalid herd name %q: %w", name, ErrValidation)
+	}
+	return nil
+}
+
 // discoverHerds scans .promptherder/herds/ for installed herds.
 // Returns herds sorted by name for deterministic merge order.
 func discoverHerds(repoPath string) ([]herdOnDisk, error) {
@@ -83,65 +113,60 @@ func discoverHerds(repoPath string) ([]herdOnDisk, error) {
 	return herds, nil
 }
 
-// mergeHerds copies all herd content into .promptherder/agent/, erroring on conflict.
+// mergeHerds copies all herd content into .promptherder/agent/, erroring on
+// conflict before anything is written.
 // It respects generated files that already exist and should not be overwritten.
+// Local overlays from .promptherder/overlays/<herd>/ are applied on top of the
+// herd content; an overlay that no longer applies fails the merge.
 func mergeHerds(ctx context.Context, repoPath string, herds []herdOnDisk, m manifest, cfg TargetConfig) ([]string, error) {
 	agentRoot := filepath.Join(repoPath, agentDir)
 
 	// Track which herd owns each relative path, for conflict detection.
 	ownership := make(map[string]string) // relSlash → herd name
-	var installed []string
+
+	// Nothing is written until every herd has merged cleanly.
+	var items []planItem
 
 	for _, herd := range herds {
 		if err := ctx.Err(); err != nil {
 			return nil, err
 		}
 
-		err := filepath.WalkDir(herd.Path, func(path string, d fs.DirEntry, err error) error {
+		overlay, err := loadOverlay(repoPath, herd.Meta.Name)
+		if err != nil {
+			return nil, err
+		}
+		if !overlay.empty() {
+			cfg.Logger.Debug("loaded overlay", "herd", herd.Meta.Name, "replace", len(overlay.Replace), "patches", len(overlay.Patches))
+		}
+		provided := make(map[string]bool) // herd-relative paths, for overlay checks
+
+		err = filepath.WalkDir(herd.Path, func(path string, d fs.DirEntry, err error) error {
 			if err != nil {
 				return err
 			}
 			if err := ctx.Err(); err != nil {
 				return err
 			}
-			// Skip .git directory entirely.
-			if d.IsDir() && d.Name() == ".git" {
-				return filepath.SkipDir
+			rel, err := filepath.Rel(herd.Path, path)
+			if err != nil {
+				return fmt.Errorf("rel path: %w", err)
 			}
-			// Only walk into known herd content directories at the top level.
+			relSlash := filepath.ToSlash(rel)
 			if d.IsDir() {
-				rel, err := filepath.Rel(herd.Path, path)
-				if err != nil {
-					return fmt.Errorf("rel path: %w", err)
-				}
-				// Allow the herd root itself.
-				if rel == "." {
-					return nil
-				}
-				// At top level, skip dirs not in herdContentDirs.
-				topDir := strings.SplitN(filepath.ToSlash(rel), "/", 2)[0]
-				if !herdContentDirs[topDir] {
+				// Only walk into known herd content directories, and never
+				// into hidden ones (.git and the like).
+				if rel != "." && (strings.HasPrefix(d.Name(), ".") || !herdContentDirs[strings.SplitN(relSlash, "/", 2)[0]]) {
 					return filepath.SkipDir
 				}
 				return nil
 			}
-
-			// Skip herd.json itself.
-			if d.Name() == herdMetaFile {
+			// Symlinks and hidden files are neither signed nor merged.
+			if !d.Type().IsRegular() || !herdMerges(relSlash) || d.Name() == herdMetaFile {
 				return nil
 			}
 
-			rel, err := filepath.Rel(herd.Path, path)
-			if err != nil {
-				return fmt.Errorf("rel path: %w", err)
-			}
-			relSlash := filepath.ToSlash(rel)
-
-			// Skip files not under a known content directory.
-			topDir := strings.SplitN(relSlash, "/", 2)[0]
-			if !herdContentDirs[topDir] {
-				return nil
-			}
+			provided[relSlash] = true
 
 			// Conflict detection: does another herd already provide this file?
 			if owner, exists := ownership[relSlash]; exists {
@@ -163,40 +188,54 @@ func mergeHerds(ctx context.Context, repoPath string, herds []herdOnDisk, m mani
 				return fmt.Errorf("read %s: %w", path, err)
 			}
 
-			targetPath := filepath.Join(agentRoot, filepath.FromSlash(relSlash))
-			targetRel := filepath.ToSlash(filepath.Join(agentDir, relSlash))
-
-			if cfg.DryRun {
-				cfg.Logger.Info("dry-run", "target", targetRel, "source", relSlash, "herd", herd.Meta.Name)
-			} else {
-				if err := writeFile(targetPath, data); err != nil {
-					return err
-				}
-				cfg.Logger.Info("synced", "target", targetRel, "source", relSlash, "herd", herd.Meta.Name)
+			data, overlaid, err := overlay.apply(relSlash, data)
+			if err != nil {
+				return err
+			}
+			if overlaid {
+				cfg.Logger.Info("overlay", "file", relSlash, "herd", herd.Meta.Name)
 			}
 
+			items = append(items, planItem{
+				Target:  filepath.Join(agentRoot, filepath.FromSlash(relSlash)),
+				Content: data,
+				Sources: []string{relSlash},
+				Herd:    herd.Meta.Name,
+			})
 			ownership[relSlash] = herd.Meta.Name
-			installed = append(installed, targetRel)
 			return nil
 		})
 
 		if err != nil {
 			return nil, fmt.Errorf("herd %s: %w", herd.Meta.Name, err)
 		}
+
+		if missing := overlay.unused(provided); len(missing) > 0 {
+			return nil, fmt.Errorf("herd %s: overlay targets files the herd no longer provides: %s", herd.Meta.Name, strings.Join(missing, ", "))
+		}
 	}
 
+	var installed []string
+	for _, item := range items {
+		targetRel := agentDir + "/" + item.Sources[0]
+		if err := emitFile(cfg, targetRel, item.Content, "source", item.Sources[0], "herd", item.Herd); err != nil {
+			return installed, err
+		}
+		installed = append(installed, targetRel)
+	}
 	return installed, nil
 }
 
 // cleanAgentDir removes all files from .promptherder/agent/ that are tracked
 // in the manifest under the herds target, preparing for a fresh merge.
 func cleanAgentDir(repoPath string, prev manifest, dryRun bool, logger interface{ Info(string, ...any) }) error {
-	herdFiles := prev.Targets["herds"]
-	if len(herdFiles) == 0 {
-		return nil
-	}
+	return removeMerged(repoPath, prev.Targets["herds"], prev, dryRun, logger)
+}
 
-	for _, relSlash := range herdFiles {
+// removeMerged removes files merged into .promptherder/agent/ by a previous
+// run, along with directories the removal leaves empty.
+func removeMerged(repoPath string, merged []string, prev manifest, dryRun bool, logger interface{ Info(string, ...any) }) error {
+	for _, relSlash := range merged {
 		// Only clean files under .promptherder/agent/
 		if !strings.HasPrefix(relSlash, agentDir+"/") {
 			continue
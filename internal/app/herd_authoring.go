@@ -0,0 +1,378 @@
+package app
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Severity levels for HerdIssue.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// HerdIssue is a single problem found by ValidateHerd.
+type HerdIssue struct {
+	Severity string // SeverityError or SeverityWarning
+	Path     string // herd-relative path, slash-separated
+	Message  string
+}
+
+func (i HerdIssue) String() string {
+	return fmt.Sprintf("%s: %s: %s", i.Severity, i.Path, i.Message)
+}
+
+// herdDocFiles are top-level files that are expected in a herd but are not
+// merged (documentation and licensing).
+var herdDocFiles = []string{"README", "LICENSE", "CHANGELOG", "CONTRIBUTING", "NOTICE"}
+
+// variantFilePattern matches uppercase filenames like COPILOT.md that look
+// like skill variants.
+var variantFilePattern = regexp.MustCompile(`^[A-Z][A-Z0-9_-]*\.md$`)
+
+// HerdInit scaffolds a new herd in dir/name with herd.json and example
+// rules, skills, and workflows. It refuses to write into a non-empty directory.
+func HerdInit(dir, name string) (string, error) {
+	if err := checkHerdName(name); err != nil {
+		return "", err
+	}
+
+	root := filepath.Join(dir, name)
+	if entries, err := os.ReadDir(root); err == nil && len(entries) > 0 {
+		return "", fmt.Errorf("%s already exists and is not empty", root)
+	}
+
+	// The description is left empty for the author to fill in; herd validate
+	// flags it until they do.
+	meta, err := json.MarshalIndent(struct {
+		Name        string `json:"name"`
+		Version     string `json:"version"`
+		Description string `json:"description"`
+	}{Name: name, Version: "0.1.0"}, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("marshal %s: %w", herdMetaFile, err)
+	}
+
+	scaffold := map[string]string{
+		herdMetaFile: string(meta) + "\n",
+		"README.md":  fmt.Sprintf("# %s\n\nA promptherder herd.\n\n```bash\npromptherder pull <url-of-this-repo>\npromptherder\n```\n", name),
+		"rules/" + name + ".md": "# " + name + "\n\n" +
+			"- Always-on rule. Add `applyTo` frontmatter to scope it to matching files.\n",
+		"skills/example/SKILL.md": "---\nname: example\ndescription: Describe when the agent should use this skill.\n---\n\n" +
+			"# Example Skill\n\n1. Step one.\n2. Step two.\n",
+		"workflows/example.md": "---\ndescription: Describe what this slash command does.\n---\n\n" +
+			"# Example Workflow\n\n1. Step one.\n2. Step two.\n",
+	}
+
+	paths := make([]string, 0, len(scaffold))
+	for rel := range scaffold {
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+
+	for _, rel := range paths {
+		if err := writeFile(filepath.Join(root, filepath.FromSlash(rel)), []byte(scaffold[rel])); err != nil {
+			return "", err
+		}
+	}
+
+	return root, nil
+}
+
+// ValidateHerd checks a herd directory for mistakes that would otherwise
+// surface only as files silently missing after a merge: a missing or invalid
+// herd.json, top-level directories that mergeHerds drops, skills without a
+// SKILL.md, malformed frontmatter, rule triggers, globs or order that a sync
+// would reject, and variant files no target recognizes. It also flags a
+// herd.json without a description. Returns the issues found, sorted by path.
+// The error is non-nil only when the herd cannot be read at all.
+func ValidateHerd(herdPath string) ([]HerdIssue, error) {
+	info, err := os.Stat(herdPath)
+	if err != nil {
+		return nil, fmt.Errorf("read herd: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", herdPath)
+	}
+
+	var issues []HerdIssue
+	add := func(severity, path, format string, args ...any) {
+		issues = append(issues, HerdIssue{Severity: severity, Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// herd.json
+	data, err := os.ReadFile(filepath.Join(herdPath, herdMetaFile))
+	switch {
+	case os.IsNotExist(err):
+		add(SeverityError, herdMetaFile, "missing — promptherder ignores directories without it")
+	case err != nil:
+		return nil, fmt.Errorf("read %s: %w", herdMetaFile, err)
+	default:
+		var meta HerdMeta
+		if err := json.Unmarshal(data, &meta); err != nil {
+			add(SeverityError, herdMetaFile, "invalid JSON: %v", err)
+		} else {
+			if meta.Name == "" {
+				add(SeverityWarning, herdMetaFile, "no name set; the directory name will be used")
+			}
+			if strings.TrimSpace(meta.Description) == "" {
+				add(SeverityWarning, herdMetaFile, "no description set; say what the herd is for before publishing")
+			}
+		}
+	}
+
+	// Top-level entries.
+	entries, err := os.ReadDir(herdPath)
+	if err != nil {
+		return nil, fmt.Errorf("read herd: %w", err)
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, ".") || name == herdMetaFile || name == herdSigFile {
+			continue
+		}
+		if e.IsDir() {
+			if !herdContentDirs[name] {
+				add(SeverityWarning, name+"/", "not merged — only %s are copied from a herd", strings.Join(sortedKeys(herdContentDirs), ", "))
+			}
+			continue
+		}
+		if !isHerdDocFile(name) {
+			add(SeverityWarning, name, "top-level file is not merged — move it under %s", strings.Join(sortedKeys(herdContentDirs), "/, ")+"/")
+		}
+	}
+
+	// Skills: every skill directory needs SKILL.md; uppercase .md files must
+	// be known variants.
+	skillsRoot := filepath.Join(herdPath, "skills")
+	if skillDirs, err := os.ReadDir(skillsRoot); err == nil {
+		for _, sd := range skillDirs {
+			if !sd.IsDir() {
+				continue
+			}
+			skillRel := "skills/" + sd.Name()
+			files, err := os.ReadDir(filepath.Join(skillsRoot, sd.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("read %s: %w", skillRel, err)
+			}
+			hasSkill := false
+			for _, f := range files {
+				if f.IsDir() {
+					continue
+				}
+				switch {
+				case f.Name() == "SKILL.md":
+					hasSkill = true
+				case isHerdDocFile(f.Name()):
+				case variantFilePattern.MatchString(f.Name()):
+					if _, known := SkillVariantFiles[f.Name()]; !known {
+						add(SeverityWarning, skillRel+"/"+f.Name(), "looks like a skill variant but no target uses it (known variants: %s)", strings.Join(sortedKeys(SkillVariantFiles), ", "))
+					}
+				}
+			}
+			if !hasSkill {
+				add(SeverityError, skillRel+"/", "skill has no SKILL.md and will be skipped by every target")
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read skills: %w", err)
+	}
+
+	// Frontmatter in every markdown file under the content dirs.
+	for dir := range herdContentDirs {
+		root := filepath.Join(herdPath, dir)
+		if !isDirectory(root) {
+			continue
+		}
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !strings.HasSuffix(d.Name(), ".md") {
+				return nil
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("read %s: %w", path, err)
+			}
+			rel, _ := filepath.Rel(herdPath, path)
+			if ferr := checkFrontmatter(data); ferr != nil {
+				add(SeverityError, filepath.ToSlash(rel), "%v", ferr)
+			} else if dir == "rules" {
+				meta, _, _ := parseFrontmatter(data)
+				if _, serr := parseRuleScope(meta); serr != nil {
+					add(SeverityError, filepath.ToSlash(rel), "%v", serr)
+				}
+				if _, oerr := parseRuleOrder(meta); oerr != nil {
+					add(SeverityError, filepath.ToSlash(rel), "%v", oerr)
+				}
+			}
+			if _, cerr := renderConditionals(data, ""); cerr != nil {
+				add(SeverityError, filepath.ToSlash(rel), "conditional block: %v", cerr)
+			}
+			if _, ierr := expandIncludes(data, filepath.ToSlash(rel), herdPath, ""); ierr != nil {
+				add(SeverityError, filepath.ToSlash(rel), "%v", ierr)
+			}
+			for _, dest := range brokenLinks(data, filepath.ToSlash(rel), herdPath) {
+				add(SeverityWarning, filepath.ToSlash(rel), "link %q points to nothing in the herd", dest)
+			}
+			if templated(data) {
+				if _, terr := parseTemplate(data, filepath.ToSlash(rel), ""); terr != nil {
+					add(SeverityError, filepath.ToSlash(rel), "%s", strings.TrimPrefix(terr.Error(), "template: "))
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Path < issues[j].Path })
+	return issues, nil
+}
+
+// PackHerd writes a reproducible tar.gz of the herd at herdPath to w.
+// Entries are sorted, prefixed with "<name>/" (so `pull` can extract the
+// archive like a GitHub tarball), and carry no timestamps or ownership.
+// Hidden files and directories (.git, .github, ...) are excluded.
+func PackHerd(herdPath string, w io.Writer) error {
+	meta, err := readHerdMeta(herdPath)
+	if err != nil {
+		return err
+	}
+
+	gw, err := gzip.NewWriterLevel(w, gzip.BestCompression)
+	if err != nil {
+		return fmt.Errorf("gzip writer: %w", err)
+	}
+	tw := tar.NewWriter(gw)
+
+	epoch := time.Unix(0, 0).UTC()
+	err = filepath.WalkDir(herdPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(herdPath, path)
+		if err != nil {
+			return fmt.Errorf("rel path: %w", err)
+		}
+		if rel == "." {
+			return tw.WriteHeader(&tar.Header{Name: meta.Name + "/", Typeflag: tar.TypeDir, Mode: 0o755, ModTime: epoch})
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		name := meta.Name + "/" + filepath.ToSlash(rel)
+		if d.IsDir() {
+			return tw.WriteHeader(&tar.Header{Name: name + "/", Typeflag: tar.TypeDir, Mode: 0o755, ModTime: epoch})
+		}
+		if !d.Type().IsRegular() {
+			return nil // skip symlinks and other special files
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		mode := int64(0o644)
+		if info.Mode()&0o111 != 0 {
+			mode = 0o755 // keep helper scripts executable
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", path, err)
+		}
+		hdr := &tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: mode, Size: int64(len(data)), ModTime: epoch}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("tar header %s: %w", name, err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			return fmt.Errorf("tar write %s: %w", name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("pack herd %s: %w", meta.Name, err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("close tar: %w", err)
+	}
+	if err := gw.Close(); err != nil {
+		return fmt.Errorf("close gzip: %w", err)
+	}
+	return nil
+}
+
+// PackFileName returns the default archive name for a herd, e.g.
+// "compound-v-0.9.0.tar.gz".
+func PackFileName(herdPath string) (string, error) {
+	meta, err := readHerdMeta(herdPath)
+	if err != nil {
+		return "", err
+	}
+	if meta.Version == "" {
+		return meta.Name + ".tar.gz", nil
+	}
+	return meta.Name + "-" + meta.Version + ".tar.gz", nil
+}
+
+// readHerdMeta loads herd.json from herdPath, defaulting the name to the
+// directory name.
+func readHerdMeta(herdPath string) (HerdMeta, error) {
+	metaPath := filepath.Join(herdPath, herdMetaFile)
+	data, err := os.ReadFile(metaPath)
+	if err != nil {
+		return HerdMeta{}, fmt.Errorf("read %s: %w", metaPath, err)
+	}
+	var meta HerdMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return HerdMeta{}, fmt.Errorf("parse %s: %w", metaPath, err)
+	}
+	if meta.Name == "" {
+		abs, err := filepath.Abs(herdPath)
+		if err != nil {
+			return HerdMeta{}, fmt.Errorf("resolve herd path: %w", err)
+		}
+		meta.Name = filepath.Base(abs)
+	}
+	return meta, nil
+}
+
+// isHerdDocFile reports whether name is a documentation/licensing file
+// (README.md, LICENSE, ...).
+func isHerdDocFile(name string) bool {
+	stem := strings.ToUpper(strings.TrimSuffix(name, filepath.Ext(name)))
+	for _, doc := range herdDocFiles {
+		if stem == doc {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,295 @@
+package app
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// findIssue returns the first issue whose path equals path, or nil.
+func findIssue(issues []HerdIssue, path string) *HerdIssue {
+	for i := range issues {
+		if issues[i].Path == path {
+			return &issues[i]
+		}
+	}
+	return nil
+}
+
+func TestHerdInit_ScaffoldsValidHerd(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	root, err := HerdInit(dir, "my-herd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != filepath.Join(dir, "my-herd") {
+		t.Errorf("root = %q, want %q", root, filepath.Join(dir, "my-herd"))
+	}
+
+	for _, rel := range []string{"herd.json", "README.md", "rules/my-herd.md", "skills/example/SKILL.md", "workflows/example.md"} {
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(rel))); err != nil {
+			t.Errorf("%s should exist: %v", rel, err)
+		}
+	}
+
+	meta, err := readHerdMeta(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Name != "my-herd" || meta.Version == "" {
+		t.Errorf("herd.json = %+v, want name my-herd with a version", meta)
+	}
+
+	if meta.Description != "" {
+		t.Errorf("description = %q, want it left for the author", meta.Description)
+	}
+
+	// The only issue is the description the author still has to write.
+	issues, err := ValidateHerd(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 1 || issues[0].Path != herdMetaFile || issues[0].Severity != SeverityWarning {
+		t.Errorf("scaffolded herd should only lack a description, got %v", issues)
+	}
+}
+
+func TestHerdInit_RefusesNonEmptyDir(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, "my-herd/notes.txt", "keep me\n")
+
+	if _, err := HerdInit(dir, "my-herd"); err == nil {
+		t.Fatal("expected error for non-empty directory")
+	}
+}
+
+func TestHerdInit_RejectsBadName(t *testing.T) {
+	t.Parallel()
+	for _, name := range []string{"", "a/b", ".hidden"} {
+		if _, err := HerdInit(t.TempDir(), name); err == nil {
+			t.Errorf("HerdInit(%q) should fail", name)
+		}
+	}
+}
+
+func TestValidateHerd_ReportsProblems(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	createTestFile(t, dir, "herd.json", `{"name":"bad-herd","description":"Examples of mistakes"}`)
+	createTestFile(t, dir, "README.md", "# Docs\n")
+	createTestFile(t, dir, "rules/ok.md", "---\napplyTo: \"**/*.go\"\n---\n# OK\n")
+	createTestFile(t, dir, "rules/unclosed.md", "---\napplyTo: \"**/*.go\"\n# Oops\n")
+	createTestFile(t, dir, "rules/garbage.md", "---\nthis is not yaml\n---\n# Garbage\n")
+	createTestFile(t, dir, "workflows/open-if.md", "# Plan\n<!-- promptherder:if target=copilot -->\nCopilot only\n")
+	createTestFile(t, dir, "skills/bad-tmpl/SKILL.md", "# Skill\nRun {{.TestCommand\n")
+	createTestFile(t, dir, "partials/verify.md", "Run the tests.\n")
+	createTestFile(t, dir, "rules/includes.md", "<!-- @include /partials/verify.md -->\n<!-- @include ../partials/missing.md -->\n")
+	createTestFile(t, dir, "workflows/links.md", "See [ok](../rules/ok.md), [gone](../rules/gone.md) and [docs](https://example.com).\n")
+	createTestFile(t, dir, "prompts/orphan.md", "# Dropped\n")
+	createTestFile(t, dir, "notes.md", "# Dropped too\n")
+	createTestFile(t, dir, "skills/no-skill/COPILOT.md", "# Variant only\n")
+	createTestFile(t, dir, "skills/typo/SKILL.md", "# Skill\n")
+	createTestFile(t, dir, "skills/typo/CURSOR.md", "# Unknown variant\n")
+	createTestFile(t, dir, ".github/workflows/ci.yml", "on: push\n")
+	createTestFile(t, dir, "rules/bad-trigger.md", "---\ntrigger: sometimes\n---\n# Bad trigger\n")
+	createTestFile(t, dir, "rules/bad-glob.md", "---\napplyTo: \"src/[a-\"\n---\n# Bad glob\n")
+	createTestFile(t, dir, "rules/bad-order.md", "---\norder: first\n---\n# Bad order\n")
+
+	issues, err := ValidateHerd(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"prompts/":                 SeverityWarning,
+		"notes.md":                 SeverityWarning,
+		"rules/unclosed.md":        SeverityError,
+		"rules/garbage.md":         SeverityError,
+		"workflows/open-if.md":     SeverityError,
+		"skills/bad-tmpl/SKILL.md": SeverityError,
+		"rules/includes.md":        SeverityError,
+		"skills/no-skill/":         SeverityError,
+		"skills/typo/CURSOR.md":    SeverityWarning,
+		"workflows/links.md":       SeverityWarning,
+		"rules/bad-trigger.md":     SeverityError,
+		"rules/bad-glob.md":        SeverityError,
+		"rules/bad-order.md":       SeverityError,
+	}
+	for path, severity := range want {
+		issue := findIssue(issues, path)
+		if issue == nil {
+			t.Errorf("expected issue for %s, got %v", path, issues)
+			continue
+		}
+		if issue.Severity != severity {
+			t.Errorf("%s severity = %s, want %s", path, issue.Severity, severity)
+		}
+	}
+	if len(issues) != len(want) {
+		t.Errorf("expected %d issues, got %d: %v", len(want), len(issues), issues)
+	}
+	if issue := findIssue(issues, "notes.md"); issue != nil && !strings.Contains(issue.Message, "partials/") {
+		t.Errorf("notes.md message should list every content dir, got %q", issue.Message)
+	}
+}
+
+func TestValidateHerd_MissingHerdJSON(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, "rules/foo.md", "# Foo\n")
+
+	issues, err := ValidateHerd(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issue := findIssue(issues, herdMetaFile)
+	if issue == nil || issue.Severity != SeverityError {
+		t.Errorf("expected error for missing herd.json, got %v", issues)
+	}
+}
+
+func TestValidateHerd_InvalidHerdJSON(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, "herd.json", `{"name":`)
+
+	issues, err := ValidateHerd(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issue := findIssue(issues, herdMetaFile)
+	if issue == nil || !strings.Contains(issue.Message, "invalid JSON") {
+		t.Errorf("expected invalid JSON issue, got %v", issues)
+	}
+}
+
+func TestValidateHerd_MissingDescription(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, "herd.json", `{"name":"h","description":"  "}`)
+
+	issues, err := ValidateHerd(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issue := findIssue(issues, herdMetaFile)
+	if issue == nil || issue.Severity != SeverityWarning || !strings.Contains(issue.Message, "description") {
+		t.Errorf("expected a description warning, got %v", issues)
+	}
+}
+
+func TestValidateHerd_CompoundV(t *testing.T) {
+	t.Parallel()
+
+	// The herd vendored in this repo must always validate cleanly.
+	herdPath := filepath.Join("..", "..", ".promptherder", "herds", "compound-v")
+	if !isDirectory(herdPath) {
+		t.Skip("compound-v herd not present")
+	}
+	issues, err := ValidateHerd(herdPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			t.Errorf("unexpected error: %s", issue)
+		}
+	}
+}
+
+func TestPackHerd_Reproducible(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	root, err := HerdInit(dir, "packed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	createTestFile(t, root, ".git/HEAD", "ref: refs/heads/main\n")
+
+	var first, second bytes.Buffer
+	if err := PackHerd(root, &first); err != nil {
+		t.Fatal(err)
+	}
+
+	// Touch a file: mtimes must not leak into the archive.
+	path := filepath.Join(root, "rules", "packed.md")
+	data, _ := os.ReadFile(path)
+	mustWrite(t, path, string(data))
+
+	if err := PackHerd(root, &second); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Error("packing the same herd twice should produce identical bytes")
+	}
+
+	// Entries are prefixed with the herd name and exclude hidden dirs.
+	gz, err := gzip.NewReader(bytes.NewReader(first.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+		if !strings.HasPrefix(hdr.Name, "packed/") {
+			t.Errorf("entry %q should be prefixed with packed/", hdr.Name)
+		}
+		if strings.Contains(hdr.Name, ".git") {
+			t.Errorf("entry %q should not be packed", hdr.Name)
+		}
+	}
+	if len(names) == 0 {
+		t.Fatal("archive is empty")
+	}
+
+	// The archive round-trips through the pull extractor.
+	dest := filepath.Join(dir, "extracted")
+	if err := extractTarGz(bytes.NewReader(first.Bytes()), dest, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "herd.json")); err != nil {
+		t.Errorf("herd.json should be extracted: %v", err)
+	}
+}
+
+func TestPackFileName(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	createTestFile(t, dir, "versioned/herd.json", `{"name":"versioned","version":"1.2.0"}`)
+	createTestFile(t, dir, "plain/herd.json", `{}`)
+
+	got, err := PackFileName(filepath.Join(dir, "versioned"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "versioned-1.2.0.tar.gz" {
+		t.Errorf("PackFileName = %q, want versioned-1.2.0.tar.gz", got)
+	}
+
+	got, err = PackFileName(filepath.Join(dir, "plain"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "plain.tar.gz" {
+		t.Errorf("PackFileName = %q, want plain.tar.gz", got)
+	}
+}
@@ -436,6 +436,40 @@ func TestMergeHerds_SkipsNonContentDirs(t *testing.T) {
 	}
 }
 
+func TestMergeHerds_Partials(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, filepath.Join(herdsDir, "test-herd", "herd.json"), `{"name":"test-herd"}`)
+	createTestFile(t, dir, filepath.Join(herdsDir, "test-herd", "partials", "verify.md"), "Run the tests.\n")
+	createTestFile(t, dir, filepath.Join(herdsDir, "test-herd", "skills", "tdd", "SKILL.md"), "# TDD\n<!-- @include ../../partials/verify.md -->\n")
+
+	herds, err := discoverHerds(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := TargetConfig{RepoPath: dir, Logger: testLogger(t)}
+	if _, err := mergeHerds(context.Background(), dir, herds, manifest{Version: 2}, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, agentDir, "partials", "verify.md")); err != nil {
+		t.Error("herd partials should be merged into the agent dir")
+	}
+
+	if _, err := (AntigravityTarget{}).Install(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	skill, err := os.ReadFile(filepath.Join(dir, ".agent", "skills", "tdd", "SKILL.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(skill) != "# TDD\nRun the tests.\n" {
+		t.Errorf("SKILL.md = %q", skill)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".agent", "partials")); !os.IsNotExist(err) {
+		t.Error("partials must not be installed to .agent/")
+	}
+}
+
 func TestCleanAgentDir_RemovesEmptyParentDirs(t *testing.T) {
 	t.Parallel()
 	dir := t.TempDir()
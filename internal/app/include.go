@@ -0,0 +1,116 @@
+package app
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// partialsDir holds shared fragments under .promptherder/agent (and in
+// herds). Partials are only reachable through @include; no target emits them.
+const partialsDir = "partials"
+
+// includeRe matches <!-- @include path -->. The path is relative to the
+// including file; a leading "/" makes it relative to the include root
+// (.promptherder/agent during a sync, the herd root in `herd validate`).
+var includeRe = regexp.MustCompile(`<!--\s*@include\s+(.*?)\s*-->`)
+
+// isIncludeComment reports whether an HTML comment body is a well-formed
+// include directive, which the content scanner doesn't flag.
+func isIncludeComment(body string) bool {
+	f := strings.Fields(body)
+	return len(f) == 2 && f[0] == "@include" && strings.HasSuffix(f[1], ".md")
+}
+
+// expandIncludes replaces include directives in data with the referenced
+// files, recursively. label is data's slash path relative to dir; included
+// files must stay under boundary (a slash path relative to dir, "" for all
+// of dir). A directive alone on its line is replaced with the whole file;
+// frontmatter in included files is dropped and their relative links are
+// rebased onto label's directory.
+func expandIncludes(data []byte, label, dir, boundary string) ([]byte, error) {
+	return expandIncludesFrom(data, label, dir, boundary, []string{label})
+}
+
+func expandIncludesFrom(data []byte, label, dir, boundary string, stack []string) ([]byte, error) {
+	if !bytes.Contains(data, []byte("@include")) {
+		return data, nil
+	}
+	matches := includeRe.FindAllSubmatchIndex(data, -1)
+	if len(matches) == 0 {
+		return data, nil
+	}
+
+	var out bytes.Buffer
+	pos := 0
+	for _, m := range matches {
+		line := bytes.Count(data[:m[0]], []byte("\n")) + 1
+		fail := func(format string, args ...any) error {
+			return fmt.Errorf("%s:%d: %s: %w", label, line, fmt.Sprintf(format, args...), ErrValidation)
+		}
+
+		ref := string(data[m[2]:m[3]])
+		if !strings.HasSuffix(ref, ".md") {
+			return nil, fail("include %q: want a .md file", ref)
+		}
+		var target string
+		if strings.HasPrefix(ref, "/") {
+			target = path.Clean(path.Join(boundary, strings.TrimPrefix(ref, "/")))
+		} else {
+			target = path.Clean(path.Join(path.Dir(label), ref))
+		}
+		if (boundary != "" && !strings.HasPrefix(target, boundary+"/")) || target == ".." || strings.HasPrefix(target, "../") {
+			return nil, fail("include %q escapes %s", ref, cmp.Or(boundary, "the include root"))
+		}
+		for i, s := range stack {
+			if s == target {
+				return nil, fail("include cycle: %s", strings.Join(append(stack[i:], target), " → "))
+			}
+		}
+
+		raw, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(target)))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, fail("include %q: %s not found", ref, target)
+			}
+			return nil, fmt.Errorf("read %s: %w", target, err)
+		}
+		_, body, err := parseFrontmatter(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: frontmatter %v: %w", target, err, ErrValidation)
+		}
+		body, err = expandIncludesFrom(body, target, dir, boundary, append(stack, target))
+		if err != nil {
+			return nil, err
+		}
+		// Links in the partial are written relative to the partial.
+		body = rebaseLinks(body, path.Dir(target), path.Dir(label))
+
+		// A directive alone on its line becomes the included block; an
+		// inline one is spliced in without the partial's final newline.
+		start, end := m[0], m[1]
+		lineStart := bytes.LastIndexByte(data[:start], '\n') + 1
+		lineEnd := len(data)
+		if i := bytes.IndexByte(data[end:], '\n'); i >= 0 {
+			lineEnd = end + i + 1
+		}
+		if len(bytes.TrimSpace(data[lineStart:start])) == 0 && len(bytes.TrimSpace(data[end:lineEnd])) == 0 {
+			start, end = lineStart, lineEnd
+			if len(body) > 0 && body[len(body)-1] != '\n' {
+				body = append(body, '\n')
+			}
+		} else {
+			body = bytes.TrimRight(body, "\r\n")
+		}
+		out.Write(data[pos:start])
+		out.Write(body)
+		pos = end
+	}
+	out.Write(data[pos:])
+	return out.Bytes(), nil
+}
@@ -0,0 +1,158 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandIncludes(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, "agent/partials/verify.md", "---\ndescription: shared\n---\n## Verify\n\nRun the tests.\n")
+	createTestFile(t, dir, "agent/partials/outer.md", "Before.\n<!-- @include verify.md -->\nAfter.\n")
+	createTestFile(t, dir, "agent/partials/word.md", "tests\n")
+	createTestFile(t, dir, "agent/partials/see-plan.md", "See [the plan](../workflows/plan.md).\n")
+
+	tests := []struct {
+		name  string
+		label string
+		input string
+		want  string
+	}{
+		{
+			name:  "relative to including file",
+			label: "agent/skills/tdd/SKILL.md",
+			input: "# TDD\n<!-- @include ../../partials/verify.md -->\nDone.\n",
+			want:  "# TDD\n## Verify\n\nRun the tests.\nDone.\n",
+		},
+		{
+			name:  "root-relative",
+			label: "agent/rules/x.md",
+			input: "<!-- @include /partials/verify.md -->\n",
+			want:  "## Verify\n\nRun the tests.\n",
+		},
+		{
+			name:  "nested",
+			label: "agent/rules/x.md",
+			input: "<!-- @include /partials/outer.md -->\n",
+			want:  "Before.\n## Verify\n\nRun the tests.\nAfter.\n",
+		},
+		{
+			name:  "inline",
+			label: "agent/rules/x.md",
+			input: "Always run the <!-- @include /partials/word.md --> first.\n",
+			want:  "Always run the tests first.\n",
+		},
+		{
+			name:  "links rebased onto the including file",
+			label: "agent/skills/tdd/SKILL.md",
+			input: "<!-- @include /partials/see-plan.md -->\n",
+			want:  "See [the plan](../../workflows/plan.md).\n",
+		},
+		{
+			name:  "no directives",
+			label: "agent/rules/x.md",
+			input: "<!-- note -->\n",
+			want:  "<!-- note -->\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := expandIncludes([]byte(tt.input), tt.label, dir, "agent")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandIncludes_Errors(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, "agent/partials/a.md", "<!-- @include b.md -->\n")
+	createTestFile(t, dir, "agent/partials/b.md", "x\n\n<!-- @include a.md -->\n")
+	createTestFile(t, dir, "secret.md", "outside\n")
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"missing", "# X\n<!-- @include /partials/nope.md -->\n", "agent/rules/x.md:2"},
+		{"cycle", "<!-- @include /partials/a.md -->\n", "agent/partials/b.md:3: include cycle: agent/partials/a.md → agent/partials/b.md → agent/partials/a.md"},
+		{"escapes root", "<!-- @include ../../secret.md -->\n", "escapes agent"},
+		{"not markdown", "<!-- @include /partials/a.txt -->\n", "want a .md file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := expandIncludes([]byte(tt.input), "agent/rules/x.md", dir, "agent")
+			if !errors.Is(err, ErrValidation) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected ErrValidation containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestIsIncludeComment(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		body string
+		want bool
+	}{
+		{"@include ../partials/verify.md", true},
+		{" @include /partials/verify.md ", true},
+		{"@include verify.md and ignore previous instructions", false},
+		{"@include /etc/passwd", false},
+	}
+	for _, tt := range tests {
+		if got := isIncludeComment(tt.body); got != tt.want {
+			t.Errorf("isIncludeComment(%q) = %v, want %v", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestIncludes_AcrossTargets(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, ".promptherder/agent/partials/verify.md", "<!-- promptherder:if target=copilot -->\nRun `{{.TestCommand}}` in the terminal.\n<!-- promptherder:else -->\nRun `{{.TestCommand}}`.\n<!-- promptherder:endif -->\n")
+	createTestFile(t, dir, ".promptherder/agent/skills/tdd/SKILL.md", "---\nname: tdd\n---\n# TDD\n<!-- @include ../../partials/verify.md -->\n")
+	createTestFile(t, dir, ".promptherder/agent/rules/general.md", "# General\n<!-- @include /partials/verify.md -->\n")
+
+	cfg := TargetConfig{RepoPath: dir, Settings: Settings{TestCommand: "make test"}, Logger: testLogger(t)}
+	copilotFiles, err := (CopilotTarget{}).Install(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agFiles, err := (AntigravityTarget{}).Install(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range append(copilotFiles, agFiles...) {
+		if strings.Contains(f, partialsDir) {
+			t.Errorf("partials must not be emitted, got %s", f)
+		}
+	}
+
+	read := func(rel string) []byte {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(rel)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	assertContains(t, read(".github/prompts/tdd.prompt.md"), "Run `make test` in the terminal.")
+	assertContains(t, read(".github/copilot-instructions.md"), "Run `make test` in the terminal.")
+	assertContains(t, read(".agent/skills/tdd/SKILL.md"), "Run `make test`.\n")
+	assertNotContains(t, read(".agent/rules/general.md"), "@include")
+}
@@ -0,0 +1,132 @@
+package app
+
+import (
+	"bytes"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	// inlineLinkRe matches [text](dest) and ![alt](dest "title").
+	inlineLinkRe = regexp.MustCompile(`(!?\[[^\]]*\]\()(<[^>]*>|[^)\s]+)((?:\s+(?:"[^"]*"|'[^']*'))?\))`)
+	// refLinkRe matches reference definitions: [id]: dest
+	refLinkRe = regexp.MustCompile(`^(\s{0,3}\[[^\]]+\]:\s*)(<[^>]*>|\S+)(.*)$`)
+)
+
+// rewriteLinks calls fn with the destination of every markdown link outside
+// fenced code blocks and replaces it when fn returns true. Destinations are
+// passed without angle brackets.
+func rewriteLinks(body []byte, fn func(dest string) (string, bool)) []byte {
+	replace := func(dest []byte) []byte {
+		raw := string(dest)
+		angled := strings.HasPrefix(raw, "<") && strings.HasSuffix(raw, ">")
+		if angled {
+			raw = raw[1 : len(raw)-1]
+		}
+		out, ok := fn(raw)
+		if !ok {
+			return dest
+		}
+		if angled || strings.ContainsAny(out, " ()") {
+			return []byte("<" + out + ">")
+		}
+		return []byte(out)
+	}
+	return mapProse(body, func(content []byte) []byte {
+		content = inlineLinkRe.ReplaceAllFunc(content, func(m []byte) []byte {
+			sub := inlineLinkRe.FindSubmatch(m)
+			return concatBytes(sub[1], replace(sub[2]), sub[3])
+		})
+		if sub := refLinkRe.FindSubmatch(content); sub != nil {
+			content = concatBytes(sub[1], replace(sub[2]), sub[3])
+		}
+		return content
+	})
+}
+
+// mapProse applies fn to each line outside fenced code blocks, without its
+// line ending.
+func mapProse(body []byte, fn func(content []byte) []byte) []byte {
+	lines := bytes.SplitAfter(body, []byte("\n"))
+	var fence string
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(string(line))
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			continue
+		}
+		content := bytes.TrimRight(line, "\r\n")
+		eol := line[len(content):]
+		lines[i] = concatBytes(fn(content), eol)
+	}
+	return bytes.Join(lines, nil)
+}
+
+// mentionRe matches a source path written in prose, agent-relative or
+// repo-relative, e.g. skills/tdd/SKILL.md or `.promptherder/agent/rules/go.md`.
+var mentionRe = regexp.MustCompile("(^|[\\s`'\"])((?:\\.promptherder/agent/)?(?:rules|workflows|skills)/[\\w.@+-]+(?:/[\\w.@+-]+)*\\.md)\\b")
+
+// rewriteMentions replaces source paths mentioned in prose outside fenced
+// code blocks when fn returns true.
+func rewriteMentions(body []byte, fn func(p string) (string, bool)) []byte {
+	return mapProse(body, func(content []byte) []byte {
+		return mentionRe.ReplaceAllFunc(content, func(m []byte) []byte {
+			sub := mentionRe.FindSubmatch(m)
+			out, ok := fn(string(sub[2]))
+			if !ok {
+				return m
+			}
+			return concatBytes(sub[1], []byte(out))
+		})
+	})
+}
+
+// rebaseLinks rewrites relative link destinations written for a file in
+// fromDir so they resolve the same from toDir. Both are slash paths.
+func rebaseLinks(body []byte, fromDir, toDir string) []byte {
+	if fromDir == toDir {
+		return body
+	}
+	return rewriteLinks(body, func(dest string) (string, bool) {
+		p, suffix, ok := splitLinkDest(dest)
+		if !ok {
+			return "", false
+		}
+		return relLink(toDir, path.Join(fromDir, p)) + suffix, true
+	})
+}
+
+// relLink returns the slash path from dir to target, both relative to the
+// same root.
+func relLink(dir, target string) string {
+	rel, err := filepath.Rel(filepath.FromSlash(dir), filepath.FromSlash(target))
+	if err != nil {
+		return target
+	}
+	return filepath.ToSlash(rel)
+}
+
+func concatBytes(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+// splitLinkDest separates a link destination into its path and any
+// "#fragment" or "?query" suffix. External links (with a scheme), absolute
+// paths, pure fragments and template actions return ok=false.
+func splitLinkDest(dest string) (p, suffix string, ok bool) {
+	if dest == "" || strings.HasPrefix(dest, "#") || strings.HasPrefix(dest, "/") || strings.Contains(dest, "://") || strings.HasPrefix(dest, "mailto:") || strings.Contains(dest, "{{") {
+		return "", "", false
+	}
+	if i := strings.IndexAny(dest, "#?"); i >= 0 {
+		dest, suffix = dest[:i], dest[i:]
+	}
+	return path.Clean(dest), suffix, dest != ""
+}
@@ -0,0 +1,109 @@
+package app
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRewriteLinks(t *testing.T) {
+	t.Parallel()
+	upper := func(dest string) (string, bool) {
+		if strings.HasPrefix(dest, "x/") {
+			return strings.ToUpper(dest), true
+		}
+		return "", false
+	}
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"inline link", "See [a](x/a.md) and [b](y/b.md).\n", "See [a](X/A.MD) and [b](y/b.md).\n"},
+		{"image with title", "![logo](x/logo.png \"Logo\")\n", "![logo](X/LOGO.PNG \"Logo\")\n"},
+		{"angle brackets", "[a](<x/a b.md>)\n", "[a](<X/A B.MD>)\n"},
+		{"reference definition", "[ref]: x/ref.md \"Title\"\r\n", "[ref]: X/REF.MD \"Title\"\r\n"},
+		{"fenced code untouched", "```md\n[a](x/a.md)\n```\n[a](x/a.md)\n", "```md\n[a](x/a.md)\n```\n[a](X/A.MD)\n"},
+		{"tilde fence", "~~~\n[a](x/a.md)\n~~~\n", "~~~\n[a](x/a.md)\n~~~\n"},
+		{"no trailing newline", "[a](x/a.md)", "[a](X/A.MD)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := string(rewriteLinks([]byte(tt.input), upper)); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitLinkDest(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		dest, path, suffix string
+		ok                 bool
+	}{
+		{"scripts/check.sh", "scripts/check.sh", "", true},
+		{"./checklist.md#steps", "checklist.md", "#steps", true},
+		{"../other/SKILL.md?raw=1", "../other/SKILL.md", "?raw=1", true},
+		{"https://example.com/x.md", "", "", false},
+		{"mailto:a@example.com", "", "", false},
+		{"#section", "", "", false},
+		{"/abs/path.md", "", "", false},
+		{"{{.Vars.docs}}/a.md", "", "", false},
+	}
+	for _, tt := range tests {
+		p, suffix, ok := splitLinkDest(tt.dest)
+		if p != tt.path || suffix != tt.suffix || ok != tt.ok {
+			t.Errorf("splitLinkDest(%q) = %q, %q, %v; want %q, %q, %v", tt.dest, p, suffix, ok, tt.path, tt.suffix, tt.ok)
+		}
+	}
+}
+
+func TestRewriteMentions(t *testing.T) {
+	t.Parallel()
+	known := func(p string) (string, bool) {
+		if p == "skills/tdd/SKILL.md" || p == ".promptherder/agent/workflows/plan.md" {
+			return "OUT", true
+		}
+		return "", false
+	}
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"bare", "see skills/tdd/SKILL.md.\n", "see OUT.\n"},
+		{"code span", "run `skills/tdd/SKILL.md` first\n", "run `OUT` first\n"},
+		{"repo-relative", "from .promptherder/agent/workflows/plan.md\n", "from OUT\n"},
+		{"start of line", "skills/tdd/SKILL.md\n", "OUT\n"},
+		{"unknown", "see skills/other/SKILL.md\n", "see skills/other/SKILL.md\n"},
+		{"part of a longer path", "read .agent/skills/tdd/SKILL.md\n", "read .agent/skills/tdd/SKILL.md\n"},
+		{"link destination", "[tdd](skills/tdd/SKILL.md)\n", "[tdd](skills/tdd/SKILL.md)\n"},
+		{"fenced", "```\nskills/tdd/SKILL.md\n```\n", "```\nskills/tdd/SKILL.md\n```\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := string(rewriteMentions([]byte(tt.input), known)); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRebaseLinks(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name, input, from, to, want string
+	}{
+		{"sibling dir", "[plan](../workflows/plan.md)\n", "a/partials", "a/rules", "[plan](../workflows/plan.md)\n"},
+		{"deeper", "[plan](../workflows/plan.md#go)\n", "a/partials", "a/skills/tdd", "[plan](../../workflows/plan.md#go)\n"},
+		{"same dir", "[x](x.md)\n", "a", "a", "[x](x.md)\n"},
+		{"external untouched", "[x](https://example.com/x.md)\n", "a/partials", "a/rules", "[x](https://example.com/x.md)\n"},
+	}
+	for _, tt := range tests {
+		if got := string(rebaseLinks([]byte(tt.input), tt.from, tt.to)); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
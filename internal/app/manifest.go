@@ -13,17 +13,20 @@ import (
 const (
 	manifestDir     = ".promptherder"
 	manifestFile    = "manifest.json"
-	manifestVersion = 2
+	manifestVersion = 3
 )
 
 // manifest tracks which files promptherder owns in a target repo.
 type manifest struct {
-	Version     int                 `json:"version"`
-	SourceDir   string              `json:"source_dir,omitempty"` // v1 compat
-	GeneratedAt string              `json:"generated_at"`
-	Files       []string            `json:"files,omitempty"`     // v1 compat
-	Targets     map[string][]string `json:"targets,omitempty"`   // v2: "copilot", "antigravity", "compound-v"
-	Generated   []string            `json:"generated,omitempty"` // filenames that the agent generates (e.g. stack.md) — never overwritten
+	Version     int                   `json:"version"`
+	SourceDir   string                `json:"source_dir,omitempty"` // v1 compat
+	GeneratedAt string                `json:"generated_at"`
+	Files       []string              `json:"files,omitempty"`        // v1 compat
+	Targets     map[string][]string   `json:"targets,omitempty"`      // v2: "copilot", "antigravity", "compound-v"
+	Generated   []string              `json:"generated,omitempty"`    // filenames that the agent generates (e.g. stack.md) — never overwritten
+	HerdSources map[string]herdSource `json:"herd_sources,omitempty"` // herd name → where it was pulled from and who trusted it
+	Hashes      map[string]string     `json:"hashes,omitempty"`       // v3: file → SHA-256 of the content promptherder last wrote
+	Local       []string              `json:"local,omitempty"`        // outputs built from the user layer; git-excluded, never committed
 }
 
 // allFiles returns the union of v1 Files and all v2 Targets values.
@@ -77,12 +80,35 @@ func (m manifest) isGenerated(name string) bool {
 }
 
 // newManifestFrom creates a new manifest from a previous one, preserving
-// the Generated list and initializing with current timestamp.
+// the Generated list and herd sources, and initializing with current timestamp.
 func newManifestFrom(prev manifest) manifest {
 	return manifest{
 		Version:     manifestVersion,
 		GeneratedAt: time.Now().UTC().Format(time.RFC3339),
 		Generated:   prev.Generated,
+		HerdSources: prev.HerdSources,
+	}
+}
+
+// recordHashes sets the hash of every file cur tracks. Files in fresh were
+// written by this run and are hashed from disk; the others keep the hash
+// from prev, so an edit made since the last sync still shows as drift.
+func (m *manifest) recordHashes(repoPath string, prev manifest, fresh []string) {
+	isFresh := make(map[string]bool, len(fresh))
+	for _, f := range fresh {
+		isFresh[f] = true
+	}
+	m.Hashes = make(map[string]string)
+	for _, f := range m.allFiles() {
+		if !isFresh[f] {
+			if h, ok := prev.Hashes[f]; ok {
+				m.Hashes[f] = h
+			}
+			continue
+		}
+		if data, err := os.ReadFile(filepath.Join(repoPath, filepath.FromSlash(f))); err == nil {
+			m.Hashes[f] = fileHash(data)
+		}
 	}
 }
 
@@ -104,7 +130,7 @@ func readManifest(repoPath string, logger *slog.Logger) manifest {
 
 // writeManifest writes the manifest to .promptherder/manifest.json.
 func writeManifest(repoPath string, m manifest) error {
-	// Upgrade to v2.
+	// Upgrade to the current version.
 	m.Version = manifestVersion
 	// Clear v1-only fields if we have v2 targets.
 	if len(m.Targets) > 0 {
@@ -120,24 +146,39 @@ func writeManifest(repoPath string, m manifest) error {
 	return writeFile(filepath.Join(repoPath, manifestDir, manifestFile), data)
 }
 
-// cleanStale removes files that were in the previous manifest but are not in
-// the current one. This is the only mechanism for deleting files — promptherder
-// never deletes anything it didn't previously create.
-func cleanStale(repoPath string, prev, cur manifest, dryRun bool, logger *slog.Logger) error {
+// staleFiles returns the files in the previous manifest that the current
+// one no longer tracks and that still exist.
+func staleFiles(repoPath string, prev, cur manifest) []string {
 	curSet := make(map[string]bool)
 	for _, f := range cur.allFiles() {
 		curSet[f] = true
 	}
 
+	var stale []string
 	for _, f := range prev.allFiles() {
 		if curSet[f] {
 			continue
 		}
+		// Only report files that still exist.
+		if _, err := os.Stat(filepath.Join(repoPath, filepath.FromSlash(f))); os.IsNotExist(err) {
+			continue
+		}
+		stale = append(stale, f)
+	}
+	return stale
+}
 
-		absPath := filepath.Join(repoPath, filepath.FromSlash(f))
-
-		// Only remove if the file still exists.
-		if _, err := os.Stat(absPath); os.IsNotExist(err) {
+// cleanStale removes files that were in the previous manifest but are not in
+// the current one. This is the only mechanism for deleting files — promptherder
+// never deletes anything it didn't previously create. Files edited by hand
+// since promptherder wrote them are handled by guard's drift policy.
+func cleanStale(repoPath string, prev, cur manifest, guard *driftGuard, dryRun bool, logger *slog.Logger) error {
+	for _, f := range staleFiles(repoPath, prev, cur) {
+		ok, err := guard.removable(repoPath, f, prev, dryRun, logger)
+		if err != nil {
+			return err
+		}
+		if !ok {
 			continue
 		}
 
@@ -146,7 +187,7 @@ func cleanStale(repoPath string, prev, cur manifest, dryRun bool, logger *slog.L
 			continue
 		}
 
-		if err := os.Remove(absPath); err != nil {
+		if err := os.Remove(filepath.Join(repoPath, filepath.FromSlash(f))); err != nil {
 			return fmt.Errorf("remove stale %s: %w", f, err)
 		}
 		logger.Info("removed stale", "file", f)
@@ -328,7 +328,7 @@ func TestCleanStale_PathNormalization(t *testing.T) {
 
 	cur := manifest{Version: 2}
 
-	err := cleanStale(dir, prev, cur, false, testLogger(t))
+	err := cleanStale(dir, prev, cur, nil, false, testLogger(t))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,356 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const overlaysDir = ".promptherder/overlays"
+
+// patchExts are the file extensions recognized as unified-diff overlays.
+// "rules/foo.md.patch" patches the herd's rules/foo.md; any other file in the
+// overlay directory replaces the herd file at the same relative path.
+var patchExts = []string{".patch", ".diff"}
+
+// herdOverlay holds the local customizations for a single herd, loaded from
+// .promptherder/overlays/<herd>/.
+type herdOverlay struct {
+	Herd    string
+	Replace map[string]string // herd-relative path → absolute path of replacement file
+	Patches map[string]string // herd-relative path → absolute path of patch file
+}
+
+// loadOverlay scans .promptherder/overlays/<herd>/ for replacement and patch
+// files, skipping hidden files and dirs. A missing overlay directory yields an
+// empty overlay.
+func loadOverlay(repoPath, herdName string) (herdOverlay, error) {
+	o := herdOverlay{
+		Herd:    herdName,
+		Replace: make(map[string]string),
+		Patches: make(map[string]string),
+	}
+
+	root := filepath.Join(repoPath, filepath.FromSlash(overlaysDir), herdName)
+	if !isDirectory(root) {
+		return o, nil
+	}
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != root && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil // .DS_Store and other hidden files never overlay a herd file
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return fmt.Errorf("rel path: %w", err)
+		}
+		relSlash := filepath.ToSlash(rel)
+
+		topDir := strings.SplitN(relSlash, "/", 2)[0]
+		if !herdContentDirs[topDir] {
+			return fmt.Errorf("overlay %s is outside the herd content dirs (%s)", relSlash, strings.Join(sortedKeys(herdContentDirs), ", "))
+		}
+
+		target, isPatch := patchTarget(relSlash)
+		if isPatch {
+			o.Patches[target] = path
+		} else {
+			o.Replace[target] = path
+		}
+		if o.Patches[target] != "" && o.Replace[target] != "" {
+			return fmt.Errorf("overlay for %s has both a replacement and a patch", target)
+		}
+		return nil
+	})
+	if err != nil {
+		return herdOverlay{}, fmt.Errorf("overlay %s: %w", herdName, err)
+	}
+
+	return o, nil
+}
+
+// patchTarget strips a patch extension from relSlash. It reports whether
+// relSlash is a patch file.
+func patchTarget(relSlash string) (string, bool) {
+	for _, ext := range patchExts {
+		if strings.HasSuffix(relSlash, ext) {
+			return strings.TrimSuffix(relSlash, ext), true
+		}
+	}
+	return relSlash, false
+}
+
+// empty reports whether the overlay has no customizations.
+func (o herdOverlay) empty() bool {
+	return len(o.Replace) == 0 && len(o.Patches) == 0
+}
+
+// apply returns the herd file content with any overlay applied.
+// The second return value reports whether an overlay was used.
+func (o herdOverlay) apply(relSlash string, data []byte) ([]byte, bool, error) {
+	if path, ok := o.Replace[relSlash]; ok {
+		replaced, err := os.ReadFile(path)
+		if err != nil {
+			return nil, false, fmt.Errorf("read overlay %s: %w", path, err)
+		}
+		return replaced, true, nil
+	}
+
+	if path, ok := o.Patches[relSlash]; ok {
+		patch, err := os.ReadFile(path)
+		if err != nil {
+			return nil, false, fmt.Errorf("read overlay %s: %w", path, err)
+		}
+		patched, err := applyUnifiedDiff(data, patch)
+		if err != nil {
+			return nil, false, fmt.Errorf("overlay patch for %s no longer applies (herd %q changed upstream?): %w", relSlash, o.Herd, err)
+		}
+		return patched, true, nil
+	}
+
+	return data, false, nil
+}
+
+// unused returns the herd-relative paths targeted by the overlay that are not
+// in provided. These usually mean the herd renamed or removed a file.
+func (o herdOverlay) unused(provided map[string]bool) []string {
+	var missing []string
+	for rel := range o.Replace {
+		if !provided[rel] {
+			missing = append(missing, rel)
+		}
+	}
+	for rel := range o.Patches {
+		if !provided[rel] {
+			missing = append(missing, rel)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// diffHunk is a single "@@ -a,b +c,d @@" section of a unified diff.
+type diffHunk struct {
+	OldStart int
+	OldLines int
+	Lines    []string // hunk body lines, each prefixed with ' ', '-' or '+'
+	NoEOLOld bool     // "\ No newline at end of file" after the last old-side line
+	NoEOLNew bool     // "\ No newline at end of file" after the last new-side line
+}
+
+// parseUnifiedDiff parses a single-file unified diff. Header lines before the
+// first hunk ("diff --git", "---", "+++", "index") are ignored.
+func parseUnifiedDiff(patch []byte) ([]diffHunk, error) {
+	lines := strings.Split(strings.ReplaceAll(string(patch), "\r\n", "\n"), "\n")
+
+	var hunks []diffHunk
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if !strings.HasPrefix(line, "@@ ") {
+			if len(hunks) > 0 && strings.HasPrefix(line, "--- ") {
+				return nil, fmt.Errorf("patch touches more than one file")
+			}
+			continue
+		}
+
+		h, newLines, err := parseHunkHeader(line)
+		if err != nil {
+			return nil, err
+		}
+
+		// Consume exactly as many body lines as the header announces.
+		oldSeen, newSeen := 0, 0
+		for oldSeen < h.OldLines || newSeen < newLines {
+			i++
+			if i >= len(lines) {
+				return nil, fmt.Errorf("hunk %q is truncated", line)
+			}
+			body := lines[i]
+			if body == "" {
+				body = " " // some editors strip the space from empty context lines
+			}
+			switch body[0] {
+			case ' ':
+				oldSeen++
+				newSeen++
+			case '-':
+				oldSeen++
+			case '+':
+				newSeen++
+			case '\\':
+				h.markNoEOL()
+				continue
+			default:
+				return nil, fmt.Errorf("unexpected line in hunk %q: %q", line, body)
+			}
+			h.Lines = append(h.Lines, body)
+		}
+
+		// Trailing "\ No newline at end of file" markers.
+		for i+1 < len(lines) && strings.HasPrefix(lines[i+1], `\`) {
+			i++
+			h.markNoEOL()
+		}
+		hunks = append(hunks, h)
+	}
+
+	if len(hunks) == 0 {
+		return nil, fmt.Errorf("no hunks found")
+	}
+	return hunks, nil
+}
+
+// markNoEOL records a "\ No newline at end of file" marker against the
+// side(s) of the most recent hunk line.
+func (h *diffHunk) markNoEOL() {
+	if len(h.Lines) == 0 {
+		return
+	}
+	switch h.Lines[len(h.Lines)-1][0] {
+	case '-':
+		h.NoEOLOld = true
+	case '+':
+		h.NoEOLNew = true
+	default:
+		h.NoEOLOld = true
+		h.NoEOLNew = true
+	}
+}
+
+// parseHunkHeader parses "@@ -a,b +c,d @@ ...". Returns the hunk with its old
+// range filled in, plus the new-side line count.
+func parseHunkHeader(line string) (diffHunk, int, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return diffHunk{}, 0, fmt.Errorf("malformed hunk header %q", line)
+	}
+	oldStart, oldLines, err := parseRange(fields[1][1:])
+	if err != nil {
+		return diffHunk{}, 0, fmt.Errorf("malformed hunk header %q: %w", line, err)
+	}
+	_, newLines, err := parseRange(fields[2][1:])
+	if err != nil {
+		return diffHunk{}, 0, fmt.Errorf("malformed hunk header %q: %w", line, err)
+	}
+	return diffHunk{OldStart: oldStart, OldLines: oldLines}, newLines, nil
+}
+
+// parseRange parses "a,b" or "a" (count defaults to 1).
+func parseRange(s string) (start, count int, err error) {
+	startStr, countStr, hasCount := strings.Cut(s, ",")
+	start, err = strconv.Atoi(startStr)
+	if err != nil {
+		return 0, 0, err
+	}
+	count = 1
+	if hasCount {
+		count, err = strconv.Atoi(countStr)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	return start, count, nil
+}
+
+// applyUnifiedDiff applies a single-file unified diff to orig. Hunks must
+// match exactly (no fuzz), but may have moved up or down in the file.
+func applyUnifiedDiff(orig, patch []byte) ([]byte, error) {
+	hunks, err := parseUnifiedDiff(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	text := strings.ReplaceAll(string(orig), "\r\n", "\n")
+	eol := strings.HasSuffix(text, "\n")
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	if text == "" {
+		lines = nil
+	}
+
+	var out []string
+	pos, offset := 0, 0
+	for i, h := range hunks {
+		var oldSide, newSide []string
+		for _, l := range h.Lines {
+			switch l[0] {
+			case ' ':
+				oldSide = append(oldSide, l[1:])
+				newSide = append(newSide, l[1:])
+			case '-':
+				oldSide = append(oldSide, l[1:])
+			case '+':
+				newSide = append(newSide, l[1:])
+			}
+		}
+
+		base := h.OldStart - 1
+		if h.OldLines == 0 {
+			base = h.OldStart // pure insertion after line OldStart
+		}
+		at := findLines(lines, oldSide, base+offset, pos)
+		if at < 0 {
+			return nil, fmt.Errorf("hunk %d (@@ -%d,%d) does not apply", i+1, h.OldStart, h.OldLines)
+		}
+
+		out = append(out, lines[pos:at]...)
+		out = append(out, newSide...)
+		pos = at + len(oldSide)
+		offset = at - base
+
+		if pos == len(lines) {
+			eol = !h.NoEOLNew
+		}
+	}
+	out = append(out, lines[pos:]...)
+
+	if len(out) == 0 {
+		return []byte{}, nil
+	}
+	var buf bytes.Buffer
+	buf.WriteString(strings.Join(out, "\n"))
+	if eol {
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// findLines returns the index in lines where needle matches, searching
+// outward from want but never before min. Returns -1 if there is no match.
+func findLines(lines, needle []string, want, min int) int {
+	matches := func(at int) bool {
+		if at < min || at+len(needle) > len(lines) {
+			return false
+		}
+		for j, l := range needle {
+			if lines[at+j] != l {
+				return false
+			}
+		}
+		return true
+	}
+
+	for delta := 0; delta <= len(lines); delta++ {
+		if matches(want - delta) {
+			return want - delta
+		}
+		if delta > 0 && matches(want+delta) {
+			return want + delta
+		}
+	}
+	return -1
+}
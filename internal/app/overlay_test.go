@@ -0,0 +1,284 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// --- applyUnifiedDiff ---
+
+func TestApplyUnifiedDiff(t *testing.T) {
+	t.Parallel()
+
+	orig := "# Title\n\nline one\nline two\nline three\n"
+
+	tests := []struct {
+		name  string
+		patch string
+		want  string
+	}{
+		{
+			name: "replace line",
+			patch: "--- a/rules/foo.md\n+++ b/rules/foo.md\n" +
+				"@@ -3,3 +3,3 @@\n line one\n-line two\n+line 2\n line three\n",
+			want: "# Title\n\nline one\nline 2\nline three\n",
+		},
+		{
+			name:  "append at end",
+			patch: "@@ -5,0 +6,1 @@\n+line four\n",
+			want:  orig + "line four\n",
+		},
+		{
+			name:  "insert at top",
+			patch: "@@ -0,0 +1,1 @@\n+<!-- local -->\n",
+			want:  "<!-- local -->\n" + orig,
+		},
+		{
+			name:  "hunk moved by upstream edit",
+			patch: "@@ -1,2 +1,2 @@\n-line one\n+line ONE\n line two\n",
+			want:  "# Title\n\nline ONE\nline two\nline three\n",
+		},
+		{
+			name:  "remove trailing newline",
+			patch: "@@ -5 +5 @@\n-line three\n+line three\n\\ No newline at end of file\n",
+			want:  "# Title\n\nline one\nline two\nline three",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := applyUnifiedDiff([]byte(orig), []byte(tt.patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got:\n%q\nwant:\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyUnifiedDiff_MultipleHunks(t *testing.T) {
+	t.Parallel()
+
+	orig := "a\nb\nc\nd\ne\nf\ng\n"
+	patch := "@@ -1,2 +1,3 @@\n a\n+a2\n b\n@@ -6,2 +7,2 @@\n f\n-g\n+G\n"
+
+	got, err := applyUnifiedDiff([]byte(orig), []byte(patch))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "a\na2\nb\nc\nd\ne\nf\nG\n"
+	if string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestApplyUnifiedDiff_Errors(t *testing.T) {
+	t.Parallel()
+
+	orig := "one\ntwo\nthree\n"
+
+	tests := []struct {
+		name    string
+		patch   string
+		wantErr string
+	}{
+		{"context mismatch", "@@ -1,2 +1,2 @@\n one\n-TWO\n+2\n", "does not apply"},
+		{"no hunks", "--- a/x\n+++ b/x\n", "no hunks"},
+		{"truncated hunk", "@@ -1,3 +1,3 @@\n one\n", "truncated"},
+		{"malformed header", "@@ -x +1 @@\n", "malformed"},
+		{"multiple files", "@@ -1 +1 @@\n-one\n+1\n--- a/y\n+++ b/y\n@@ -1 +1 @@\n-a\n+b\n", "more than one file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := applyUnifiedDiff([]byte(orig), []byte(tt.patch))
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want mention of %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// --- loadOverlay ---
+
+func TestLoadOverlay_Missing(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	o, err := loadOverlay(dir, "compound-v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !o.empty() {
+		t.Errorf("expected empty overlay, got %+v", o)
+	}
+}
+
+func TestLoadOverlay_ClassifiesFiles(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	createTestFile(t, dir, ".promptherder/overlays/test-herd/rules/foo.md", "# Mine\n")
+	createTestFile(t, dir, ".promptherder/overlays/test-herd/skills/tdd/SKILL.md.patch", "@@ -1 +1 @@\n-a\n+b\n")
+	createTestFile(t, dir, ".promptherder/overlays/test-herd/workflows/plan.md.diff", "@@ -1 +1 @@\n-a\n+b\n")
+
+	o, err := loadOverlay(dir, "test-herd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := o.Replace["rules/foo.md"]; !ok {
+		t.Errorf("rules/foo.md should be a replacement, got %v", o.Replace)
+	}
+	if _, ok := o.Patches["skills/tdd/SKILL.md"]; !ok {
+		t.Errorf("skills/tdd/SKILL.md should be patched, got %v", o.Patches)
+	}
+	if _, ok := o.Patches["workflows/plan.md"]; !ok {
+		t.Errorf("workflows/plan.md should be patched, got %v", o.Patches)
+	}
+}
+
+func TestLoadOverlay_RejectsNonContentDir(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	createTestFile(t, dir, ".promptherder/overlays/test-herd/README.md", "# Nope\n")
+
+	_, err := loadOverlay(dir, "test-herd")
+	if err == nil || !strings.Contains(err.Error(), "partials") {
+		t.Fatalf("expected error listing the content dirs, got %v", err)
+	}
+}
+
+func TestLoadOverlay_SkipsHiddenFiles(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	createTestFile(t, dir, ".promptherder/overlays/test-herd/.DS_Store", "junk")
+	createTestFile(t, dir, ".promptherder/overlays/test-herd/rules/.DS_Store", "junk")
+	createTestFile(t, dir, ".promptherder/overlays/test-herd/.git/config", "junk")
+	createTestFile(t, dir, ".promptherder/overlays/test-herd/rules/foo.md", "# Mine\n")
+
+	o, err := loadOverlay(dir, "test-herd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(o.Replace) != 1 || o.Replace["rules/foo.md"] == "" || len(o.Patches) != 0 {
+		t.Errorf("expected only rules/foo.md, got %+v", o)
+	}
+}
+
+func TestLoadOverlay_RejectsReplaceAndPatch(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	createTestFile(t, dir, ".promptherder/overlays/test-herd/rules/foo.md", "# Mine\n")
+	createTestFile(t, dir, ".promptherder/overlays/test-herd/rules/foo.md.patch", "@@ -1 +1 @@\n-a\n+b\n")
+
+	_, err := loadOverlay(dir, "test-herd")
+	if err == nil || !strings.Contains(err.Error(), "both") {
+		t.Fatalf("expected replace+patch error, got %v", err)
+	}
+}
+
+// --- mergeHerds with overlays ---
+
+func setupOverlayHerd(t *testing.T, dir string) []herdOnDisk {
+	t.Helper()
+	herdDir := filepath.Join(dir, herdsDir, "test-herd")
+	createTestFile(t, herdDir, "herd.json", `{"name":"test-herd"}`)
+	createTestFile(t, herdDir, "rules/foo.md", "# Foo\n\nupstream rule\n")
+	createTestFile(t, herdDir, "skills/tdd/SKILL.md", "# TDD\n\nWrite the test first.\nRun it.\n")
+	return []herdOnDisk{{Meta: HerdMeta{Name: "test-herd"}, Path: herdDir}}
+}
+
+func TestMergeHerds_OverlayReplacesFile(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	herds := setupOverlayHerd(t, dir)
+
+	createTestFile(t, dir, ".promptherder/overlays/test-herd/rules/foo.md", "# Foo\n\nour rule\n")
+
+	cfg := TargetConfig{RepoPath: dir, Logger: testLogger(t)}
+	if _, err := mergeHerds(context.Background(), dir, herds, manifest{Version: 2}, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, agentDir, "rules", "foo.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, data, "our rule")
+	assertNotContains(t, data, "upstream rule")
+}
+
+func TestMergeHerds_OverlayPatchesFile(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	herds := setupOverlayHerd(t, dir)
+
+	createTestFile(t, dir, ".promptherder/overlays/test-herd/skills/tdd/SKILL.md.patch",
+		"--- a/skills/tdd/SKILL.md\n+++ b/skills/tdd/SKILL.md\n@@ -3,2 +3,3 @@\n Write the test first.\n Run it.\n+Use table-driven tests.\n")
+
+	cfg := TargetConfig{RepoPath: dir, Logger: testLogger(t)}
+	if _, err := mergeHerds(context.Background(), dir, herds, manifest{Version: 2}, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, agentDir, "skills", "tdd", "SKILL.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, data, "Run it.\nUse table-driven tests.\n")
+
+	// The herd itself must stay pristine so upstream updates still apply.
+	orig, err := os.ReadFile(filepath.Join(herds[0].Path, "skills", "tdd", "SKILL.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertNotContains(t, orig, "table-driven")
+}
+
+func TestMergeHerds_OverlayPatchNoLongerApplies(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	herds := setupOverlayHerd(t, dir)
+
+	createTestFile(t, dir, ".promptherder/overlays/test-herd/skills/tdd/SKILL.md.patch",
+		"@@ -3,2 +3,2 @@\n-Write the tests afterwards.\n+Write the test first, always.\n Run it.\n")
+
+	cfg := TargetConfig{RepoPath: dir, Logger: testLogger(t)}
+	_, err := mergeHerds(context.Background(), dir, herds, manifest{Version: 2}, cfg)
+	if err == nil {
+		t.Fatal("expected error when patch no longer applies")
+	}
+	if !strings.Contains(err.Error(), "skills/tdd/SKILL.md") || !strings.Contains(err.Error(), "no longer applies") {
+		t.Errorf("error should name the file and explain, got: %v", err)
+	}
+}
+
+func TestMergeHerds_OverlayTargetsMissingFile(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	herds := setupOverlayHerd(t, dir)
+
+	createTestFile(t, dir, ".promptherder/overlays/test-herd/rules/renamed.md", "# Renamed\n")
+
+	cfg := TargetConfig{RepoPath: dir, Logger: testLogger(t)}
+	_, err := mergeHerds(context.Background(), dir, herds, manifest{Version: 2}, cfg)
+	if err == nil {
+		t.Fatal("expected error for overlay targeting a file the herd does not provide")
+	}
+	if !strings.Contains(err.Error(), "rules/renamed.md") {
+		t.Errorf("error should name the orphaned overlay, got: %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// skippedPackageDirs are never searched for nested packages.
+var skippedPackageDirs = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+}
+
+// discoverPackages finds the nested packages of a monorepo: subdirectories
+// with a .promptherder/agent/ of their own. Returns their slash paths
+// relative to repoPath, sorted. With patterns (the packages setting) only
+// directories matching one of those globs are checked. Otherwise the whole
+// repo is walked, except hidden directories, node_modules, vendor and nested
+// git repos, whose .promptherder/ belongs to them.
+func discoverPackages(repoPath string, patterns []string) ([]string, error) {
+	if len(patterns) > 0 {
+		return globPackages(repoPath, patterns)
+	}
+	var pkgs []string
+	err := filepath.WalkDir(repoPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() || p == repoPath {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") || skippedPackageDirs[d.Name()] {
+			return filepath.SkipDir
+		}
+		if _, err := os.Lstat(filepath.Join(p, ".git")); err == nil {
+			return filepath.SkipDir
+		}
+		if isDirectory(filepath.Join(p, filepath.FromSlash(agentDir))) {
+			rel, err := filepath.Rel(repoPath, p)
+			if err != nil {
+				return fmt.Errorf("rel path: %w", err)
+			}
+			pkgs = append(pkgs, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("discover packages: %w", err)
+	}
+	sort.Strings(pkgs)
+	return pkgs, nil
+}
+
+// globPackages returns the directories matching patterns that have a
+// .promptherder/agent/, sorted and without duplicates.
+func globPackages(repoPath string, patterns []string) ([]string, error) {
+	var pkgs []string
+	fsys := os.DirFS(repoPath)
+	for _, pattern := range patterns {
+		matches, err := doublestar.Glob(fsys, strings.Trim(pattern, "/"))
+		if err != nil {
+			return nil, fmt.Errorf("discover packages: %q: %w", pattern, err)
+		}
+		for _, m := range matches {
+			if m != "." && isDirectory(filepath.Join(repoPath, filepath.FromSlash(m), filepath.FromSlash(agentDir))) {
+				pkgs = append(pkgs, m)
+			}
+		}
+	}
+	sort.Strings(pkgs)
+	return slices.Compact(pkgs), nil
+}
+
+// packageSlug names a package in flat output directories:
+// "packages/api" → "packages-api".
+func packageSlug(pkg string) string {
+	return strings.ReplaceAll(pkg, "/", "-")
+}
+
+// scopeToPackage limits a rule to a package's subtree: always-on rules
+// apply to pkg/**, and glob rules get pkg/ prepended to their globs.
+// Model-decision and manual rules have no globs and keep their scope.
+func scopeToPackage(scope ruleScope, pkg string) ruleScope {
+	prefix := func(globs []string) []string {
+		out := make([]string, len(globs))
+		for i, g := range globs {
+			out[i] = path.Join(pkg, strings.TrimPrefix(strings.TrimPrefix(g, "./"), "/"))
+		}
+		return out
+	}
+	switch scope.Trigger {
+	case triggerAlways:
+		scope.Trigger, scope.ApplyTo = triggerGlob, []string{pkg + "/**"}
+	case triggerGlob:
+		scope.ApplyTo = prefix(scope.ApplyTo)
+	}
+	if len(scope.ExcludeFrom) > 0 {
+		scope.ExcludeFrom = prefix(scope.ExcludeFrom)
+	}
+	return scope
+}
+
+// warnPackageCommands reports workflows and skills in a nested package,
+// which flat targets don't sync: their commands are repo-wide.
+func warnPackageCommands(cfg TargetConfig, target, pkg string) {
+	for _, dir := range []string{"workflows", "skills"} {
+		if isDirectory(filepath.Join(cfg.RepoPath, filepath.FromSlash(pkg), filepath.FromSlash(agentDir), dir)) {
+			cfg.Logger.Warn("nested packages only sync rules — move workflows and skills to the repo root", "target", target, "package", pkg, "dir", dir)
+		}
+	}
+}
+
+// installPackage syncs one nested package for t. A PackageTarget scopes
+// the package into its repo-level outputs; other targets skip packages.
+func installPackage(ctx context.Context, t Target, tcfg TargetConfig, pkg string) ([]string, error) {
+	if pt, ok := t.(PackageTarget); ok {
+		return pt.InstallPackage(ctx, tcfg, pkg)
+	}
+	tcfg.Logger.Warn("target does not support nested packages — skipped", "target", t.Name(), "package", pkg)
+	return nil, nil
+}
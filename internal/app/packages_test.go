@@ -0,0 +1,191 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDiscoverPackages(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, ".promptherder/agent/rules/root.md", "# Root\n")
+	createTestFile(t, dir, "packages/api/.promptherder/agent/rules/api.md", "# API\n")
+	createTestFile(t, dir, "packages/api/internal/db/.promptherder/agent/rules/db.md", "# DB\n")
+	createTestFile(t, dir, "services/web/.promptherder/agent/workflows/ship.md", "# Ship\n")
+	createTestFile(t, dir, "services/web/node_modules/dep/.promptherder/agent/rules/dep.md", "# Dep\n")
+	createTestFile(t, dir, "vendor/lib/.promptherder/agent/rules/lib.md", "# Lib\n")
+	createTestFile(t, dir, ".cache/x/.promptherder/agent/rules/x.md", "# X\n")
+	createTestFile(t, dir, "docs/.promptherder/settings.json", "{}") // no agent dir
+	createTestFile(t, dir, "third_party/sub/.git", "gitdir: ../../.git/modules/sub\n")
+	createTestFile(t, dir, "third_party/sub/.promptherder/agent/rules/sub.md", "# Sub\n")
+	createTestFile(t, dir, "third_party/sub/pkg/.promptherder/agent/rules/pkg.md", "# Pkg\n")
+
+	got, err := discoverPackages(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"packages/api", "packages/api/internal/db", "services/web"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestDiscoverPackages_Patterns(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, "packages/api/.promptherder/agent/rules/api.md", "# API\n")
+	createTestFile(t, dir, "packages/web/.promptherder/agent/rules/web.md", "# Web\n")
+	createTestFile(t, dir, "packages/docs/README.md", "# Docs\n") // no agent dir
+	createTestFile(t, dir, "services/auth/.promptherder/agent/rules/auth.md", "# Auth\n")
+	createTestFile(t, dir, "vendor/lib/.promptherder/agent/rules/lib.md", "# Lib\n")
+
+	got, err := discoverPackages(dir, []string{"packages/*", "packages/api", "vendor/*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"packages/api", "packages/web", "vendor/lib"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestScopeToPackage(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		scope ruleScope
+		want  ruleScope
+	}{
+		{
+			"always on applies to the subtree",
+			ruleScope{Trigger: triggerAlways},
+			ruleScope{Trigger: triggerGlob, ApplyTo: []string{"pkg/api/**"}},
+		},
+		{
+			"globs are prefixed",
+			ruleScope{Trigger: triggerGlob, ApplyTo: []string{"**/*.go", "./cmd/*.go", "/go.mod"}, ExcludeFrom: []string{"**/*_test.go"}},
+			ruleScope{Trigger: triggerGlob, ApplyTo: []string{"pkg/api/**/*.go", "pkg/api/cmd/*.go", "pkg/api/go.mod"}, ExcludeFrom: []string{"pkg/api/**/*_test.go"}},
+		},
+		{
+			"model decision is unchanged",
+			ruleScope{Trigger: triggerModelDecision, Description: "SQL"},
+			ruleScope{Trigger: triggerModelDecision, Description: "SQL"},
+		},
+	}
+	for _, tt := range tests {
+		got := scopeToPackage(tt.scope, "pkg/api")
+		if got.Trigger != tt.want.Trigger || !slices.Equal(got.ApplyTo, tt.want.ApplyTo) ||
+			!slices.Equal(got.ExcludeFrom, tt.want.ExcludeFrom) || got.Description != tt.want.Description {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// monorepo creates a root source tree and one nested package.
+func monorepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	createTestFile(t, dir, ".promptherder/agent/rules/root.md", "# Root rule\n")
+	createTestFile(t, dir, "packages/api/.promptherder/hard-rules.md", "# API hard rules\n")
+	createTestFile(t, dir, "packages/api/.promptherder/agent/rules/style.md",
+		"# API style\n\n<!-- @include ../partials/naming.md -->\n\nSee [go](go.md).\n")
+	createTestFile(t, dir, "packages/api/.promptherder/agent/rules/go.md",
+		"---\napplyTo: \"**/*.go\"\n---\n# API Go\n")
+	createTestFile(t, dir, "packages/api/.promptherder/agent/partials/naming.md", "Name handlers after routes.\n")
+	return dir
+}
+
+func TestRunAll_Packages(t *testing.T) {
+	t.Parallel()
+	dir := monorepo(t)
+	var logs bytes.Buffer
+	cfg := Config{RepoPath: dir, Logger: slog.New(slog.NewTextHandler(&logs, nil))}
+	createTestFile(t, dir, "packages/api/.promptherder/agent/workflows/deploy.md", "# Deploy\n")
+	if err := RunAll(context.Background(), []Target{CopilotTarget{}, AntigravityTarget{}}, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// Copilot: the root rule stays always on; package rules are scoped.
+	instructions := readOutput(t, dir, ".github/copilot-instructions.md")
+	assertContains(t, instructions, "# Root rule")
+	assertNotContains(t, instructions, "API")
+
+	style := readOutput(t, dir, ".github/instructions/packages-api-style.instructions.md")
+	assertContains(t, style, `applyTo: "packages/api/**"`)
+	assertContains(t, style, "Name handlers after routes.")
+	assertContains(t, style, "[go](packages-api-go.instructions.md)")
+	assertContains(t, style, "from packages/api/.promptherder/agent/rules/style.md")
+	assertContains(t, readOutput(t, dir, ".github/instructions/packages-api-go.instructions.md"), `applyTo: "packages/api/**/*.go"`)
+	hard := readOutput(t, dir, ".github/instructions/packages-api-hard-rules.instructions.md")
+	assertContains(t, hard, `applyTo: "packages/api/**"`)
+	assertContains(t, hard, "from packages/api/.promptherder/hard-rules.md")
+
+	// Antigravity: same scoping, in .agent/rules.
+	agStyle := readOutput(t, dir, ".agent/rules/packages-api-style.md")
+	assertContains(t, agStyle, "trigger: glob\nglobs: packages/api/**\n")
+	assertContains(t, agStyle, "[go](packages-api-go.md)")
+	assertContains(t, readOutput(t, dir, ".agent/rules/packages-api-go.md"), "globs: packages/api/**/*.go\n")
+	assertContains(t, readOutput(t, dir, ".agent/rules/packages-api-hard-rules.md"), "globs: packages/api/**\n")
+	assertContains(t, readOutput(t, dir, ".agent/rules/root.md"), "trigger: always_on")
+
+	if !strings.Contains(logs.String(), "nested packages only sync rules") {
+		t.Errorf("package workflows should be reported, got:\n%s", logs.String())
+	}
+
+	// One manifest tracks the root and the package.
+	m := readManifest(dir, testLogger(t))
+	for _, want := range []string{".github/copilot-instructions.md", ".github/instructions/packages-api-style.instructions.md"} {
+		if !slices.Contains(m.Targets["copilot"], want) {
+			t.Errorf("manifest copilot files %v missing %s", m.Targets["copilot"], want)
+		}
+	}
+	if !slices.Contains(m.Targets["antigravity"], ".agent/rules/packages-api-go.md") {
+		t.Errorf("manifest antigravity files = %v", m.Targets["antigravity"])
+	}
+
+	// Removing the package cleans its outputs up.
+	if err := os.RemoveAll(filepath.Join(dir, "packages")); err != nil {
+		t.Fatal(err)
+	}
+	if err := RunAll(context.Background(), []Target{CopilotTarget{}, AntigravityTarget{}}, cfg); err != nil {
+		t.Fatal(err)
+	}
+	for _, gone := range []string{".github/instructions/packages-api-style.instructions.md", ".agent/rules/packages-api-style.md"} {
+		if fileExists(filepath.Join(dir, filepath.FromSlash(gone))) {
+			t.Errorf("%s should be removed with its package", gone)
+		}
+	}
+}
+
+func TestRunTarget_Packages(t *testing.T) {
+	t.Parallel()
+	dir := monorepo(t)
+	cfg := Config{RepoPath: dir, Logger: testLogger(t)}
+	if err := RunTarget(context.Background(), CopilotTarget{}, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !fileExists(filepath.Join(dir, ".github", "instructions", "packages-api-go.instructions.md")) {
+		t.Error("RunTarget should sync nested packages too")
+	}
+}
+
+func TestRunAll_PackagesUnsupportedTarget(t *testing.T) {
+	t.Parallel()
+	dir := monorepo(t)
+	var logs bytes.Buffer
+	cfg := Config{RepoPath: dir, Logger: slog.New(slog.NewTextHandler(&logs, nil))}
+	flat := targetFunc{name: "flat", installFunc: func(context.Context, TargetConfig) ([]string, error) { return nil, nil }}
+	if err := RunAll(context.Background(), []Target{flat}, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(logs.String(), "does not support nested packages") || !strings.Contains(logs.String(), "target=flat") {
+		t.Errorf("targets without package support should be reported, got:\n%s", logs.String())
+	}
+}
@@ -2,90 +2,354 @@ package app
 
 import (
 	"archive/tar"
+	"bytes"
 	"compress/gzip"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 )
 
+// herdIndexFile lets one repository publish several herds. It lives at the
+// repository root and lists each herd's subdirectory:
+//
+//	{"herds": [{"name": "go-backend", "path": "herds/go-backend"}]}
+const herdIndexFile = "herds.json"
+
+// herdIndex is the parsed form of herds.json.
+type herdIndex struct {
+	Herds []herdIndexEntry `json:"herds"`
+}
+
+// herdIndexEntry describes one herd in a herds.json index or a registry.
+// Repository indexes set Path; registries set URL (and optionally Version).
+type herdIndexEntry struct {
+	Name        string `json:"name"`
+	Path        string `json:"path,omitempty"` // repo-relative subdirectory, slash-separated
+	URL         string `json:"url,omitempty"`  // repository URL, may include "//subdir"
+	Version     string `json:"version,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
 // PullConfig holds the configuration for a pull operation.
 type PullConfig struct {
-	RepoPath string       // absolute path to the repo root
-	DryRun   bool         // if true, log what would happen but don't download
-	Logger   *slog.Logger // structured logger
+	RepoPath     string       // absolute path to the repo root
+	SettingsPath string       // alternate settings file; defaults to .promptherder/settings.json
+	Path         string       // subdirectory of the repository holding the herd; overrides "//path" in the URL
+	Trust        bool         // confirm a source that is not in trusted_sources
+	Strict       bool         // fail instead of warning when the content scanner finds something
+	DryRun       bool         // if true, log what would happen but don't download
+	Logger       *slog.Logger // structured logger
 }
 
 // Pull downloads a herd from a GitHub repository archive.
-// The herd name is derived from the URL's last path segment (sans .git).
-// After extraction, it validates that herd.json exists.
+// The herd name is derived from the URL's last path segment (sans .git), or
+// from the subdirectory when one is given ("https://github.com/org/mono//herds/go"
+// or PullConfig.Path). A repository without a root herd.json may publish
+// several herds through a herds.json index; all of them are installed.
+// Herds are extracted to a staging directory and validated before they
+// replace any existing install. A bare name (no slashes) is resolved through
+// the registries configured in settings. When settings define
+// trusted_sources, the source must match them or be confirmed with
+// PullConfig.Trust; the decision is recorded in the manifest.
 // No git binary required — uses net/http + archive/tar + compress/gzip.
 func Pull(ctx context.Context, gitURL string, cfg PullConfig) error {
-	name := herdNameFromURL(gitURL)
+	settings, err := loadSettings(cfg.RepoPath, cfg.SettingsPath)
+	if err != nil {
+		return fmt.Errorf("load settings: %w", err)
+	}
+
+	var ref, registryName string
+	if isRegistryName(gitURL) {
+		entry, err := resolveRegistryHerd(ctx, cfg.RepoPath, settings, gitURL, cfg.Logger)
+		if err != nil {
+			return err
+		}
+		cfg.Logger.Info("resolved herd", "name", entry.Name, "url", entry.URL, "version", entry.Version)
+		gitURL, ref, registryName = entry.URL, entry.Version, entry.Name
+	}
+
+	repoURL, subdir := splitSubdirURL(gitURL)
+	if cfg.Path != "" {
+		subdir = cfg.Path
+	}
+	subdir, err = cleanSubdir(subdir)
+	if err != nil {
+		return err
+	}
+
+	name := herdNameFromURL(repoURL)
+	if subdir != "" {
+		name = path.Base(subdir)
+	}
+	if registryName != "" {
+		name = registryName
+	}
 	if name == "" {
 		return fmt.Errorf("cannot derive herd name from URL: %s", gitURL)
 	}
 
-	owner, repo := ownerRepoFromURL(gitURL)
+	owner, repo := ownerRepoFromURL(repoURL)
 	if owner == "" || repo == "" {
 		return fmt.Errorf("cannot parse owner/repo from URL: %s (expected https://github.com/OWNER/REPO)", gitURL)
 	}
 
-	archiveURL := toArchiveURL(owner, repo)
+	prevManifest := readManifest(cfg.RepoPath, cfg.Logger)
+	trust, err := checkTrust(sourceID(owner, repo), settings, prevManifest, cfg.Trust)
+	if err != nil {
+		return err
+	}
+	trust.URL, trust.Ref = gitURL, ref
+	if trust.TrustRule == "" && trust.TrustedBy != "" {
+		cfg.Logger.Warn("trusting new herd source", "name", name, "url", trust.Source, "by", trust.TrustedBy)
+	}
+
+	archiveURL := toArchiveURL(owner, repo, ref)
 	herdPath := filepath.Join(cfg.RepoPath, herdsDir, name)
 
 	if cfg.DryRun {
+		attrs := []any{"name", name, "url", archiveURL}
+		if subdir != "" {
+			attrs = append(attrs, "subdir", subdir)
+		}
 		if isDirectory(herdPath) {
-			cfg.Logger.Info("dry-run: would update herd", "name", name, "url", archiveURL)
+			cfg.Logger.Info("dry-run: would update herd", attrs...)
 		} else {
-			cfg.Logger.Info("dry-run: would download herd", "name", name, "url", archiveURL, "path", herdPath)
+			cfg.Logger.Info("dry-run: would download herd", append(attrs, "path", herdPath)...)
 		}
 		return nil
 	}
 
-	// Remove existing herd dir for a clean re-download.
-	if isDirectory(herdPath) {
-		cfg.Logger.Info("updating herd (re-downloading)", "name", name)
-		if err := os.RemoveAll(herdPath); err != nil {
-			return fmt.Errorf("remove existing herd %s: %w", name, err)
+	cfg.Logger.Info("downloading herd", "name", name, "url", archiveURL)
+
+	archive, err := downloadArchive(ctx, archiveURL)
+	if err != nil {
+		return err
+	}
+
+	// Herds installed before a failure are recorded too, so later pulls
+	// find their source and trust decision.
+	installed, err := installArchive(archive, name, subdir, settings, cfg)
+	if len(installed) > 0 {
+		if rerr := recordHerdSources(cfg.RepoPath, installed, trust, cfg.Logger); rerr != nil {
+			return errors.Join(err, rerr)
 		}
 	}
+	return err
+}
 
-	cfg.Logger.Info("downloading herd", "name", name, "url", archiveURL)
+// recordHerdSources stores the source and trust decision for each installed
+// herd in the manifest, leaving target file lists untouched.
+func recordHerdSources(repoPath string, names []string, rec herdSource, logger *slog.Logger) error {
+	m := readManifest(repoPath, logger)
+	if m.HerdSources == nil {
+		m.HerdSources = make(map[string]herdSource)
+	}
+	for _, name := range names {
+		m.HerdSources[name] = rec
+	}
+	if m.GeneratedAt == "" {
+		m.GeneratedAt = rec.PulledAt
+	}
+	return writeManifest(repoPath, m)
+}
 
+// downloadArchive fetches a tarball into memory. Herds are small text trees,
+// and buffering lets one download serve several herds from an index.
+func downloadArchive(ctx context.Context, archiveURL string) ([]byte, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, archiveURL, nil)
 	if err != nil {
-		return fmt.Errorf("create request: %w", err)
+		return nil, fmt.Errorf("create request: %w", err)
 	}
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("download %s: %w", archiveURL, err)
+		return nil, fmt.Errorf("download %s: %w", archiveURL, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("download %s: HTTP %d", archiveURL, resp.StatusCode)
+		return nil, fmt.Errorf("download %s: HTTP %d", archiveURL, resp.StatusCode)
 	}
 
-	if err := extractTarGz(resp.Body, herdPath); err != nil {
-		return fmt.Errorf("extract herd %s: %w", name, err)
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("download %s: %w", archiveURL, err)
+	}
+	return data, nil
+}
+
+// installArchive extracts herds from a downloaded tar.gz into
+// .promptherder/herds/. With a subdir, only that subtree is extracted and
+// installed as name. Without one, the archive root is installed as name if it
+// has a herd.json; otherwise every herd listed in its herds.json is installed.
+// Each herd's signature is checked against settings before it is installed.
+// Returns the installed herd names.
+func installArchive(archive []byte, name, subdir string, settings Settings, cfg PullConfig) ([]string, error) {
+	herdsRoot := filepath.Join(cfg.RepoPath, herdsDir)
+	if err := os.MkdirAll(herdsRoot, 0o755); err != nil {
+		return nil, fmt.Errorf("create herds dir: %w", err)
+	}
+	stage, err := os.MkdirTemp(herdsRoot, ".pull-*")
+	if err != nil {
+		return nil, fmt.Errorf("create staging dir: %w", err)
 	}
+	defer os.RemoveAll(stage)
 
-	// Validate herd.json exists.
-	metaPath := filepath.Join(herdPath, herdMetaFile)
-	if _, err := os.Stat(metaPath); os.IsNotExist(err) {
+	root := filepath.Join(stage, "root")
+	if err := extractTarGz(bytes.NewReader(archive), root, subdir); err != nil {
+		return nil, fmt.Errorf("extract herd %s: %w", name, err)
+	}
+
+	// Single herd: the archive root (or selected subtree) is the herd.
+	if _, err := os.Stat(filepath.Join(root, herdMetaFile)); err == nil || subdir != "" {
+		if err := installHerdDir(root, name, settings, cfg); err != nil {
+			return nil, err
+		}
+		return []string{name}, nil
+	}
+
+	// Multi-herd repository: install every herd listed in herds.json.
+	data, err := os.ReadFile(filepath.Join(root, herdIndexFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("herd %q has no %s or %s — is this a valid herd repository?", name, herdMetaFile, herdIndexFile)
+		}
+		return nil, fmt.Errorf("read %s: %w", herdIndexFile, err)
+	}
+	var index herdIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", herdIndexFile, err)
+	}
+	if len(index.Herds) == 0 {
+		return nil, fmt.Errorf("%s in %q lists no herds", herdIndexFile, name)
+	}
+
+	cfg.Logger.Info("herd index", "name", name, "herds", len(index.Herds))
+
+	// Check every entry before installing any, so a bad entry leaves all
+	// previous installs in place.
+	srcs := make(map[string]string, len(index.Herds))
+	var names []string
+	for _, entry := range index.Herds {
+		entryPath, err := cleanSubdir(entry.Path)
+		if err != nil {
+			return nil, fmt.Errorf("%s entry %q: %w", herdIndexFile, entry.Name, err)
+		}
+		entryName := entry.Name
+		if entryName == "" {
+			entryName = path.Base(entryPath)
+		}
+		if entryPath == "" || entryName == "" || entryName == "." {
+			return nil, fmt.Errorf("%s entry %q has no path", herdIndexFile, entry.Name)
+		}
+		if _, dup := srcs[entryName]; dup {
+			return nil, fmt.Errorf("%s lists herd %q twice: %w", herdIndexFile, entryName, ErrValidation)
+		}
+		src := filepath.Join(root, filepath.FromSlash(entryPath))
+		if err := checkHerdDir(src, entryName, settings, cfg); err != nil {
+			return nil, err
+		}
+		srcs[entryName] = src
+		names = append(names, entryName)
+	}
+
+	var installed []string
+	for _, entryName := range names {
+		if err := moveHerdDir(srcs[entryName], entryName, cfg); err != nil {
+			return installed, err
+		}
+		installed = append(installed, entryName)
+	}
+	return installed, nil
+}
+
+// installHerdDir validates, verifies and scans an extracted herd and moves it into
+// .promptherder/herds/<name>, replacing any previous install.
+func installHerdDir(src, name string, settings Settings, cfg PullConfig) error {
+	if err := checkHerdDir(src, name, settings, cfg); err != nil {
+		return err
+	}
+	return moveHerdDir(src, name, cfg)
+}
+
+// checkHerdDir validates, verifies and scans the extracted herd at src,
+// to be installed as name.
+func checkHerdDir(src, name string, settings Settings, cfg PullConfig) error {
+	if err := checkHerdName(name); err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(src, herdMetaFile)); os.IsNotExist(err) {
 		return fmt.Errorf("herd %q has no %s — is this a valid herd repository?", name, herdMetaFile)
 	}
+	if err := verifyHerdSignature(src, name, settings, cfg.Logger); err != nil {
+		return err
+	}
+	findings, err := scanDir(src, herdsDir+"/"+name, scanPhrases(settings), skipUnmerged)
+	if err != nil {
+		return err
+	}
+	return reportFindings(findings, "herd "+name, cfg.Strict, cfg.Logger)
+}
+
+// moveHerdDir moves a checked herd into .promptherder/herds/<name>,
+// replacing any previous install.
+func moveHerdDir(src, name string, cfg PullConfig) error {
+	herdPath := filepath.Join(cfg.RepoPath, herdsDir, name)
+	if isDirectory(herdPath) {
+		cfg.Logger.Info("updating herd (replacing previous download)", "name", name)
+		if err := os.RemoveAll(herdPath); err != nil {
+			return fmt.Errorf("remove existing herd %s: %w", name, err)
+		}
+	}
+	if err := os.Rename(src, herdPath); err != nil {
+		return fmt.Errorf("install herd %s: %w", name, err)
+	}
 
 	cfg.Logger.Info("herd ready", "name", name, "path", herdPath)
 	return nil
 }
 
+// splitSubdirURL splits "https://github.com/org/mono//herds/go" into the
+// repository URL and the subdirectory ("herds/go"). The "//" separator follows
+// the go-getter convention.
+func splitSubdirURL(gitURL string) (repoURL, subdir string) {
+	start := 0
+	if idx := strings.Index(gitURL, "://"); idx >= 0 {
+		start = idx + len("://")
+	}
+	idx := strings.Index(gitURL[start:], "//")
+	if idx < 0 {
+		return gitURL, ""
+	}
+	return gitURL[:start+idx], gitURL[start+idx+2:]
+}
+
+// cleanSubdir normalizes a repository subdirectory and rejects paths that
+// escape the repository root.
+func cleanSubdir(subdir string) (string, error) {
+	subdir = strings.Trim(strings.ReplaceAll(subdir, "\\", "/"), "/")
+	if subdir == "" {
+		return "", nil
+	}
+	cleaned := path.Clean(subdir)
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("subdirectory %q escapes the repository: %w", subdir, ErrValidation)
+	}
+	if cleaned == "." {
+		return "", nil
+	}
+	return cleaned, nil
+}
+
 // herdNameFromURL extracts the herd name from a git URL.
 // e.g. "https://github.com/shermanhuman/compound-v.git" → "compound-v"
 // e.g. "https://github.com/shermanhuman/compound-v" → "compound-v"
@@ -128,15 +392,22 @@ func ownerRepoFromURL(gitURL string) (owner, repo string) {
 	return "", ""
 }
 
-// toArchiveURL builds the GitHub API archive URL for a repo's default branch.
-// Returns: https://api.github.com/repos/OWNER/REPO/tarball
-func toArchiveURL(owner, repo string) string {
-	return fmt.Sprintf("https://api.github.com/repos/%s/%s/tarball", owner, repo)
+// toArchiveURL builds the GitHub API archive URL for a repo at ref, or the
+// default branch when ref is empty.
+// Returns: https://api.github.com/repos/OWNER/REPO/tarball[/REF]
+func toArchiveURL(owner, repo, ref string) string {
+	u := fmt.Sprintf("https://api.github.com/repos/%s/%s/tarball", owner, repo)
+	if ref != "" {
+		u += "/" + url.PathEscape(ref)
+	}
+	return u
 }
 
 // extractTarGz extracts a tar.gz stream into destDir, stripping the
 // top-level directory prefix (GitHub archives have a "repo-branch/" prefix).
-func extractTarGz(r io.Reader, destDir string) error {
+// If subdir is non-empty, only entries under that slash-separated path are
+// extracted, relative to it; an archive without that path is an error.
+func extractTarGz(r io.Reader, destDir, subdir string) error {
 	gz, err := gzip.NewReader(r)
 	if err != nil {
 		return fmt.Errorf("gzip reader: %w", err)
@@ -144,6 +415,7 @@ func extractTarGz(r io.Reader, destDir string) error {
 	defer gz.Close()
 
 	tr := tar.NewReader(gz)
+	found := subdir == ""
 	for {
 		hdr, err := tr.Next()
 		if err == io.EOF {
@@ -159,6 +431,14 @@ func extractTarGz(r io.Reader, destDir string) error {
 		if idx := strings.IndexByte(name, '/'); idx >= 0 {
 			name = name[idx+1:]
 		}
+		if subdir != "" {
+			trimmed := strings.TrimSuffix(name, "/")
+			if trimmed != subdir && !strings.HasPrefix(name, subdir+"/") {
+				continue
+			}
+			found = true
+			name = strings.TrimPrefix(strings.TrimPrefix(name, subdir), "/")
+		}
 		if name == "" {
 			continue // skip the root dir entry itself
 		}
@@ -191,5 +471,8 @@ func extractTarGz(r io.Reader, destDir string) error {
 		}
 	}
 
+	if !found {
+		return fmt.Errorf("path %q not found in archive", subdir)
+	}
 	return nil
 }
@@ -4,8 +4,13 @@ import (
 	"archive/tar"
 	"bytes"
 	"compress/gzip"
+	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
 )
 
@@ -73,11 +78,17 @@ func TestOwnerRepoFromURL(t *testing.T) {
 func TestToArchiveURL(t *testing.T) {
 	t.Parallel()
 
-	got := toArchiveURL("shermanhuman", "compound-v")
+	got := toArchiveURL("shermanhuman", "compound-v", "")
 	want := "https://api.github.com/repos/shermanhuman/compound-v/tarball"
 	if got != want {
 		t.Errorf("toArchiveURL() = %q, want %q", got, want)
 	}
+
+	got = toArchiveURL("shermanhuman", "compound-v", "v1.2.0")
+	want = "https://api.github.com/repos/shermanhuman/compound-v/tarball/v1.2.0"
+	if got != want {
+		t.Errorf("toArchiveURL() with ref = %q, want %q", got, want)
+	}
 }
 
 func TestExtractTarGz(t *testing.T) {
@@ -123,7 +134,7 @@ func TestExtractTarGz(t *testing.T) {
 	gw.Close()
 
 	destDir := filepath.Join(dir, "output")
-	if err := extractTarGz(&buf, destDir); err != nil {
+	if err := extractTarGz(&buf, destDir, ""); err != nil {
 		t.Fatal(err)
 	}
 
@@ -164,7 +175,7 @@ func TestExtractTarGz_PathTraversal(t *testing.T) {
 	gw.Close()
 
 	dir := t.TempDir()
-	err := extractTarGz(&buf, dir)
+	err := extractTarGz(&buf, dir, "")
 	if err == nil {
 		t.Fatal("expected path traversal error")
 	}
@@ -172,3 +183,286 @@ func TestExtractTarGz_PathTraversal(t *testing.T) {
 		t.Errorf("error should mention escape, got: %v", err)
 	}
 }
+
+// makeTarGz builds an in-memory tar.gz with a GitHub-style top-level prefix.
+// Files are given as slash-separated paths relative to the repository root.
+func makeTarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	_ = tw.WriteHeader(&tar.Header{Name: "org-mono-abc123/", Typeflag: tar.TypeDir, Mode: 0755})
+	for _, name := range names {
+		content := files[name]
+		if err := tw.WriteHeader(&tar.Header{
+			Name:     "org-mono-abc123/" + name,
+			Typeflag: tar.TypeReg,
+			Mode:     0644,
+			Size:     int64(len(content)),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		_, _ = tw.Write([]byte(content))
+	}
+	tw.Close()
+	gw.Close()
+	return buf.Bytes()
+}
+
+func TestSplitSubdirURL(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		url        string
+		wantRepo   string
+		wantSubdir string
+	}{
+		{"https://github.com/org/mono//herds/go-backend", "https://github.com/org/mono", "herds/go-backend"},
+		{"https://github.com/org/mono.git//herds/go", "https://github.com/org/mono.git", "herds/go"},
+		{"https://github.com/org/herd", "https://github.com/org/herd", ""},
+		{"git@github.com:org/mono//herds/go", "git@github.com:org/mono", "herds/go"},
+	}
+
+	for _, tt := range tests {
+		gotRepo, gotSubdir := splitSubdirURL(tt.url)
+		if gotRepo != tt.wantRepo || gotSubdir != tt.wantSubdir {
+			t.Errorf("splitSubdirURL(%q) = (%q, %q), want (%q, %q)", tt.url, gotRepo, gotSubdir, tt.wantRepo, tt.wantSubdir)
+		}
+	}
+}
+
+func TestCleanSubdir(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"", "", false},
+		{"herds/go/", "herds/go", false},
+		{"/herds//go", "herds/go", false},
+		{`herds\go`, "herds/go", false},
+		{".", "", false},
+		{"../outside", "", true},
+		{"herds/../../outside", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := cleanSubdir(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("cleanSubdir(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("cleanSubdir(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestExtractTarGz_Subdir(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	archive := makeTarGz(t, map[string]string{
+		"README.md":                        "# Mono\n",
+		"herds/go-backend/herd.json":       `{"name":"go-backend"}`,
+		"herds/go-backend/rules/go.md":     "# Go\n",
+		"herds/go-backend-extra/herd.json": `{"name":"go-backend-extra"}`,
+		"herds/typescript/rules/ts.md":     "# TS\n",
+	})
+
+	if err := extractTarGz(bytes.NewReader(archive), dir, "herds/go-backend"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "herd.json")); err != nil {
+		t.Error("herd.json should be extracted at the destination root")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "rules", "go.md")); err != nil {
+		t.Error("rules/go.md should be extracted")
+	}
+	for _, unwanted := range []string{"README.md", "herds", "rules/ts.md"} {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(unwanted))); !os.IsNotExist(err) {
+			t.Errorf("%s should not be extracted", unwanted)
+		}
+	}
+}
+
+func TestExtractTarGz_SubdirNotFound(t *testing.T) {
+	t.Parallel()
+
+	archive := makeTarGz(t, map[string]string{"herd.json": `{}`})
+	err := extractTarGz(bytes.NewReader(archive), t.TempDir(), "herds/missing")
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expected not-found error, got %v", err)
+	}
+}
+
+func TestInstallArchive_SingleHerd(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	archive := makeTarGz(t, map[string]string{
+		"herd.json":    `{"name":"solo"}`,
+		"rules/foo.md": "# Foo\n",
+	})
+
+	names, err := installArchive(archive, "solo", "", Settings{}, PullConfig{RepoPath: dir, Logger: testLogger(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, []string{"solo"}) {
+		t.Errorf("installed = %v, want [solo]", names)
+	}
+	if _, err := os.Stat(filepath.Join(dir, herdsDir, "solo", "rules", "foo.md")); err != nil {
+		t.Error("rules/foo.md should be installed")
+	}
+	assertNoStaging(t, dir)
+}
+
+func TestInstallArchive_Subdir(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	archive := makeTarGz(t, map[string]string{
+		"herds/go-backend/herd.json":   `{"name":"go-backend"}`,
+		"herds/go-backend/rules/go.md": "# Go\n",
+		"herds/typescript/herd.json":   `{"name":"typescript"}`,
+	})
+
+	names, err := installArchive(archive, "go-backend", "herds/go-backend", Settings{}, PullConfig{RepoPath: dir, Logger: testLogger(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, []string{"go-backend"}) {
+		t.Errorf("installed = %v, want [go-backend]", names)
+	}
+	if _, err := os.Stat(filepath.Join(dir, herdsDir, "go-backend", "rules", "go.md")); err != nil {
+		t.Error("go-backend rules should be installed")
+	}
+	if isDirectory(filepath.Join(dir, herdsDir, "typescript")) {
+		t.Error("typescript herd should not be installed")
+	}
+}
+
+func TestInstallArchive_Index(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	archive := makeTarGz(t, map[string]string{
+		"herds.json": `{"herds":[
+			{"name":"go-backend","path":"herds/go-backend"},
+			{"path":"herds/typescript"}
+		]}`,
+		"herds/go-backend/herd.json":   `{"name":"go-backend"}`,
+		"herds/go-backend/rules/go.md": "# Go\n",
+		"herds/typescript/herd.json":   `{"name":"typescript"}`,
+		"herds/typescript/rules/ts.md": "# TS\n",
+	})
+
+	names, err := installArchive(archive, "mono", "", Settings{}, PullConfig{RepoPath: dir, Logger: testLogger(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, []string{"go-backend", "typescript"}) {
+		t.Errorf("installed = %v, want [go-backend typescript]", names)
+	}
+
+	herds, err := discoverHerds(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(herds) != 2 {
+		t.Fatalf("expected 2 discoverable herds, got %d", len(herds))
+	}
+	assertNoStaging(t, dir)
+}
+
+func TestInstallArchive_IndexRejectsUnsafeNames(t *testing.T) {
+	t.Parallel()
+	for _, name := range []string{"../../src", "..", ".hidden", `a\b`} {
+		dir := t.TempDir()
+		createTestFile(t, dir, "src/main.go", "package main\n")
+
+		index, _ := json.Marshal(herdIndex{Herds: []herdIndexEntry{{Name: name, Path: "h"}}})
+		archive := makeTarGz(t, map[string]string{
+			"herds.json":   string(index),
+			"h/herd.json":  `{"name":"h"}`,
+			"h/rules/h.md": "# H\n",
+		})
+		_, err := installArchive(archive, "mono", "", Settings{}, PullConfig{RepoPath: dir, Logger: testLogger(t)})
+		if !errors.Is(err, ErrValidation) {
+			t.Errorf("name %q: expected a validation error, got %v", name, err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "src", "main.go")); err != nil {
+			t.Errorf("name %q: files outside the herds dir must survive: %v", name, err)
+		}
+		assertNoStaging(t, dir)
+	}
+}
+
+func TestInstallArchive_IndexFailureInstallsNothing(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, filepath.Join(herdsDir, "one", "herd.json"), `{"name":"one"}`)
+	createTestFile(t, dir, filepath.Join(herdsDir, "one", "rules", "one.md"), "# One v1\n")
+
+	index, _ := json.Marshal(herdIndex{Herds: []herdIndexEntry{{Name: "one", Path: "one"}, {Name: "two", Path: "two"}}})
+	archive := makeTarGz(t, map[string]string{
+		"herds.json":       string(index),
+		"one/herd.json":    `{"name":"one"}`,
+		"one/rules/one.md": "# One v2\n",
+		"two/rules/two.md": "# Two, no herd.json\n",
+	})
+	installed, err := installArchive(archive, "mono", "", Settings{}, PullConfig{RepoPath: dir, Logger: testLogger(t)})
+	if err == nil {
+		t.Fatal("expected the second entry to fail")
+	}
+	if len(installed) != 0 {
+		t.Errorf("installed = %v, want none", installed)
+	}
+	// The first entry passed, but must not replace the installed herd.
+	assertContains(t, readOutput(t, dir, filepath.Join(herdsDir, "one", "rules", "one.md")), "# One v1\n")
+	if isDirectory(filepath.Join(dir, herdsDir, "two")) {
+		t.Error("the failed entry must not be installed")
+	}
+	assertNoStaging(t, dir)
+}
+
+func TestInstallArchive_InvalidKeepsExistingHerd(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	createTestFile(t, dir, filepath.Join(herdsDir, "solo", "herd.json"), `{"name":"solo"}`)
+
+	archive := makeTarGz(t, map[string]string{"rules/foo.md": "# No herd.json\n"})
+	_, err := installArchive(archive, "solo", "", Settings{}, PullConfig{RepoPath: dir, Logger: testLogger(t)})
+	if err == nil {
+		t.Fatal("expected error for archive without herd.json or herds.json")
+	}
+
+	// A failed pull must not destroy the previous install.
+	if _, err := os.Stat(filepath.Join(dir, herdsDir, "solo", "herd.json")); err != nil {
+		t.Error("existing herd should survive a failed pull")
+	}
+	assertNoStaging(t, dir)
+}
+
+// assertNoStaging fails if a .pull-* staging dir was left behind.
+func assertNoStaging(t *testing.T, repoPath string) {
+	t.Helper()
+	entries, _ := os.ReadDir(filepath.Join(repoPath, herdsDir))
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".pull-") {
+			t.Errorf("staging dir %s was not cleaned up", e.Name())
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// RegistryEntry is a herd listed in a registry index.
+type RegistryEntry struct {
+	Name        string
+	Description string
+	URL         string // repository URL, may include "//subdir"
+	Version     string // git ref (tag or branch) to pull; empty means the default branch
+	Registry    string // the registry source the entry came from
+}
+
+// loadRegistry reads a registry index from an http(s) URL or a local path.
+// Relative paths are resolved against the repo root. The index uses the
+// herds.json format with url/version/description set on each entry.
+func loadRegistry(ctx context.Context, repoPath, source string) (herdIndex, error) {
+	var data []byte
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+		if err != nil {
+			return herdIndex{}, fmt.Errorf("create request: %w", err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return herdIndex{}, fmt.Errorf("fetch registry %s: %w", source, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return herdIndex{}, fmt.Errorf("fetch registry %s: HTTP %d", source, resp.StatusCode)
+		}
+		data, err = io.ReadAll(resp.Body)
+		if err != nil {
+			return herdIndex{}, fmt.Errorf("fetch registry %s: %w", source, err)
+		}
+	} else {
+		path := filepath.FromSlash(strings.TrimPrefix(source, "file://"))
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(repoPath, path)
+		}
+		var err error
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return herdIndex{}, fmt.Errorf("read registry %s: %w", source, err)
+		}
+	}
+
+	var index herdIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return herdIndex{}, fmt.Errorf("parse registry %s: %w", source, err)
+	}
+	return index, nil
+}
+
+// loadRegistryEntries loads every configured registry, in settings order.
+// A registry that cannot be loaded is logged and skipped so one unreachable
+// catalog doesn't block the others.
+func loadRegistryEntries(ctx context.Context, repoPath string, settings Settings, logger *slog.Logger) ([]RegistryEntry, error) {
+	if len(settings.Registries) == 0 {
+		return nil, fmt.Errorf("no registries configured — add \"registries\" to %s/%s: %w", manifestDir, settingsFile, ErrValidation)
+	}
+
+	var entries []RegistryEntry
+	for _, source := range settings.Registries {
+		index, err := loadRegistry(ctx, repoPath, source)
+		if err != nil {
+			logger.Warn("skipping registry", "url", source, "error", err)
+			continue
+		}
+		for _, e := range index.Herds {
+			if e.Name == "" || e.URL == "" {
+				logger.Debug("skipping incomplete registry entry", "url", source, "name", e.Name)
+				continue
+			}
+			entries = append(entries, RegistryEntry{
+				Name:        e.Name,
+				Description: e.Description,
+				URL:         e.URL,
+				Version:     e.Version,
+				Registry:    source,
+			})
+		}
+	}
+	return entries, nil
+}
+
+// Search runs SearchRegistries with the registries from cfg's settings,
+// read the same way a sync reads them, for `promptherder search`.
+func Search(ctx context.Context, term string, cfg Config) ([]RegistryEntry, error) {
+	settings, err := loadSettings(cfg.RepoPath, cfg.SettingsPath)
+	if err != nil {
+		return nil, fmt.Errorf("load settings: %w", err)
+	}
+	return SearchRegistries(ctx, cfg.RepoPath, settings, term, cfg.Logger)
+}
+
+// SearchRegistries returns registry entries whose name or description
+// contains term (case-insensitive). An empty term lists every entry.
+// Results are sorted by name; when several registries list the same name,
+// the first configured registry wins.
+func SearchRegistries(ctx context.Context, repoPath string, settings Settings, term string, logger *slog.Logger) ([]RegistryEntry, error) {
+	entries, err := loadRegistryEntries(ctx, repoPath, settings, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	term = strings.ToLower(strings.TrimSpace(term))
+	seen := make(map[string]bool)
+	var matches []RegistryEntry
+	for _, e := range entries {
+		if seen[e.Name] {
+			continue
+		}
+		seen[e.Name] = true
+		if term != "" && !strings.Contains(strings.ToLower(e.Name), term) && !strings.Contains(strings.ToLower(e.Description), term) {
+			continue
+		}
+		matches = append(matches, e)
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Name < matches[j].Name })
+	return matches, nil
+}
+
+// resolveRegistryHerd looks up a herd by exact name in the configured
+// registries. The first configured registry that lists the name wins.
+func resolveRegistryHerd(ctx context.Context, repoPath string, settings Settings, name string, logger *slog.Logger) (RegistryEntry, error) {
+	entries, err := loadRegistryEntries(ctx, repoPath, settings, logger)
+	if err != nil {
+		return RegistryEntry{}, err
+	}
+	for _, e := range entries {
+		if e.Name == name {
+			return e, nil
+		}
+	}
+	return RegistryEntry{}, fmt.Errorf("herd %q not found in any configured registry — try `promptherder search %s`: %w", name, name, ErrValidation)
+}
+
+// isRegistryName reports whether a pull argument is a bare herd name
+// (resolved through registries) rather than a URL or path.
+func isRegistryName(arg string) bool {
+	return arg != "" && !strings.ContainsAny(arg, `/\:`)
+}
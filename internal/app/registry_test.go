@@ -0,0 +1,179 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testRegistry = `{"herds": [
+	{"name": "compound-v", "description": "TDD-first coding methodology", "url": "https://github.com/shermanhuman/compound-v", "version": "v0.9.0"},
+	{"name": "go-backend", "description": "Go service conventions", "url": "https://github.com/org/mono//herds/go-backend"},
+	{"name": "incomplete"}
+]}`
+
+func TestSearchRegistries_LocalFile(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, "registry.json", testRegistry)
+
+	settings := Settings{Registries: []string{"registry.json"}}
+
+	tests := []struct {
+		term string
+		want []string
+	}{
+		{"", []string{"compound-v", "go-backend"}},
+		{"tdd", []string{"compound-v"}},
+		{"GO", []string{"go-backend"}},
+		{"rust", nil},
+	}
+
+	for _, tt := range tests {
+		got, err := SearchRegistries(context.Background(), dir, settings, tt.term, testLogger(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, e := range got {
+			names = append(names, e.Name)
+		}
+		if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("search %q = %v, want %v", tt.term, names, tt.want)
+		}
+	}
+}
+
+func TestSearchRegistries_HTTP(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/index.json" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(testRegistry))
+	}))
+	defer srv.Close()
+
+	settings := Settings{Registries: []string{srv.URL + "/index.json"}}
+	got, err := SearchRegistries(context.Background(), t.TempDir(), settings, "compound", testLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("expected 1 result, got %v", got)
+	}
+	if got[0].Version != "v0.9.0" || got[0].Registry != srv.URL+"/index.json" {
+		t.Errorf("entry = %+v", got[0])
+	}
+}
+
+func TestSearchRegistries_SkipsUnreachableRegistry(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, "registry.json", testRegistry)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	settings := Settings{Registries: []string{srv.URL, "registry.json"}}
+	got, err := SearchRegistries(context.Background(), dir, settings, "", testLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Errorf("expected entries from the reachable registry, got %v", got)
+	}
+}
+
+func TestSearchRegistries_FirstRegistryWins(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, "internal.json", `{"herds":[{"name":"compound-v","url":"https://github.com/acme/compound-v-fork"}]}`)
+	createTestFile(t, dir, "public.json", testRegistry)
+
+	settings := Settings{Registries: []string{"internal.json", filepath.Join(dir, "public.json")}}
+	got, err := SearchRegistries(context.Background(), dir, settings, "compound", testLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].URL != "https://github.com/acme/compound-v-fork" {
+		t.Errorf("expected the internal fork to shadow the public entry, got %v", got)
+	}
+}
+
+func TestSearchRegistries_NoneConfigured(t *testing.T) {
+	t.Parallel()
+
+	_, err := SearchRegistries(context.Background(), t.TempDir(), Settings{}, "", testLogger(t))
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("expected ErrValidation, got %v", err)
+	}
+}
+
+func TestSearch_SettingsPath(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, "registry.json", testRegistry)
+	createTestFile(t, dir, "ci.json", `{"registries": ["registry.json"]}`)
+
+	// The repo has no settings.json; the alternate file names the registry.
+	got, err := Search(context.Background(), "tdd", Config{RepoPath: dir, SettingsPath: filepath.Join(dir, "ci.json"), Logger: testLogger(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Name != "compound-v" {
+		t.Errorf("Search = %v, want compound-v", got)
+	}
+
+	if _, err := Search(context.Background(), "", Config{RepoPath: dir, SettingsPath: filepath.Join(dir, "missing.json"), Logger: testLogger(t)}); err == nil {
+		t.Error("a missing -config file should be an error")
+	}
+}
+
+func TestResolveRegistryHerd(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, "registry.json", testRegistry)
+	settings := Settings{Registries: []string{"registry.json"}}
+
+	entry, err := resolveRegistryHerd(context.Background(), dir, settings, "go-backend", testLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.URL != "https://github.com/org/mono//herds/go-backend" {
+		t.Errorf("URL = %q", entry.URL)
+	}
+
+	_, err = resolveRegistryHerd(context.Background(), dir, settings, "missing", testLogger(t))
+	if !errors.Is(err, ErrValidation) || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("expected not-found validation error, got %v", err)
+	}
+}
+
+func TestIsRegistryName(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		arg  string
+		want bool
+	}{
+		{"compound-v", true},
+		{"https://github.com/org/herd", false},
+		{"git@github.com:org/herd.git", false},
+		{"./local/herd", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isRegistryName(tt.arg); got != tt.want {
+			t.Errorf("isRegistryName(%q) = %v, want %v", tt.arg, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package app
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FindRepoRoot walks up from dir to the repo promptherder should work on,
+// so running it from a subdirectory doesn't create a stray .promptherder/.
+// Inside a git work tree that is the outermost directory with a
+// .promptherder/ (nested ones are monorepo packages), or else the top of
+// the work tree. Outside git it is the nearest directory with a
+// .promptherder/. With neither, dir itself is the root.
+func FindRepoRoot(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("resolve repo path: %w", err)
+	}
+
+	var nearest, outermost string // directories with a .promptherder/
+	for d := dir; ; {
+		if isDirectory(filepath.Join(d, manifestDir)) {
+			nearest = cmp.Or(nearest, d)
+			outermost = d
+		}
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			return cmp.Or(outermost, d), nil
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			break
+		}
+		d = parent
+	}
+	return cmp.Or(nearest, dir), nil
+}
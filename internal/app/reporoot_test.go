@@ -0,0 +1,60 @@
+package app
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestFindRepoRoot(t *testing.T) {
+	t.Parallel()
+	base := t.TempDir()
+	mk := func(rel string) string {
+		p := filepath.Join(base, filepath.FromSlash(rel))
+		mustMkdir(t, p)
+		return p
+	}
+
+	// A git monorepo with a nested package and a deeper source dir.
+	mono := mk("mono")
+	mk("mono/.git")
+	mk("mono/.promptherder/agent")
+	mk("mono/packages/api/.promptherder/agent")
+	apiSrc := mk("mono/packages/api/src")
+
+	// A git repo without promptherder config yet.
+	plain := mk("plain")
+	mk("plain/.git")
+	plainSub := mk("plain/cmd/tool")
+
+	// A project in a subdirectory of a git repo.
+	mk("outer/.git")
+	project := mk("outer/tools/config")
+	mk("outer/tools/config/.promptherder")
+	projectSub := mk("outer/tools/config/docs")
+
+	// No git at all.
+	loose := mk("loose")
+	mk("loose/.promptherder")
+	looseSub := mk("loose/a/b")
+	nothing := mk("nothing/here")
+
+	tests := []struct {
+		name, dir, want string
+	}{
+		{"repo root itself", mono, mono},
+		{"nested package resolves to the monorepo root", apiSrc, mono},
+		{"git work tree without config", plainSub, plain},
+		{"config below the work tree top", projectSub, project},
+		{"nearest config outside git", looseSub, loose},
+		{"nothing found", nothing, nothing},
+	}
+	for _, tt := range tests {
+		got, err := FindRepoRoot(tt.dir)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package app
+
+import (
+	"cmp"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// ruleTrigger is the canonical activation mode of a rule. Sources declare it
+// with the `trigger` frontmatter key; each target translates it into its own
+// frontmatter.
+type ruleTrigger string
+
+const (
+	triggerAlways        ruleTrigger = "always"         // applies to every request
+	triggerGlob          ruleTrigger = "glob"           // applies to files matching applyTo
+	triggerModelDecision ruleTrigger = "model-decision" // the agent decides from the description
+	triggerManual        ruleTrigger = "manual"         // only when the user attaches it
+)
+
+// triggerAliases accepts target-specific spellings, so a rule written for
+// Antigravity (e.g. `trigger: always_on`) keeps its meaning.
+var triggerAliases = map[string]ruleTrigger{
+	"always":         triggerAlways,
+	"always_on":      triggerAlways,
+	"always-on":      triggerAlways,
+	"glob":           triggerGlob,
+	"model-decision": triggerModelDecision,
+	"model_decision": triggerModelDecision,
+	"manual":         triggerManual,
+}
+
+// ruleScope is the target-neutral activation of one rule.
+type ruleScope struct {
+	Trigger     ruleTrigger
+	ApplyTo     []string // glob trigger only
+	ExcludeFrom []string
+	Description string
+}
+
+// parseRuleScope reads a rule's trigger, globs and description. Without an
+// explicit trigger, a rule with applyTo is a glob rule and anything else
+// applies always. Antigravity's `globs` key is accepted in place of applyTo.
+func parseRuleScope(meta frontmatter) (ruleScope, error) {
+	var scope ruleScope
+	var err error
+	if scope.ApplyTo, err = meta.Globs("applyTo"); err != nil {
+		return ruleScope{}, err
+	}
+	if len(scope.ApplyTo) == 0 {
+		if scope.ApplyTo, err = meta.Globs("globs"); err != nil {
+			return ruleScope{}, err
+		}
+	}
+	if scope.ExcludeFrom, err = meta.Globs("excludeFrom"); err != nil {
+		return ruleScope{}, err
+	}
+	scope.Description = strings.TrimSpace(meta.String("description"))
+
+	raw := strings.ToLower(strings.TrimSpace(meta.String("trigger")))
+	switch {
+	case raw != "":
+		t, ok := triggerAliases[raw]
+		if !ok {
+			return ruleScope{}, fmt.Errorf("trigger %q: want always, glob, model-decision or manual", raw)
+		}
+		scope.Trigger = t
+	case len(scope.ApplyTo) > 0:
+		scope.Trigger = triggerGlob
+	default:
+		scope.Trigger = triggerAlways
+	}
+
+	switch {
+	case scope.Trigger == triggerGlob && len(scope.ApplyTo) == 0:
+		return ruleScope{}, fmt.Errorf("trigger glob needs applyTo")
+	case scope.Trigger != triggerGlob && len(scope.ApplyTo) > 0:
+		return ruleScope{}, fmt.Errorf("applyTo only applies to trigger glob, not %s", scope.Trigger)
+	case scope.Trigger == triggerModelDecision && scope.Description == "":
+		return ruleScope{}, fmt.Errorf("trigger model-decision needs a description")
+	}
+	return scope, nil
+}
+
+// copilotRuleHeader renders the frontmatter of a Copilot .instructions.md
+// file. Copilot has no model-decision or manual mode: those rules get an
+// instructions file without applyTo, which Copilot only attaches on request.
+func copilotRuleHeader(scope ruleScope) string {
+	var b strings.Builder
+	if scope.Description != "" {
+		fmt.Fprintf(&b, "description: %q\n", scope.Description)
+	}
+	if scope.Trigger == triggerGlob {
+		// Copilot takes several globs as one comma-separated applyTo.
+		fmt.Fprintf(&b, "applyTo: %q\n", strings.Join(scope.ApplyTo, ","))
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return "---\n" + b.String() + "---\n"
+}
+
+// antigravityTriggers maps canonical triggers to Antigravity's values.
+var antigravityTriggers = map[ruleTrigger]string{
+	triggerAlways:        "always_on",
+	triggerGlob:          "glob",
+	triggerModelDecision: "model_decision",
+	triggerManual:        "manual",
+}
+
+// antigravityRuleHeader renders the frontmatter of an .agent/rules file.
+func antigravityRuleHeader(scope ruleScope) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "---\ntrigger: %s\n", antigravityTriggers[scope.Trigger])
+	if scope.Trigger == triggerGlob {
+		fmt.Fprintf(&b, "globs: %s\n", strings.Join(scope.ApplyTo, ","))
+	}
+	if scope.Description != "" {
+		fmt.Fprintf(&b, "description: %q\n", scope.Description)
+	}
+	b.WriteString("---\n")
+	return b.String()
+}
+
+// parseRuleOrder reads a rule's position in concatenated outputs from its
+// `order` key (`priority` is accepted as a synonym). Lower values come
+// first; rules without either are 0.
+func parseRuleOrder(meta frontmatter) (int, error) {
+	if meta.Has("order") && meta.Has("priority") {
+		return 0, fmt.Errorf("set order or priority, not both")
+	}
+	for _, key := range []string{"order", "priority"} {
+		if !meta.Has(key) {
+			continue
+		}
+		n, ok := meta.Int(key)
+		if !ok {
+			return 0, fmt.Errorf("%s %q: want an integer", key, meta.String(key))
+		}
+		return n, nil
+	}
+	return 0, nil
+}
+
+// sortRules orders rules for concatenation: first by the first rule_order
+// pattern matching the rule's name (unmatched rules after all listed ones),
+// then by their order key. Ties keep their filename order.
+func sortRules(sources []sourceFile, ruleOrder []string) {
+	rank := func(name string) int {
+		for i, pattern := range ruleOrder {
+			if ok, _ := path.Match(pattern, name); ok {
+				return i
+			}
+		}
+		return len(ruleOrder)
+	}
+	slices.SortStableFunc(sources, func(a, b sourceFile) int {
+		return cmp.Or(cmp.Compare(rank(a.Name), rank(b.Name)), cmp.Compare(a.Order, b.Order))
+	})
+}
@@ -0,0 +1,250 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRuleScope(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		yaml    string
+		want    ruleScope
+		wantErr bool
+	}{
+		{
+			name: "no frontmatter is always",
+			yaml: "",
+			want: ruleScope{Trigger: triggerAlways},
+		},
+		{
+			name: "applyTo implies glob",
+			yaml: "applyTo: \"**/*.sh\"\n",
+			want: ruleScope{Trigger: triggerGlob, ApplyTo: []string{"**/*.sh"}},
+		},
+		{
+			name: "antigravity spelling",
+			yaml: "trigger: glob\nglobs: \"**/*.ts,**/*.tsx\"\n",
+			want: ruleScope{Trigger: triggerGlob, ApplyTo: []string{"**/*.ts", "**/*.tsx"}},
+		},
+		{
+			name: "always_on alias",
+			yaml: "trigger: always_on\n",
+			want: ruleScope{Trigger: triggerAlways},
+		},
+		{
+			name: "model decision",
+			yaml: "trigger: model_decision\ndescription: Use when editing migrations\n",
+			want: ruleScope{Trigger: triggerModelDecision, Description: "Use when editing migrations"},
+		},
+		{
+			name: "manual",
+			yaml: "trigger: manual\n",
+			want: ruleScope{Trigger: triggerManual},
+		},
+		{name: "unknown trigger", yaml: "trigger: sometimes\n", wantErr: true},
+		{name: "glob without applyTo", yaml: "trigger: glob\n", wantErr: true},
+		{name: "applyTo on manual rule", yaml: "trigger: manual\napplyTo: \"*.go\"\n", wantErr: true},
+		{name: "model decision without description", yaml: "trigger: model-decision\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			meta, err := parseYAML([]byte(tt.yaml), 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := parseRuleScope(meta)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseRuleScope = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleHeaders(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		scope       ruleScope
+		copilot     string
+		antigravity string
+	}{
+		{
+			scope:       ruleScope{Trigger: triggerAlways},
+			copilot:     "",
+			antigravity: "---\ntrigger: always_on\n---\n",
+		},
+		{
+			scope:       ruleScope{Trigger: triggerGlob, ApplyTo: []string{"*.ts", "*.tsx"}},
+			copilot:     "---\napplyTo: \"*.ts,*.tsx\"\n---\n",
+			antigravity: "---\ntrigger: glob\nglobs: *.ts,*.tsx\n---\n",
+		},
+		{
+			scope:       ruleScope{Trigger: triggerModelDecision, Description: "SQL work"},
+			copilot:     "---\ndescription: \"SQL work\"\n---\n",
+			antigravity: "---\ntrigger: model_decision\ndescription: \"SQL work\"\n---\n",
+		},
+		{
+			scope:       ruleScope{Trigger: triggerManual},
+			copilot:     "",
+			antigravity: "---\ntrigger: manual\n---\n",
+		},
+	}
+	for _, tt := range tests {
+		if got := copilotRuleHeader(tt.scope); got != tt.copilot {
+			t.Errorf("copilotRuleHeader(%s) = %q, want %q", tt.scope.Trigger, got, tt.copilot)
+		}
+		if got := antigravityRuleHeader(tt.scope); got != tt.antigravity {
+			t.Errorf("antigravityRuleHeader(%s) = %q, want %q", tt.scope.Trigger, got, tt.antigravity)
+		}
+	}
+}
+
+func TestTriggers_AcrossTargets(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, ".promptherder/agent/rules/general.md", "# General\n")
+	createTestFile(t, dir, ".promptherder/agent/rules/sql.md", "---\ntrigger: model-decision\ndescription: Database migrations\nowner: data\n---\n# SQL\n")
+	createTestFile(t, dir, ".promptherder/agent/rules/release.md", "---\ntrigger: manual\n---\n# Release\n")
+	createTestFile(t, dir, ".promptherder/hard-rules.md", "---\ntrigger: manual\n---\n# Hard\n")
+
+	cfg := TargetConfig{RepoPath: dir, Logger: testLogger(t)}
+	if _, err := (CopilotTarget{}).Install(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (AntigravityTarget{}).Install(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func(rel string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(rel)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	// Copilot: only always-on rules are concatenated.
+	inst := read(".github/copilot-instructions.md")
+	assertContains(t, []byte(inst), "# General")
+	assertContains(t, []byte(inst), "# Hard")
+	assertNotContains(t, []byte(inst), "# SQL")
+	assertNotContains(t, []byte(inst), "# Release")
+	assertContains(t, []byte(read(".github/instructions/sql.instructions.md")), `description: "Database migrations"`)
+	assertNotContains(t, []byte(read(".github/instructions/release.instructions.md")), "applyTo")
+
+	// Antigravity: triggers are translated, unknown keys dropped.
+	if got := read(".agent/rules/sql.md"); got != "---\ntrigger: model_decision\ndescription: \"Database migrations\"\n---\n# SQL\n" {
+		t.Errorf("sql.md = %q", got)
+	}
+	if got := read(".agent/rules/general.md"); got != "---\ntrigger: always_on\n---\n# General\n" {
+		t.Errorf("general.md = %q", got)
+	}
+	if got := read(".agent/rules/hard-rules.md"); got != "---\ntrigger: always_on\n---\n# Hard\n" {
+		t.Errorf("hard rules must stay always on, got %q", got)
+	}
+}
+
+func TestParseRuleOrder(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		yaml    string
+		want    int
+		wantErr bool
+	}{
+		{name: "unset", yaml: "description: x\n", want: 0},
+		{name: "order", yaml: "order: 10\n", want: 10},
+		{name: "negative", yaml: "order: -5\n", want: -5},
+		{name: "priority synonym", yaml: "priority: 3\n", want: 3},
+		{name: "not a number", yaml: "order: first\n", wantErr: true},
+		{name: "both keys", yaml: "order: 1\npriority: 2\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			meta, _, err := parseFrontmatter([]byte("---\n" + tt.yaml + "---\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := parseRuleOrder(meta)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortRules(t *testing.T) {
+	t.Parallel()
+	names := func(sources []sourceFile) []string {
+		var out []string
+		for _, s := range sources {
+			out = append(out, s.Name)
+		}
+		return out
+	}
+	rules := func() []sourceFile {
+		return []sourceFile{
+			{Name: "alpha"},
+			{Name: "beta", Order: -1},
+			{Name: "compound-v", Order: 5},
+			{Name: "compound-v-go"},
+			{Name: "zeta", Order: -1},
+		}
+	}
+	tests := []struct {
+		name      string
+		ruleOrder []string
+		want      []string
+	}{
+		{"order keys, ties by filename", nil, []string{"beta", "zeta", "alpha", "compound-v-go", "compound-v"}},
+		{"settings override order keys", []string{"compound-v*", "alpha"}, []string{"compound-v-go", "compound-v", "alpha", "beta", "zeta"}},
+	}
+	for _, tt := range tests {
+		sources := rules()
+		sortRules(sources, tt.ruleOrder)
+		if got := names(sources); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRuleOrder_CopilotInstructions(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, ".promptherder/hard-rules.md", "---\norder: 99\n---\n# Hard\n")
+	createTestFile(t, dir, ".promptherder/agent/rules/00-general.md", "# General\n")
+	createTestFile(t, dir, ".promptherder/agent/rules/compound-v.md", "---\norder: -10\n---\n# Compound V\n")
+	createTestFile(t, dir, ".promptherder/agent/rules/style.md", "---\npriority: 1\n---\n# Style\n")
+
+	cfg := TargetConfig{RepoPath: dir, Logger: testLogger(t), Settings: Settings{RuleOrder: []string{"style"}}}
+	if _, err := (CopilotTarget{}).Install(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, ".github", "copilot-instructions.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// hard-rules first despite its order key, then rule_order, then order keys.
+	prev := -1
+	for _, heading := range []string{"# Hard", "# Style", "# Compound V", "# General"} {
+		i := strings.Index(string(data), heading)
+		if i < 0 || i < prev {
+			t.Errorf("%q is out of order in:\n%s", heading, data)
+		}
+		prev = i
+	}
+}
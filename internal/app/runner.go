@@ -2,10 +2,12 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 )
 
 // setupRunner initializes logger, resolves paths, loads manifest and settings.
@@ -22,7 +24,7 @@ func setupRunner(cfg *Config) (repoPath string, prevManifest manifest, tcfg Targ
 
 	prevManifest = readManifest(repoPath, cfg.Logger)
 
-	settings, err := LoadSettings(repoPath)
+	settings, err := loadSettings(repoPath, cfg.SettingsPath)
 	if err != nil {
 		return "", manifest{}, TargetConfig{}, fmt.Errorf("load settings: %w", err)
 	}
@@ -31,18 +33,35 @@ func setupRunner(cfg *Config) (repoPath string, prevManifest manifest, tcfg Targ
 		cfg.Logger.Info("settings", "prefix", settings.CommandPrefix)
 	}
 
+	packages, err := discoverPackages(repoPath, settings.Packages)
+	if err != nil {
+		return "", manifest{}, TargetConfig{}, err
+	}
+	if len(packages) > 0 {
+		cfg.Logger.Info("packages", "packages", packages)
+	}
+
 	tcfg = TargetConfig{
 		RepoPath: repoPath,
 		DryRun:   cfg.DryRun,
 		Logger:   cfg.Logger,
 		Settings: settings,
+		packages: packages,
+		guard: &driftGuard{
+			repoPath: repoPath,
+			hashes:   prevManifest.Hashes,
+			policy:   settings.DriftPolicy,
+			force:    cfg.Force,
+			dryRun:   cfg.DryRun,
+			logger:   cfg.Logger,
+		},
 	}
 
 	return repoPath, prevManifest, tcfg, nil
 }
 
 // persistAndClean writes the manifest and cleans stale files.
-func persistAndClean(repoPath string, prev, cur manifest, dryRun bool, logger *slog.Logger) error {
+func persistAndClean(repoPath string, prev, cur manifest, guard *driftGuard, dryRun bool, logger *slog.Logger) error {
 	if dryRun {
 		logger.Info("dry-run", "target", filepath.Join(repoPath, manifestDir, manifestFile))
 	} else {
@@ -50,17 +69,40 @@ func persistAndClean(repoPath string, prev, cur manifest, dryRun bool, logger *s
 			return err
 		}
 	}
-	return cleanStale(repoPath, prev, cur, dryRun, logger)
+	return cleanStale(repoPath, prev, cur, guard, dryRun, logger)
+}
+
+// finishRecording compares the outputs recorded by a dry run with disk,
+// and prints the diffs if cfg.Diff is set.
+func finishRecording(cfg Config, repoPath string, prev, cur manifest, tcfg TargetConfig) error {
+	if tcfg.rec == nil {
+		return nil
+	}
+	tcfg.rec.changes = tcfg.rec.compare(repoPath, prev, cur, tcfg.guard)
+	if cfg.Diff == nil {
+		return nil
+	}
+	return writeDiffs(cfg.Diff, repoPath, tcfg.rec)
 }
 
 // RunAll runs all registered targets and writes a unified manifest.
-// This is the bare `promptherder` command. It merges herds first,
-// then fans out to all agent targets.
+// This is the bare `promptherder` command. It merges herds and the user
+// layer first, then fans out to all agent targets.
 func RunAll(ctx context.Context, targets []Target, cfg Config) error {
+	return runAll(ctx, targets, cfg, nil)
+}
+
+// runAll implements RunAll. In a dry run, rec (if not nil) collects the
+// outputs and the changes a sync would make, for Check and -diff.
+func runAll(ctx context.Context, targets []Target, cfg Config, rec *outputRecorder) error {
 	repoPath, prevManifest, tcfg, err := setupRunner(&cfg)
 	if err != nil {
 		return err
 	}
+	if rec == nil && cfg.DryRun && cfg.Diff != nil {
+		rec = newOutputRecorder()
+	}
+	tcfg.rec = rec
 
 	curManifest := newManifestFrom(prevManifest)
 
@@ -70,6 +112,20 @@ func RunAll(ctx context.Context, targets []Target, cfg Config) error {
 		return fmt.Errorf("discover herds: %w", err)
 	}
 
+	userDir := resolveUserDir(cfg)
+	prevMerged := slices.Concat(prevManifest.Targets["herds"], prevManifest.Targets[userTarget])
+
+	// Scan everything before anything is written, so --strict stops the sync.
+	if err := scanSources(repoPath, herds, userDir, tcfg.packages, prevMerged, tcfg.Settings, cfg.Strict, cfg.Logger); err != nil {
+		return err
+	}
+
+	// User-layer files are re-merged from scratch each run, so removing or
+	// opting out of the layer also removes its files.
+	if err := removeMerged(repoPath, prevManifest.Targets[userTarget], prevManifest, cfg.DryRun, cfg.Logger); err != nil {
+		return fmt.Errorf("clean user layer: %w", err)
+	}
+
 	if len(herds) == 0 {
 		cfg.Logger.Warn("no herds found — run `promptherder pull <url>` to install one")
 	} else {
@@ -91,21 +147,56 @@ func RunAll(ctx context.Context, targets []Target, cfg Config) error {
 		curManifest.setTarget("herds", installed)
 	}
 
+	// --- User layer step ---
+	var userFiles []string
+	if userDir != "" {
+		cfg.Logger.Info("merging user layer", "dir", userDir)
+		userFiles, err = mergeUserLayer(ctx, repoPath, userDir, curManifest.Targets["herds"], prevManifest, tcfg)
+		if err != nil {
+			return fmt.Errorf("merge user layer: %w", err)
+		}
+		curManifest.setTarget(userTarget, userFiles)
+	}
+	tcfg.user = userSources(prevManifest, userFiles, cfg.DryRun)
+	var local []string
+	tcfg.local = &local
+
 	// --- Target install step ---
+	var budgetErrs []error
 	for _, t := range targets {
 		if err := ctx.Err(); err != nil {
 			return err
 		}
 
 		cfg.Logger.Info("target", "name", t.Name())
-		installed, err := t.Install(ctx, tcfg)
+		installed, stats, err := installMeasured(ctx, t, tcfg, cfg.Tokenizer)
 		if err != nil {
 			return fmt.Errorf("target %s: %w", t.Name(), err)
 		}
 		curManifest.setTarget(t.Name(), installed)
+		if err := checkBudget(stats, tcfg.Settings.BudgetPolicy, cfg.Logger); err != nil {
+			budgetErrs = append(budgetErrs, err)
+		}
+	}
+
+	// Outputs built from the user layer are as personal as its files.
+	slices.Sort(local)
+	curManifest.Local = local
+	if err := syncGitExclude(repoPath, slices.Concat(userFiles, local), cfg.DryRun, cfg.Logger); err != nil {
+		return fmt.Errorf("exclude user layer: %w", err)
+	}
+
+	if err := finishRecording(cfg, repoPath, prevManifest, curManifest, tcfg); err != nil {
+		return err
 	}
 
-	return persistAndClean(repoPath, prevManifest, curManifest, cfg.DryRun, cfg.Logger)
+	// Over-budget targets and hand-edited outputs fail the run only after
+	// the manifest is saved, so the files already written stay tracked.
+	curManifest.recordHashes(repoPath, prevManifest, slices.DeleteFunc(curManifest.allFiles(), tcfg.guard.wasKept))
+	if err := persistAndClean(repoPath, prevManifest, curManifest, tcfg.guard, cfg.DryRun, cfg.Logger); err != nil {
+		return err
+	}
+	return errors.Join(append(budgetErrs, tcfg.guard.err())...)
 }
 
 // RunTarget runs a single named target and updates the manifest.
@@ -114,12 +205,24 @@ func RunTarget(ctx context.Context, target Target, cfg Config) error {
 	if err != nil {
 		return err
 	}
+	if cfg.DryRun && cfg.Diff != nil {
+		tcfg.rec = newOutputRecorder()
+	}
+	userFiles := prevManifest.Targets[userTarget]
+	tcfg.user = userSources(prevManifest, userFiles, false)
+	var local []string
+	tcfg.local = &local
+
+	if err := scanSources(repoPath, nil, "", tcfg.packages, nil, tcfg.Settings, cfg.Strict, cfg.Logger); err != nil {
+		return err
+	}
 
 	cfg.Logger.Info("target", "name", target.Name())
-	installed, err := target.Install(ctx, tcfg)
+	installed, stats, err := installMeasured(ctx, target, tcfg, cfg.Tokenizer)
 	if err != nil {
 		return fmt.Errorf("target %s: %w", target.Name(), err)
 	}
+	budgetErr := checkBudget(stats, tcfg.Settings.BudgetPolicy, cfg.Logger)
 
 	// Build manifest: preserve all other targets, update this one.
 	curManifest := newManifestFrom(prevManifest)
@@ -129,6 +232,23 @@ func RunTarget(ctx context.Context, target Target, cfg Config) error {
 		}
 	}
 	curManifest.setTarget(target.Name(), installed)
+	for _, f := range prevManifest.Local {
+		if !slices.Contains(prevManifest.Targets[target.Name()], f) {
+			local = append(local, f)
+		}
+	}
+	slices.Sort(local)
+	curManifest.Local = local
+	if err := syncGitExclude(repoPath, slices.Concat(userFiles, local), cfg.DryRun, cfg.Logger); err != nil {
+		return fmt.Errorf("exclude user layer: %w", err)
+	}
+	curManifest.recordHashes(repoPath, prevManifest, slices.DeleteFunc(slices.Clone(installed), tcfg.guard.wasKept))
+	if err := finishRecording(cfg, repoPath, prevManifest, curManifest, tcfg); err != nil {
+		return err
+	}
 
-	return persistAndClean(repoPath, prevManifest, curManifest, cfg.DryRun, cfg.Logger)
+	if err := persistAndClean(repoPath, prevManifest, curManifest, tcfg.guard, cfg.DryRun, cfg.Logger); err != nil {
+		return err
+	}
+	return errors.Join(budgetErr, tcfg.guard.err())
 }
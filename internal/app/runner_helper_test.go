@@ -73,8 +73,8 @@ func TestSetupRunner_LoadsManifest(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if prevManifest.Version != 2 {
-		t.Errorf("manifest version = %d, want 2", prevManifest.Version)
+	if prevManifest.Version != manifestVersion {
+		t.Errorf("manifest version = %d, want %d", prevManifest.Version, manifestVersion)
 	}
 
 	testFiles := prevManifest.Targets["test"]
@@ -166,7 +166,7 @@ func TestPersistAndClean_DryRun(t *testing.T) {
 	cur := manifest{Version: 2}
 	cur.setTarget("test", []string{".test/file.md"})
 
-	err := persistAndClean(dir, prev, cur, true, testLogger(t))
+	err := persistAndClean(dir, prev, cur, nil, true, testLogger(t))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -192,7 +192,7 @@ func TestPersistAndClean_ActualWrite(t *testing.T) {
 	cur := manifest{Version: 2}
 	cur.setTarget("test", []string{".test/file.md"})
 
-	err := persistAndClean(dir, prev, cur, false, logger)
+	err := persistAndClean(dir, prev, cur, nil, false, logger)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -203,8 +203,8 @@ func TestPersistAndClean_ActualWrite(t *testing.T) {
 	}
 
 	readBack := readManifest(dir, logger)
-	if readBack.Version != 2 {
-		t.Errorf("manifest version = %d, want 2", readBack.Version)
+	if readBack.Version != manifestVersion {
+		t.Errorf("manifest version = %d, want %d", readBack.Version, manifestVersion)
 	}
 	testFiles := readBack.Targets["test"]
 	if len(testFiles) != 1 {
@@ -227,7 +227,7 @@ func TestPersistAndClean_CleansStaleFiles(t *testing.T) {
 	cur := manifest{Version: 2}
 	cur.setTarget("test", []string{".test/new.md"})
 
-	err := persistAndClean(dir, prev, cur, false, testLogger(t))
+	err := persistAndClean(dir, prev, cur, nil, false, testLogger(t))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -250,7 +250,7 @@ func TestPersistAndClean_WriteError(t *testing.T) {
 	prev := manifest{Version: 1}
 	cur := manifest{Version: 2}
 
-	err := persistAndClean(dir, prev, cur, false, testLogger(t))
+	err := persistAndClean(dir, prev, cur, nil, false, testLogger(t))
 	if err == nil {
 		t.Error("persistAndClean should return error when writeManifest fails")
 	}
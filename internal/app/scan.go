@@ -0,0 +1,321 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Scan finding kinds.
+const (
+	FindingHiddenChar  = "hidden-character"
+	FindingHTMLComment = "html-comment"
+	FindingBase64      = "base64-blob"
+	FindingPhrase      = "suspicious-phrase"
+)
+
+// defaultSuspiciousPhrases are matched case-insensitively, with runs of
+// whitespace collapsed. Settings.SuspiciousPhrases extends this list.
+var defaultSuspiciousPhrases = []string{
+	"ignore previous instructions",
+	"ignore all previous instructions",
+	"ignore the above instructions",
+	"disregard previous instructions",
+	"disregard all prior instructions",
+	"forget your instructions",
+	"do not tell the user",
+	"don't tell the user",
+	"without telling the user",
+	"without asking the user",
+	"do not mention this",
+	"exfiltrate",
+	"send the contents of",
+	"| sh",
+	"| bash",
+}
+
+var (
+	htmlCommentRe = regexp.MustCompile(`(?s)<!--(.*?)-->`)
+	base64BlobRe  = regexp.MustCompile(`[A-Za-z0-9+/]{64,}={0,2}`)
+	whitespaceRe  = regexp.MustCompile(`\s+`)
+)
+
+// ScanFinding is one suspicious item found in herd or source content.
+type ScanFinding struct {
+	Path   string // slash-separated, prefixed with the scanned root's label
+	Line   int
+	Kind   string
+	Detail string
+}
+
+func (f ScanFinding) String() string {
+	return fmt.Sprintf("%s:%d: %s: %s", f.Path, f.Line, f.Kind, f.Detail)
+}
+
+// hiddenCharName returns a description of r if it is an invisible or
+// direction-changing character that can hide text from a human reviewer.
+func hiddenCharName(r rune) (string, bool) {
+	switch {
+	case r == 0x00AD:
+		return "soft hyphen", true
+	case r >= 0x200B && r <= 0x200D, r == 0x2060, r == 0xFEFF:
+		return "zero-width character", true
+	case r == 0x200E || r == 0x200F || r == 0x061C:
+		return "direction mark", true
+	case r >= 0x202A && r <= 0x202E, r >= 0x2066 && r <= 0x2069:
+		return "bidi override", true
+	case r >= 0x2061 && r <= 0x2064:
+		return "invisible operator", true
+	case r >= 0xE0000 && r <= 0xE007F:
+		return "tag character", true
+	}
+	return "", false
+}
+
+// scanContent inspects one file. rel is used for reporting only.
+func scanContent(rel string, data []byte, phrases []string) []ScanFinding {
+	var findings []ScanFinding
+	add := func(line int, kind, format string, args ...any) {
+		findings = append(findings, ScanFinding{Path: rel, Line: line, Kind: kind, Detail: fmt.Sprintf(format, args...)})
+	}
+	lineAt := func(offset int) int { return bytes.Count(data[:offset], []byte("\n")) + 1 }
+
+	// Hidden characters, reported once per line.
+	for i, line := range strings.Split(string(data), "\n") {
+		for col, r := range line {
+			if i == 0 && col == 0 && r == 0xFEFF {
+				continue // a leading byte-order mark is harmless
+			}
+			if name, ok := hiddenCharName(r); ok {
+				add(i+1, FindingHiddenChar, "%s U+%04X", name, r)
+				break
+			}
+		}
+	}
+
+	// HTML comments are invisible in rendered markdown but read by agents.
+	for _, m := range htmlCommentRe.FindAllSubmatchIndex(data, -1) {
+		body := strings.TrimSpace(string(data[m[2]:m[3]]))
+		if !strings.ContainsFunc(body, unicode.IsLetter) || isDirectiveComment(body) || isIncludeComment(body) {
+			continue
+		}
+		add(lineAt(m[0]), FindingHTMLComment, "%q", truncate(whitespaceRe.ReplaceAllString(body, " "), 60))
+	}
+
+	// Long base64 runs can smuggle encoded instructions past a reviewer.
+	for _, m := range base64BlobRe.FindAllIndex(data, -1) {
+		blob := string(data[m[0]:m[1]])
+		if !looksEncoded(blob) {
+			continue
+		}
+		add(lineAt(m[0]), FindingBase64, "%d characters starting %q", len(blob), truncate(blob, 16))
+	}
+
+	// Suspicious phrases, matched per line on normalized text.
+	for i, line := range strings.Split(string(data), "\n") {
+		norm := strings.ToLower(whitespaceRe.ReplaceAllString(line, " "))
+		for _, phrase := range phrases {
+			p := strings.ToLower(whitespaceRe.ReplaceAllString(strings.TrimSpace(phrase), " "))
+			if p != "" && containsPhrase(norm, p) {
+				add(i+1, FindingPhrase, "%q", phrase)
+			}
+		}
+	}
+
+	return findings
+}
+
+// containsPhrase reports whether phrase occurs in s on word boundaries, so
+// "| sh" matches "curl x | sh" but not "| Short name".
+func containsPhrase(s, phrase string) bool {
+	isWord := func(r rune) bool { return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) }
+	for start := 0; ; {
+		i := strings.Index(s[start:], phrase)
+		if i < 0 {
+			return false
+		}
+		i += start
+		end := i + len(phrase)
+		before, _ := utf8.DecodeLastRuneInString(s[:i])
+		after, _ := utf8.DecodeRuneInString(s[end:])
+		first, _ := utf8.DecodeRuneInString(phrase)
+		last, _ := utf8.DecodeLastRuneInString(phrase)
+		if (i == 0 || !isWord(first) || !isWord(before)) && (end == len(s) || !isWord(last) || !isWord(after)) {
+			return true
+		}
+		start = i + 1
+	}
+}
+
+// looksEncoded reports whether a base64-alphabet run mixes upper case, lower
+// case and digits, which rules out long paths and identifiers.
+func looksEncoded(s string) bool {
+	var upper, lower, digit bool
+	for _, r := range s {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= '0' && r <= '9':
+			digit = true
+		}
+	}
+	return upper && lower && digit
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "…"
+}
+
+// scanDir scans every text file under root that a target could read,
+// hidden files and symlinked files included; only .git dirs are skipped.
+// Paths in findings are prefixed with label (e.g. ".promptherder/agent").
+// skip, if non-nil, is called with the slash-separated path relative to root.
+func scanDir(root, label string, phrases []string, skip func(rel string) bool) ([]ScanFinding, error) {
+	var findings []ScanFinding
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+				return nil
+			}
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return fmt.Errorf("rel path: %w", err)
+		}
+		rel = filepath.ToSlash(rel)
+		if skip != nil && skip(rel) {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", path, err)
+		}
+		if bytes.IndexByte(data, 0) >= 0 {
+			return nil // binary asset
+		}
+		findings = append(findings, scanContent(label+"/"+rel, data, phrases)...)
+		return nil
+	})
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("scan %s: %w", label, err)
+	}
+	return findings, nil
+}
+
+// skipUnmerged makes a herd, overlay or user-layer scan cover exactly the
+// files mergeHerds and mergeUserLayer read — not herd.json, herd.sig, a README
+// or hidden files.
+func skipUnmerged(rel string) bool { return !herdMerges(rel) }
+
+// reportFindings logs each finding and a summary. In strict mode any finding
+// fails with ErrValidation so the pull or sync stops before content reaches
+// an agent.
+func reportFindings(findings []ScanFinding, scope string, strict bool, logger *slog.Logger) error {
+	if len(findings) == 0 {
+		logger.Debug("scan clean", "scope", scope)
+		return nil
+	}
+	for _, f := range findings {
+		logger.Warn("suspicious content", "file", fmt.Sprintf("%s:%d", f.Path, f.Line), "kind", f.Kind, "detail", f.Detail)
+	}
+	logger.Warn("scan report", "scope", scope, "findings", len(findings))
+	if strict {
+		return fmt.Errorf("%d suspicious finding(s) in %s — review them, or run without --strict: %w", len(findings), scope, ErrValidation)
+	}
+	return nil
+}
+
+// scanPhrases returns the default suspicious phrases plus any from settings.
+func scanPhrases(settings Settings) []string {
+	return append(append([]string(nil), defaultSuspiciousPhrases...), settings.SuspiciousPhrases...)
+}
+
+// scanSources scans everything a sync is about to hand to agents: the
+// merged content dirs of each herd and its overlay (replacement files and
+// patches), the user layer (userDir, "" for none), the local files in
+// .promptherder/agent and the hard-rules file, at the root and in each
+// nested package. Files listed in mergedFiles
+// (repo-relative, from the previous merge) are skipped in the agent dir
+// since their herd or layer is scanned directly.
+func scanSources(repoPath string, herds []herdOnDisk, userDir string, packages, mergedFiles []string, settings Settings, strict bool, logger *slog.Logger) error {
+	phrases := scanPhrases(settings)
+	var findings []ScanFinding
+
+	for _, h := range herds {
+		label := filepath.ToSlash(filepath.Join(herdsDir, filepath.Base(h.Path)))
+		found, err := scanDir(h.Path, label, phrases, skipUnmerged)
+		if err != nil {
+			return err
+		}
+		findings = append(findings, found...)
+
+		overlay := overlaysDir + "/" + h.Meta.Name
+		found, err = scanDir(filepath.Join(repoPath, filepath.FromSlash(overlay)), overlay, phrases, skipUnmerged)
+		if err != nil {
+			return err
+		}
+		findings = append(findings, found...)
+	}
+
+	if userDir != "" {
+		found, err := scanDir(userDir, filepath.ToSlash(userDir), phrases, skipUnmerged)
+		if err != nil {
+			return err
+		}
+		findings = append(findings, found...)
+	}
+
+	merged := make(map[string]bool, len(mergedFiles))
+	for _, f := range mergedFiles {
+		merged[f] = true
+	}
+	found, err := scanDir(filepath.Join(repoPath, agentDir), agentDir, phrases, func(rel string) bool {
+		return merged[agentDir+"/"+rel]
+	})
+	if err != nil {
+		return err
+	}
+	findings = append(findings, found...)
+
+	if data, err := os.ReadFile(filepath.Join(repoPath, filepath.FromSlash(hardRulesFile))); err == nil {
+		findings = append(findings, scanContent(hardRulesFile, data, phrases)...)
+	}
+
+	for _, pkg := range packages {
+		found, err := scanDir(filepath.Join(repoPath, filepath.FromSlash(pkg+"/"+agentDir)), pkg+"/"+agentDir, phrases, nil)
+		if err != nil {
+			return err
+		}
+		findings = append(findings, found...)
+		label := pkg + "/" + hardRulesFile
+		if data, err := os.ReadFile(filepath.Join(repoPath, filepath.FromSlash(label))); err == nil {
+			findings = append(findings, scanContent(label, data, phrases)...)
+		}
+	}
+
+	return reportFindings(findings, "sources", strict, logger)
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"github.com/bmatcuk/doublestar/v4"
 )
 
 const settingsFile = "settings.json"
@@ -17,6 +19,66 @@ type Settings struct {
 
 	// CommandPrefixEnabled toggles prefix application. Default false.
 	CommandPrefixEnabled bool `json:"command_prefix_enabled"`
+
+	// Registries are herd index files (http(s) URLs or repo-relative paths)
+	// used by `promptherder search` and `promptherder pull <name>`.
+	// Earlier registries win when several list the same herd name.
+	Registries []string `json:"registries,omitempty"`
+
+	// TrustedSources limits `promptherder pull` to these hosts, orgs or
+	// repos (e.g. "github.com/acme" or "github.com/acme/herd"). Other
+	// sources require --trust. Empty allows every source.
+	TrustedSources []string `json:"trusted_sources,omitempty"`
+
+	// TrustedKeys are base64 ed25519 public keys accepted for herd.sig
+	// signatures (see `promptherder herd sign`).
+	TrustedKeys []string `json:"trusted_keys,omitempty"`
+
+	// RequireSignatures makes pull reject herds without a valid signature
+	// from one of TrustedKeys.
+	RequireSignatures bool `json:"require_signatures,omitempty"`
+
+	// SuspiciousPhrases extends the built-in phrase list used by the
+	// content scanner (matched case-insensitively).
+	SuspiciousPhrases []string `json:"suspicious_phrases,omitempty"`
+
+	// ProjectName, DefaultBranch and TestCommand are exposed to source
+	// templates as {{.ProjectName}}, {{.DefaultBranch}} and {{.TestCommand}}.
+	ProjectName   string `json:"project_name,omitempty"`
+	DefaultBranch string `json:"default_branch,omitempty"`
+	TestCommand   string `json:"test_command,omitempty"`
+
+	// Vars are free-form template variables, read as {{.Vars.name}}.
+	Vars map[string]string `json:"vars,omitempty"`
+
+	// Packages lists the monorepo package directories to sync, as globs
+	// relative to the repo root (e.g. "packages/*"). Empty searches the
+	// whole repo for nested .promptherder/agent/ dirs.
+	Packages []string `json:"packages,omitempty"`
+
+	// RuleOrder lists rule names (stems, path.Match patterns allowed) in
+	// the order they appear in concatenated outputs such as
+	// copilot-instructions.md. It overrides the rules' own order keys;
+	// unlisted rules follow. hard-rules.md always comes first.
+	RuleOrder []string `json:"rule_order,omitempty"`
+
+	// SectionHeaders wraps each rule in copilot-instructions.md in comments
+	// naming its source file and herd, with a table of contents on top.
+	SectionHeaders bool `json:"section_headers,omitempty"`
+
+	// TokenBudgets caps the estimated tokens of each target's always-on
+	// outputs, by target name (e.g. "copilot": 6000). 0 turns a target's
+	// check off; targets not listed keep their built-in budget.
+	TokenBudgets map[string]int `json:"token_budgets,omitempty"`
+
+	// BudgetPolicy is what happens over budget: "warn" (default) or "fail".
+	BudgetPolicy string `json:"budget_policy,omitempty"`
+
+	// DriftPolicy is what a sync does with an output edited by hand since
+	// promptherder wrote it: "refuse" (default) keeps it and fails the run,
+	// "warn" overwrites it, "backup" copies it to .promptherder/backup/
+	// first. Stale outputs are kept, deleted or backed up the same way.
+	DriftPolicy string `json:"drift_policy,omitempty"`
 }
 
 // DefaultSettings returns the zero-value settings (all off).
@@ -35,12 +97,58 @@ func LoadSettings(repoPath string) (Settings, error) {
 		}
 		return Settings{}, fmt.Errorf("read settings: %w", err)
 	}
+	return parseSettings(path, data)
+}
+
+// LoadSettingsFile reads settings from an alternate file, for --config.
+// Unlike LoadSettings, a missing file is an error.
+func LoadSettingsFile(path string) (Settings, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Settings{}, fmt.Errorf("read settings: %w", err)
+	}
+	return parseSettings(path, data)
+}
 
+// loadSettings reads settingsPath if set, else the repo's settings.json.
+func loadSettings(repoPath, settingsPath string) (Settings, error) {
+	if settingsPath != "" {
+		return LoadSettingsFile(settingsPath)
+	}
+	return LoadSettings(repoPath)
+}
+
+// parseSettings decodes and validates settings read from path.
+func parseSettings(path string, data []byte) (Settings, error) {
 	var s Settings
 	if err := json.Unmarshal(data, &s); err != nil {
 		return Settings{}, fmt.Errorf("parse settings %s: %w", path, err)
 	}
 
+	for _, pattern := range s.RuleOrder {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return Settings{}, fmt.Errorf("parse settings %s: rule_order %q: %w", path, pattern, err)
+		}
+	}
+
+	for _, pattern := range s.Packages {
+		if !doublestar.ValidatePattern(pattern) {
+			return Settings{}, fmt.Errorf("parse settings %s: packages %q: invalid glob", path, pattern)
+		}
+	}
+
+	switch s.BudgetPolicy {
+	case "", budgetWarn, budgetFail:
+	default:
+		return Settings{}, fmt.Errorf("parse settings %s: budget_policy %q: want %q or %q", path, s.BudgetPolicy, budgetWarn, budgetFail)
+	}
+
+	switch s.DriftPolicy {
+	case "", driftRefuse, driftWarn, driftBackup:
+	default:
+		return Settings{}, fmt.Errorf("parse settings %s: drift_policy %q: want %q, %q or %q", path, s.DriftPolicy, driftRefuse, driftWarn, driftBackup)
+	}
+
 	// Validate: empty prefix + enabled = treat as disabled.
 	if s.CommandPrefixEnabled && s.CommandPrefix == "" {
 		s.CommandPrefixEnabled = false
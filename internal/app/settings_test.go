@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
@@ -134,3 +135,128 @@ func TestPrefixCommand_Disabled(t *testing.T) {
 		t.Errorf("expected %q, got %q", "plan.md", got)
 	}
 }
+
+func TestLoadSettings_Registries(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, filepath.Join(manifestDir, settingsFile),
+		`{"registries": ["https://herds.example.com/index.json", "registry.json"]}`)
+
+	s, err := LoadSettings(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(s.Registries) != 2 || s.Registries[1] != "registry.json" {
+		t.Errorf("registries = %v", s.Registries)
+	}
+}
+
+func TestLoadSettings_TemplateVars(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, filepath.Join(manifestDir, settingsFile),
+		`{"project_name": "acme", "default_branch": "trunk", "test_command": "make test", "vars": {"owner": "web"}}`)
+
+	s, err := LoadSettings(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.ProjectName != "acme" || s.DefaultBranch != "trunk" || s.TestCommand != "make test" || s.Vars["owner"] != "web" {
+		t.Errorf("template settings = %+v", s)
+	}
+}
+
+func TestLoadSettings_RuleOrderAndSections(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, filepath.Join(manifestDir, settingsFile), `{"rule_order": ["security", "compound-v*"], "section_headers": true}`)
+
+	s, err := LoadSettings(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(s.RuleOrder) != 2 || s.RuleOrder[1] != "compound-v*" || !s.SectionHeaders {
+		t.Errorf("rule_order = %v, section_headers = %v", s.RuleOrder, s.SectionHeaders)
+	}
+
+	bad := t.TempDir()
+	createTestFile(t, bad, filepath.Join(manifestDir, settingsFile), `{"rule_order": ["[oops"]}`)
+	if _, err := LoadSettings(bad); err == nil {
+		t.Error("expected error for a malformed rule_order pattern")
+	}
+}
+
+func TestLoadSettings_Packages(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, filepath.Join(manifestDir, settingsFile), `{"packages": ["packages/*", "services/**"]}`)
+
+	s, err := LoadSettings(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(s.Packages) != 2 || s.Packages[0] != "packages/*" {
+		t.Errorf("packages = %v", s.Packages)
+	}
+
+	bad := t.TempDir()
+	createTestFile(t, bad, filepath.Join(manifestDir, settingsFile), `{"packages": ["packages/[oops"]}`)
+	if _, err := LoadSettings(bad); err == nil {
+		t.Error("expected error for a malformed packages glob")
+	}
+}
+
+func TestLoadSettings_Budgets(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, filepath.Join(manifestDir, settingsFile), `{"token_budgets": {"copilot": 6000}, "budget_policy": "fail"}`)
+
+	s, err := LoadSettings(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.TokenBudgets["copilot"] != 6000 || s.BudgetPolicy != budgetFail {
+		t.Errorf("budgets = %v, policy = %q", s.TokenBudgets, s.BudgetPolicy)
+	}
+
+	bad := t.TempDir()
+	createTestFile(t, bad, filepath.Join(manifestDir, settingsFile), `{"budget_policy": "explode"}`)
+	if _, err := LoadSettings(bad); err == nil {
+		t.Error("expected error for an unknown budget_policy")
+	}
+}
+
+func TestLoadSettings_DriftPolicy(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, filepath.Join(manifestDir, settingsFile), `{"drift_policy": "backup"}`)
+	if s, err := LoadSettings(dir); err != nil || s.DriftPolicy != driftBackup {
+		t.Errorf("policy = %q, err = %v", s.DriftPolicy, err)
+	}
+
+	bad := t.TempDir()
+	createTestFile(t, bad, filepath.Join(manifestDir, settingsFile), `{"drift_policy": "ignore"}`)
+	if _, err := LoadSettings(bad); err == nil {
+		t.Error("expected error for an unknown drift_policy")
+	}
+}
+
+func TestLoadSettingsFile(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, filepath.Join(manifestDir, settingsFile), `{"command_prefix": "repo-", "command_prefix_enabled": true}`)
+	alt := createTestFile(t, dir, "ci/settings.json", `{"command_prefix": "ci-", "command_prefix_enabled": true}`)
+	createTestFile(t, dir, ".promptherder/agent/workflows/plan.md", "---\ndescription: Plan\n---\n# Plan\n")
+
+	if _, err := LoadSettingsFile(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("an explicit settings file that doesn't exist should be an error")
+	}
+
+	cfg := Config{RepoPath: dir, SettingsPath: alt, Logger: testLogger(t)}
+	if err := RunTarget(context.Background(), CopilotTarget{}, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !fileExists(filepath.Join(dir, ".github", "prompts", "ci-plan.prompt.md")) {
+		t.Error("the alternate settings file should replace settings.json")
+	}
+}
@@ -0,0 +1,199 @@
+package app
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// herdSigFile is the detached signature shipped at the root of a signed herd.
+const herdSigFile = "herd.sig"
+
+// HerdTreeHash returns a SHA-256 digest over the herd's files. Each file
+// contributes its slash-separated path and content hash, in sorted order,
+// so the digest is the same for a checkout, a packed archive and a GitHub
+// tarball. Hidden entries, symlinks and herd.sig itself are excluded,
+// matching what PackHerd ships. A file name with a line break is an error,
+// since it could pass for another entry in the hashed listing.
+func HerdTreeHash(herdPath string) ([]byte, error) {
+	var rels []string
+	err := filepath.WalkDir(herdPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == herdPath {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(herdPath, path)
+		if err != nil {
+			return fmt.Errorf("rel path: %w", err)
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == herdSigFile {
+			return nil
+		}
+		if strings.ContainsAny(rel, "\n\r") {
+			return fmt.Errorf("file name %q contains a line break: %w", rel, ErrValidation)
+		}
+		rels = append(rels, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("hash herd: %w", err)
+	}
+	sort.Strings(rels)
+
+	tree := sha256.New()
+	for _, rel := range rels {
+		data, err := os.ReadFile(filepath.Join(herdPath, filepath.FromSlash(rel)))
+		if err != nil {
+			return nil, fmt.Errorf("hash herd: %w", err)
+		}
+		sum := sha256.Sum256(data)
+		fmt.Fprintf(tree, "%s  %s\n", hex.EncodeToString(sum[:]), rel)
+	}
+	return tree.Sum(nil), nil
+}
+
+// GenerateSigningKey writes a new ed25519 key pair to <prefix>.key (private,
+// mode 0600) and <prefix>.pub. Both hold a single base64 line; the .pub line
+// is what consumers add to trusted_keys. Existing files are never overwritten.
+func GenerateSigningKey(prefix string) (privPath, pubPath string, err error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("generate key: %w", err)
+	}
+
+	privPath, pubPath = prefix+".key", prefix+".pub"
+	for _, p := range []string{privPath, pubPath} {
+		if _, err := os.Stat(p); err == nil {
+			return "", "", fmt.Errorf("%s already exists: %w", p, ErrValidation)
+		}
+	}
+
+	if err := os.WriteFile(privPath, []byte(base64.StdEncoding.EncodeToString(priv.Seed())+"\n"), 0o600); err != nil {
+		return "", "", fmt.Errorf("write %s: %w", privPath, err)
+	}
+	if err := os.WriteFile(pubPath, []byte(base64.StdEncoding.EncodeToString(pub)+"\n"), 0o644); err != nil {
+		return "", "", fmt.Errorf("write %s: %w", pubPath, err)
+	}
+	return privPath, pubPath, nil
+}
+
+// SignHerd signs the herd's tree hash with the private key in keyPath and
+// writes the signature to <herd>/herd.sig. Returns the signature path.
+func SignHerd(herdPath, keyPath string) (string, error) {
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return "", fmt.Errorf("read signing key: %w", err)
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return "", fmt.Errorf("signing key %s is not base64: %w", keyPath, ErrValidation)
+	}
+	var priv ed25519.PrivateKey
+	switch len(raw) {
+	case ed25519.SeedSize:
+		priv = ed25519.NewKeyFromSeed(raw)
+	case ed25519.PrivateKeySize:
+		priv = ed25519.PrivateKey(raw)
+	default:
+		return "", fmt.Errorf("signing key %s has %d bytes, want an ed25519 key: %w", keyPath, len(raw), ErrValidation)
+	}
+
+	if _, err := readHerdMeta(herdPath); err != nil {
+		return "", err
+	}
+	hash, err := HerdTreeHash(herdPath)
+	if err != nil {
+		return "", err
+	}
+
+	sigPath := filepath.Join(herdPath, herdSigFile)
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, hash))
+	if err := os.WriteFile(sigPath, []byte(sig+"\n"), 0o644); err != nil {
+		return "", fmt.Errorf("write %s: %w", sigPath, err)
+	}
+	return sigPath, nil
+}
+
+// parsePublicKeys decodes the base64 ed25519 keys listed in trusted_keys.
+func parsePublicKeys(encoded []string) ([]ed25519.PublicKey, error) {
+	keys := make([]ed25519.PublicKey, 0, len(encoded))
+	for _, s := range encoded {
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+		if err != nil || len(raw) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("trusted_keys entry %q is not a base64 ed25519 public key: %w", s, ErrValidation)
+		}
+		keys = append(keys, ed25519.PublicKey(raw))
+	}
+	return keys, nil
+}
+
+// verifyHerdSignature checks an extracted herd against the trusted_keys in
+// settings before it is installed.
+//
+// With require_signatures set, a herd must carry a herd.sig that verifies
+// against one of the trusted keys; anything else fails the pull. Without it,
+// a present signature is still verified when keys are configured (a bad
+// signature means the herd was tampered with), and unsigned herds are
+// accepted.
+func verifyHerdSignature(herdPath, name string, settings Settings, logger *slog.Logger) error {
+	keys, err := parsePublicKeys(settings.TrustedKeys)
+	if err != nil {
+		return err
+	}
+	if settings.RequireSignatures && len(keys) == 0 {
+		return fmt.Errorf("require_signatures is set but no trusted_keys are configured: %w", ErrValidation)
+	}
+
+	data, err := os.ReadFile(filepath.Join(herdPath, herdSigFile))
+	if os.IsNotExist(err) {
+		if settings.RequireSignatures {
+			return fmt.Errorf("herd %q is not signed (no %s) and require_signatures is set: %w", name, herdSigFile, ErrValidation)
+		}
+		logger.Debug("herd is not signed", "name", name)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read %s: %w", herdSigFile, err)
+	}
+	if len(keys) == 0 {
+		logger.Warn("herd is signed but no trusted_keys are configured — signature not checked", "name", name)
+		return nil
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return fmt.Errorf("herd %q has a malformed %s: %w", name, herdSigFile, ErrValidation)
+	}
+	hash, err := HerdTreeHash(herdPath)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if ed25519.Verify(key, hash, sig) {
+			logger.Info("signature verified", "name", name)
+			return nil
+		}
+	}
+	return fmt.Errorf("herd %q signature does not match any trusted key — the herd may have been modified: %w", name, ErrValidation)
+}
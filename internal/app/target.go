@@ -23,6 +23,22 @@ type TargetConfig struct {
 	DryRun   bool         // if true, log what would happen but don't write
 	Logger   *slog.Logger // structured logger
 	Settings Settings     // user settings from .promptherder/settings.json
+
+	stats    *outputStats    // records output sizes for budgets; nil outside RunAll/RunTarget/Stats
+	packages []string        // nested packages synced after the root; see discoverPackages
+	guard    *driftGuard     // checks outputs for hand edits before writing; nil outside RunAll/RunTarget
+	rec      *outputRecorder // collects dry-run outputs for Check; nil otherwise
+	user     map[string]bool // agent-dir files from the user layer → merged this run; see userSources
+	local    *[]string       // collects outputs built from the user layer, which are git-excluded
+}
+
+// PackageTarget is implemented by targets that sync the nested packages of
+// a monorepo (subdirectories with their own .promptherder/agent/) into
+// their repo-level outputs, scoped to each package's subtree.
+type PackageTarget interface {
+	// InstallPackage installs the package at pkg, a slash path relative to
+	// cfg.RepoPath. Returns repo-relative paths, like Install.
+	InstallPackage(ctx context.Context, cfg TargetConfig, pkg string) ([]string, error)
 }
 
 // SkillVariantFiles maps uppercase variant filenames to their target names.
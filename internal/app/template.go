@@ -0,0 +1,205 @@
+package app
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// templateData is what {{...}} actions in rules, workflows and skills see.
+type templateData struct {
+	Target        string            // target being rendered, e.g. "copilot"
+	ProjectName   string            // settings project_name, else the repo directory name
+	DefaultBranch string            // settings default_branch, else "main"
+	TestCommand   string            // settings test_command; empty if unset
+	Vars          map[string]string // settings vars; missing keys are an error
+	Herd          HerdMeta          // herd that shipped the file; zero for local sources
+}
+
+// sourceRenderer renders source files for one target: includes first, then
+// conditional blocks, then Go templates. Build one per Install with
+// newSourceRenderer; set outputs to enable rewriteRefs.
+type sourceRenderer struct {
+	repoPath string
+	root     string // include root: .promptherder/agent, or a nested package's
+	target   string
+	data     templateData
+	herds    []herdOnDisk
+	outputs  outputMap       // source → output paths for rewriteRefs
+	logger   *slog.Logger    // reports broken links
+	user     map[string]bool // user-layer sources; see TargetConfig.user
+}
+
+func newSourceRenderer(repoPath string, settings Settings, target string) (sourceRenderer, error) {
+	herds, err := discoverHerds(repoPath)
+	if err != nil {
+		return sourceRenderer{}, err
+	}
+	data := templateData{
+		Target:        target,
+		ProjectName:   settings.ProjectName,
+		DefaultBranch: settings.DefaultBranch,
+		TestCommand:   settings.TestCommand,
+		Vars:          settings.Vars,
+	}
+	if data.ProjectName == "" {
+		data.ProjectName = filepath.Base(repoPath)
+	}
+	if data.DefaultBranch == "" {
+		data.DefaultBranch = "main"
+	}
+	if data.Vars == nil {
+		data.Vars = map[string]string{}
+	}
+	return sourceRenderer{repoPath: repoPath, root: agentDir, target: target, data: data, herds: herds}, nil
+}
+
+// render expands includes and applies conditional blocks and templates to
+// one source file. label is its repo-relative slash path, used to resolve
+// includes and in errors; agentRel is its slash path under
+// .promptherder/agent (empty for hard-rules.md) and selects .Herd.
+// Files without "{{", or with `template: false` frontmatter, skip templating;
+// actions in other template languages are left as they are.
+func (r sourceRenderer) render(data []byte, label, agentRel string) ([]byte, error) {
+	data, err := expandIncludes(data, label, r.repoPath, cmp.Or(r.root, agentDir))
+	if err != nil {
+		return nil, err
+	}
+	data, err = renderSource(data, label, r.target)
+	if err != nil {
+		return nil, err
+	}
+	if !templated(data) {
+		return data, nil
+	}
+
+	tmpl, err := parseTemplate(data, label, r.target)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", strings.TrimPrefix(err.Error(), "template: "), ErrValidation)
+	}
+	td := r.data
+	td.Herd = r.herdFor(agentRel)
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, td); err != nil {
+		return nil, fmt.Errorf("%s: %w", strings.TrimPrefix(err.Error(), "template: "), ErrValidation)
+	}
+	return out.Bytes(), nil
+}
+
+// templated reports whether data should be rendered as a template.
+func templated(data []byte) bool {
+	if !bytes.Contains(data, []byte("{{")) {
+		return false
+	}
+	if meta, _, err := parseFrontmatter(data); err == nil {
+		if on, ok := meta.Bool("template"); ok && !on {
+			return false
+		}
+	}
+	return true
+}
+
+// parseTemplate parses a source for target, with the template functions
+// available to sources.
+func parseTemplate(data []byte, name, target string) (*template.Template, error) {
+	funcs := template.FuncMap{
+		"args": func(hint ...string) string { return argsPlaceholder(target, strings.Join(hint, " ")) },
+	}
+	return template.New(name).Option("missingkey=error").Funcs(funcs).Parse(quoteForeignActions(string(data), funcs))
+}
+
+// templateWords are the identifiers a Go template action can start with:
+// text/template's keywords and builtin functions.
+var templateWords = map[string]bool{
+	"if": true, "else": true, "end": true, "range": true, "with": true, "break": true, "continue": true,
+	"define": true, "template": true, "block": true, "nil": true, "true": true, "false": true,
+	"and": true, "or": true, "not": true, "call": true, "index": true, "slice": true, "len": true,
+	"print": true, "printf": true, "println": true, "html": true, "js": true, "urlquery": true,
+	"eq": true, "ne": true, "lt": true, "le": true, "gt": true, "ge": true,
+}
+
+// quoteForeignActions leaves {{...}} actions that aren't ours as literal
+// text, so sources can quote GitHub Actions expressions (${{ secrets.X }}),
+// Handlebars, Jinja or Vue without opting out of templates. An action is
+// ours if it starts with a field, variable, literal, parenthesis, comment,
+// template keyword or one of funcs.
+func quoteForeignActions(src string, funcs template.FuncMap) string {
+	var out strings.Builder
+	for {
+		i := strings.Index(src, "{{")
+		if i < 0 {
+			out.WriteString(src)
+			return out.String()
+		}
+		out.WriteString(src[:i])
+		src = src[i+2:]
+		if isTemplateAction(src, funcs) {
+			out.WriteString("{{")
+		} else {
+			out.WriteString(`{{"{{"}}`)
+		}
+	}
+}
+
+// isTemplateAction reports whether the action whose text follows "{{" is
+// Go template syntax.
+func isTemplateAction(action string, funcs template.FuncMap) bool {
+	if rest, ok := strings.CutPrefix(action, "-"); ok && rest != "" && strings.ContainsRune(" \t\r\n", rune(rest[0])) {
+		action = rest
+	}
+	action = strings.TrimLeft(action, " \t\r\n")
+	if action == "" || strings.HasPrefix(action, "/*") || strings.ContainsRune(".$(\"`'", rune(action[0])) {
+		return true
+	}
+	word := action[:len(action)-len(strings.TrimLeft(action, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_"))]
+	_, isFunc := funcs[word]
+	return templateWords[word] || isFunc
+}
+
+// argsPlaceholders is how each target spells the arguments given to a slash
+// command. Sources write {{args}}. Antigravity workflows have no argument
+// syntax, so it gets prose the agent can act on instead.
+var argsPlaceholders = map[string]string{
+	copilotName:     "${input:args}",
+	antigravityName: "the text the user typed after the command",
+}
+
+// argsPlaceholder returns target's argument placeholder. Copilot shows hint
+// in its input box, as ${input:args:hint}; other targets ignore it.
+func argsPlaceholder(target, hint string) string {
+	if target == copilotName && hint != "" {
+		return "${input:args:" + hint + "}"
+	}
+	if p, ok := argsPlaceholders[target]; ok {
+		return p
+	}
+	return "the command arguments"
+}
+
+// herdFor returns the metadata of the herd that provides agentRel.
+func (r sourceRenderer) herdFor(agentRel string) HerdMeta {
+	if agentRel == "" {
+		return HerdMeta{}
+	}
+	for _, h := range r.herds {
+		if _, err := os.Stat(filepath.Join(h.Path, filepath.FromSlash(agentRel))); err == nil {
+			return h.Meta
+		}
+	}
+	return HerdMeta{}
+}
+
+// agentRelPath returns a repo-relative source path relative to
+// .promptherder/agent, or "" if it lies elsewhere.
+func agentRelPath(label string) string {
+	rel, ok := strings.CutPrefix(label, agentDir+"/")
+	if !ok {
+		return ""
+	}
+	return rel
+}
@@ -0,0 +1,195 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSourceRenderer_Render(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, filepath.Join(herdsDir, "compound-v", "herd.json"), `{"name":"compound-v","version":"1.2.0"}`)
+	createTestFile(t, dir, filepath.Join(herdsDir, "compound-v", "rules", "tdd.md"), "# TDD\n")
+
+	settings := Settings{
+		ProjectName: "acme",
+		TestCommand: "make test",
+		Vars:        map[string]string{"owner": "web-team"},
+	}
+	r, err := newSourceRenderer(dir, settings, copilotName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		agentRel string
+		want     string
+	}{
+		{
+			name:  "settings",
+			input: "Run `{{.TestCommand}}` on {{.ProjectName}} before merging to {{.DefaultBranch}}.\n",
+			want:  "Run `make test` on acme before merging to main.\n",
+		},
+		{
+			name:  "target and vars",
+			input: "{{.Target}} / {{.Vars.owner}}\n",
+			want:  "copilot / web-team\n",
+		},
+		{
+			name:     "herd metadata",
+			input:    "From {{.Herd.Name}} {{.Herd.Version}}.\n",
+			agentRel: "rules/tdd.md",
+			want:     "From compound-v 1.2.0.\n",
+		},
+		{
+			name:  "local source has no herd",
+			input: "{{with .Herd.Name}}herd {{.}}{{else}}local{{end}}\n",
+			want:  "local\n",
+		},
+		{
+			name:  "fallback for unset setting",
+			input: "Lint with `{{or (index .Vars \"lint_command\") \"make lint\"}}`.\n",
+			want:  "Lint with `make lint`.\n",
+		},
+		{
+			name:  "opt out",
+			input: "---\ntemplate: false\n---\nUse ${{ secrets.TOKEN }}.\n",
+			want:  "---\ntemplate: false\n---\nUse ${{ secrets.TOKEN }}.\n",
+		},
+		{
+			name:  "other template languages left alone",
+			input: "token: ${{ secrets.TOKEN }}\n{{#if ok}}{{/if}} {{ user.name | upper }} {{ message }}\n",
+			want:  "token: ${{ secrets.TOKEN }}\n{{#if ok}}{{/if}} {{ user.name | upper }} {{ message }}\n",
+		},
+		{
+			name:  "mixed with our actions",
+			input: "{{- .ProjectName }}: ${{ github.ref }} {{ args }}\n",
+			want:  "acme: ${{ github.ref }} ${input:args}\n",
+		},
+		{
+			name:  "conditionals then templates",
+			input: "<!-- promptherder:if target=copilot -->\n{{.Target}}\n<!-- promptherder:endif -->\n",
+			want:  "copilot\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := r.render([]byte(tt.input), "rules/x.md", tt.agentRel)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSourceRenderer_Errors(t *testing.T) {
+	t.Parallel()
+	r, err := newSourceRenderer(t.TempDir(), Settings{}, antigravityName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"parse error", "# Plan\n\nRun {{.TestCommand\n", "workflows/plan.md:3"},
+		{"unknown field", "# Plan\n{{.TestComand}}\n", "workflows/plan.md:2"},
+		{"missing var", "# Plan\n\n\n{{.Vars.owner}}\n", "workflows/plan.md:4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := r.render([]byte(tt.input), "workflows/plan.md", "")
+			if !errors.Is(err, ErrValidation) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected ErrValidation at %s, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestTemplates_AcrossTargets(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, filepath.Join(manifestDir, settingsFile), `{"test_command": "go test ./..."}`)
+	createTestFile(t, dir, ".promptherder/agent/workflows/ship.md", "---\ndescription: Ship it\n---\nRun `{{.TestCommand}}` ({{.Target}}).\n")
+
+	settings, err := LoadSettings(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := TargetConfig{RepoPath: dir, Settings: settings, Logger: testLogger(t)}
+	if _, err := (CopilotTarget{}).Install(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (AntigravityTarget{}).Install(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	prompt, err := os.ReadFile(filepath.Join(dir, ".github", "prompts", "ship.prompt.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, prompt, "Run `go test ./...` (copilot).")
+
+	workflow, err := os.ReadFile(filepath.Join(dir, ".agent", "workflows", "ship.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, workflow, "Run `go test ./...` (antigravity).")
+}
+
+func TestArgsPlaceholder(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		target, hint, want string
+	}{
+		{copilotName, "", "${input:args}"},
+		{copilotName, "feature to plan", "${input:args:feature to plan}"},
+		{antigravityName, "feature to plan", "the text the user typed after the command"},
+		{"unknown", "", "the command arguments"},
+	}
+	for _, tt := range tests {
+		if got := argsPlaceholder(tt.target, tt.hint); got != tt.want {
+			t.Errorf("argsPlaceholder(%q, %q) = %q, want %q", tt.target, tt.hint, got, tt.want)
+		}
+	}
+}
+
+func TestArgs_AcrossTargets(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, ".promptherder/agent/workflows/plan.md", "---\ndescription: Plan a feature\n---\n# Plan\n\nPlan {{args \"feature\"}}.\n")
+	createTestFile(t, dir, ".promptherder/agent/skills/review/SKILL.md", "---\nname: review\n---\nReview {{args}}.\n")
+
+	cfg := TargetConfig{RepoPath: dir, Logger: testLogger(t)}
+	if _, err := (CopilotTarget{}).Install(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (AntigravityTarget{}).Install(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func(rel string) []byte {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(rel)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	assertContains(t, read(".github/prompts/plan.prompt.md"), "Plan ${input:args:feature}.")
+	assertContains(t, read(".github/prompts/review.prompt.md"), "Review ${input:args}.")
+	assertContains(t, read(".agent/workflows/plan.md"), "Plan the text the user typed after the command.")
+	assertNotContains(t, read(".agent/skills/review/SKILL.md"), "{{args}}")
+}
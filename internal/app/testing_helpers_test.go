@@ -9,6 +9,21 @@ import (
 	"testing"
 )
 
+// TestMain points the user config directory at an empty temp dir, so a
+// user layer on the machine running the tests can't leak into them.
+func TestMain(m *testing.M) {
+	home, err := os.MkdirTemp("", "promptherder-home-")
+	if err != nil {
+		panic(err)
+	}
+	for _, key := range []string{"HOME", "XDG_CONFIG_HOME", "AppData"} {
+		os.Setenv(key, home)
+	}
+	code := m.Run()
+	os.RemoveAll(home)
+	os.Exit(code)
+}
+
 // testLogger returns a quiet logger (discards output) for tests that don't
 // inspect log content. Tests that need to capture logs should create their
 // own logger with a strings.Builder.
@@ -0,0 +1,119 @@
+package app
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"os/user"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrUntrusted is returned when a pull targets a source that is not covered
+// by the trusted_sources allowlist and was not confirmed with --trust.
+var ErrUntrusted = fmt.Errorf("untrusted herd source: %w", ErrValidation)
+
+// herdSource records where an installed herd came from and who trusted it.
+// Stored per herd name in the manifest.
+type herdSource struct {
+	URL       string `json:"url"`
+	Source    string `json:"source"`               // normalized host/owner/repo
+	Ref       string `json:"ref,omitempty"`        // git ref pulled; empty means default branch
+	PulledAt  string `json:"pulled_at"`            // RFC 3339
+	TrustedBy string `json:"trusted_by,omitempty"` // user who passed --trust, or "trusted_sources"
+	TrustedAt string `json:"trusted_at,omitempty"` // RFC 3339
+	TrustRule string `json:"trust_rule,omitempty"` // matching trusted_sources entry
+}
+
+// sourceID returns the normalized "host/owner/repo" identity of a GitHub repo.
+func sourceID(owner, repo string) string {
+	return strings.ToLower("github.com/" + owner + "/" + repo)
+}
+
+// matchTrustedSource returns the first allowlist entry covering source.
+// Entries may name a host ("github.com"), an org ("github.com/acme") or an
+// exact repo ("github.com/acme/herd"); scheme, ".git" and case are ignored.
+func matchTrustedSource(source string, allowlist []string) (string, bool) {
+	srcParts := strings.Split(source, "/")
+	for _, entry := range allowlist {
+		norm := strings.ToLower(strings.TrimSpace(entry))
+		if i := strings.Index(norm, "://"); i >= 0 {
+			norm = norm[i+len("://"):]
+		}
+		norm = strings.TrimSuffix(strings.Trim(norm, "/"), ".git")
+		if norm == "" {
+			continue
+		}
+
+		parts := strings.Split(norm, "/")
+		if len(parts) > len(srcParts) {
+			continue
+		}
+		match := true
+		for i, p := range parts {
+			if p != srcParts[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return entry, true
+		}
+	}
+	return "", false
+}
+
+// checkTrust decides whether a herd may be pulled from source and returns
+// the trust record to store in the manifest.
+//
+// With no trusted_sources configured, every source is allowed (and recorded
+// without a trust decision). Otherwise the source must match the allowlist,
+// have been confirmed with --trust on an earlier pull from the same source,
+// or be confirmed now with confirm=true. Earlier confirmations are looked up
+// by source, not herd name: one pull of an index installs herds under the
+// names it lists.
+func checkTrust(source string, settings Settings, prev manifest, confirm bool) (herdSource, error) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	rec := herdSource{Source: source, PulledAt: now}
+
+	if len(settings.TrustedSources) == 0 {
+		return rec, nil
+	}
+
+	if rule, ok := matchTrustedSource(source, settings.TrustedSources); ok {
+		rec.TrustedBy = "trusted_sources"
+		rec.TrustedAt = now
+		rec.TrustRule = rule
+		return rec, nil
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(prev.HerdSources)) {
+		if old := prev.HerdSources[name]; old.Source == source && old.TrustedBy != "" && old.TrustRule == "" {
+			rec.TrustedBy = old.TrustedBy
+			rec.TrustedAt = old.TrustedAt
+			return rec, nil
+		}
+	}
+
+	if confirm {
+		rec.TrustedBy = currentUser()
+		rec.TrustedAt = now
+		return rec, nil
+	}
+
+	return herdSource{}, fmt.Errorf("%s is not in trusted_sources — review the herd, then re-run with --trust to confirm: %w", source, ErrUntrusted)
+}
+
+// currentUser returns the login name of the user running promptherder.
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	for _, env := range []string{"USER", "USERNAME"} {
+		if v := os.Getenv(env); v != "" {
+			return v
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,190 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSourceID(t *testing.T) {
+	t.Parallel()
+	if got := sourceID("Acme", "Herd"); got != "github.com/acme/herd" {
+		t.Errorf("sourceID = %q, want github.com/acme/herd", got)
+	}
+}
+
+func TestMatchTrustedSource(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name      string
+		source    string
+		allowlist []string
+		wantRule  string
+		wantOK    bool
+	}{
+		{"empty allowlist", "github.com/acme/herd", nil, "", false},
+		{"host", "github.com/acme/herd", []string{"github.com"}, "github.com", true},
+		{"org", "github.com/acme/herd", []string{"github.com/acme"}, "github.com/acme", true},
+		{"exact repo", "github.com/acme/herd", []string{"github.com/acme/herd"}, "github.com/acme/herd", true},
+		{"scheme and .git ignored", "github.com/acme/herd", []string{"https://github.com/acme/herd.git"}, "https://github.com/acme/herd.git", true},
+		{"case insensitive", "github.com/acme/herd", []string{"GitHub.com/ACME"}, "GitHub.com/ACME", true},
+		{"trailing slash", "github.com/acme/herd", []string{"github.com/acme/"}, "github.com/acme/", true},
+		{"org prefix is not a segment", "github.com/acme-evil/herd", []string{"github.com/acme"}, "", false},
+		{"other repo", "github.com/acme/other", []string{"github.com/acme/herd"}, "", false},
+		{"longer than source", "github.com/acme/herd", []string{"github.com/acme/herd/sub"}, "", false},
+		{"blank entry skipped", "github.com/acme/herd", []string{"  ", "github.com/acme"}, "github.com/acme", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			rule, ok := matchTrustedSource(tt.source, tt.allowlist)
+			if ok != tt.wantOK || rule != tt.wantRule {
+				t.Errorf("matchTrustedSource(%q, %v) = (%q, %v), want (%q, %v)", tt.source, tt.allowlist, rule, ok, tt.wantRule, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestCheckTrust(t *testing.T) {
+	t.Parallel()
+	const source = "github.com/someone/herd"
+	restricted := Settings{TrustedSources: []string{"github.com/acme"}}
+
+	confirmed := manifest{HerdSources: map[string]herdSource{
+		"herd": {Source: source, TrustedBy: "alice", TrustedAt: "2026-01-01T00:00:00Z"},
+	}}
+
+	t.Run("no allowlist allows everything", func(t *testing.T) {
+		t.Parallel()
+		rec, err := checkTrust(source, Settings{}, manifest{}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.Source != source || rec.TrustedBy != "" || rec.PulledAt == "" {
+			t.Errorf("unexpected record %+v", rec)
+		}
+	})
+
+	t.Run("allowlist match", func(t *testing.T) {
+		t.Parallel()
+		rec, err := checkTrust("github.com/acme/herd", restricted, manifest{}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.TrustedBy != "trusted_sources" || rec.TrustRule != "github.com/acme" {
+			t.Errorf("unexpected record %+v", rec)
+		}
+	})
+
+	t.Run("untrusted fails", func(t *testing.T) {
+		t.Parallel()
+		_, err := checkTrust(source, restricted, manifest{}, false)
+		if !errors.Is(err, ErrUntrusted) || !errors.Is(err, ErrValidation) {
+			t.Fatalf("expected ErrUntrusted wrapping ErrValidation, got %v", err)
+		}
+	})
+
+	t.Run("confirm records user", func(t *testing.T) {
+		t.Parallel()
+		rec, err := checkTrust(source, restricted, manifest{}, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.TrustedBy == "" || rec.TrustedAt == "" || rec.TrustRule != "" {
+			t.Errorf("unexpected record %+v", rec)
+		}
+	})
+
+	t.Run("earlier confirmation is reused", func(t *testing.T) {
+		t.Parallel()
+		rec, err := checkTrust(source, restricted, confirmed, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.TrustedBy != "alice" || rec.TrustedAt != "2026-01-01T00:00:00Z" {
+			t.Errorf("unexpected record %+v", rec)
+		}
+	})
+
+	t.Run("earlier confirmation under an index's herd names is reused", func(t *testing.T) {
+		t.Parallel()
+		index := manifest{HerdSources: map[string]herdSource{
+			"one": {Source: "github.com/someone/herds", TrustedBy: "bob", TrustedAt: "2026-02-03T04:05:06Z"},
+			"two": {Source: "github.com/someone/herds", TrustedBy: "bob", TrustedAt: "2026-02-03T04:05:06Z"},
+		}}
+		rec, err := checkTrust("github.com/someone/herds", restricted, index, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.TrustedBy != "bob" {
+			t.Errorf("unexpected record %+v", rec)
+		}
+	})
+
+	t.Run("earlier confirmation for another source is not reused", func(t *testing.T) {
+		t.Parallel()
+		_, err := checkTrust("github.com/attacker/herd", restricted, confirmed, false)
+		if !errors.Is(err, ErrUntrusted) {
+			t.Fatalf("expected ErrUntrusted, got %v", err)
+		}
+	})
+}
+
+func TestPull_UntrustedSourceRejected(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	createTestFile(t, dir, ".promptherder/settings.json", `{"trusted_sources": ["github.com/acme"]}`)
+
+	// Trust is checked before anything is downloaded, dry-run included.
+	err := Pull(context.Background(), "https://github.com/someone/herd", PullConfig{
+		RepoPath: dir,
+		DryRun:   true,
+		Logger:   testLogger(t),
+	})
+	if !errors.Is(err, ErrUntrusted) {
+		t.Fatalf("expected ErrUntrusted, got %v", err)
+	}
+
+	err = Pull(context.Background(), "https://github.com/someone/herd", PullConfig{
+		RepoPath: dir,
+		Trust:    true,
+		DryRun:   true,
+		Logger:   testLogger(t),
+	})
+	if err != nil {
+		t.Fatalf("-trust should allow the pull: %v", err)
+	}
+}
+
+func TestRecordHerdSources(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	if err := writeManifest(dir, manifest{
+		Version:   manifestVersion,
+		Targets:   map[string][]string{"copilot": {".github/copilot-instructions.md"}},
+		Generated: []string{"stack.md"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := herdSource{URL: "https://github.com/acme/herds", Source: "github.com/acme/herds", PulledAt: "2026-02-03T04:05:06Z", TrustedBy: "bob"}
+	if err := recordHerdSources(dir, []string{"one", "two"}, rec, testLogger(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	m := readManifest(dir, testLogger(t))
+	for _, name := range []string{"one", "two"} {
+		if got := m.HerdSources[name]; got != rec {
+			t.Errorf("HerdSources[%s] = %+v, want %+v", name, got, rec)
+		}
+	}
+	if len(m.Targets["copilot"]) != 1 || len(m.Generated) != 1 {
+		t.Errorf("targets and generated files should be untouched, got %+v", m)
+	}
+
+	// A later sync keeps the recorded sources.
+	if next := newManifestFrom(m); len(next.HerdSources) != 2 {
+		t.Errorf("newManifestFrom should keep herd sources, got %+v", next.HerdSources)
+	}
+}
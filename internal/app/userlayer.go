@@ -0,0 +1,246 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// userTarget is the manifest key for files merged from the user layer.
+const userTarget = "user"
+
+// Markers around a block of .git/info/exclude that promptherder manages.
+// Each is followed by ": " and the path of the worktree that owns the block.
+const (
+	excludeBegin = "# >>> promptherder user layer (managed; do not edit)"
+	excludeEnd   = "# <<< promptherder user layer"
+)
+
+// userAgentDir returns the user-level source layer:
+// $XDG_CONFIG_HOME/promptherder/agent on Linux, and the platform's user
+// config directory elsewhere (see os.UserConfigDir).
+func userAgentDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "promptherder", "agent"), nil
+}
+
+// resolveUserDir returns the user layer to merge for cfg, or "" when it is
+// turned off or doesn't exist.
+func resolveUserDir(cfg Config) string {
+	if cfg.NoUserLayer {
+		return ""
+	}
+	dir := cfg.UserDir
+	if dir == "" {
+		var err error
+		if dir, err = userAgentDir(); err != nil {
+			cfg.Logger.Debug("no user config directory", "error", err)
+			return ""
+		}
+	}
+	if !isDirectory(dir) {
+		return ""
+	}
+	return dir
+}
+
+// mergeUserLayer copies the content dirs of userDir into .promptherder/agent/.
+// The user layer sits under the repo: a file is only copied when neither the
+// repo nor a herd (herdFiles, from this run's merge) provides that path.
+// Files the previous run merged from the user layer or from herds no longer
+// installed don't count as the repo's own. Returns the repo-relative paths
+// written.
+func mergeUserLayer(ctx context.Context, repoPath, userDir string, herdFiles []string, prev manifest, cfg TargetConfig) ([]string, error) {
+	agentRoot := filepath.Join(repoPath, agentDir)
+	merged := make(map[string]bool)
+	for _, f := range prev.Targets["herds"] {
+		merged[f] = true
+	}
+	for _, f := range prev.Targets[userTarget] {
+		merged[f] = true
+	}
+
+	var installed []string
+	err := filepath.WalkDir(userDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(userDir, path)
+		if err != nil {
+			return fmt.Errorf("rel path: %w", err)
+		}
+		relSlash := filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if rel != "." && (strings.HasPrefix(d.Name(), ".") || !herdContentDirs[strings.SplitN(relSlash, "/", 2)[0]]) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// The same files as a herd: what the scan covers.
+		if !d.Type().IsRegular() || !herdMerges(relSlash) {
+			return nil
+		}
+
+		targetPath := filepath.Join(agentRoot, filepath.FromSlash(relSlash))
+		targetRel := agentDir + "/" + relSlash
+		if slices.Contains(herdFiles, targetRel) || (fileExists(targetPath) && !merged[targetRel]) {
+			cfg.Logger.Debug("repo file shadows user layer", "file", targetRel)
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", path, err)
+		}
+		if err := emitFile(cfg, targetRel, data, "source", relSlash, "layer", userTarget); err != nil {
+			return err
+		}
+		installed = append(installed, targetRel)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("user layer %s: %w", userDir, err)
+	}
+	return installed, nil
+}
+
+// userSources maps the agent-dir files of the user layer to whether this
+// run merged them (merged). A dry run leaves the previous run's user files
+// on disk, so those it didn't merge again are listed as false, for the
+// targets to skip.
+func userSources(prev manifest, merged []string, dryRun bool) map[string]bool {
+	user := make(map[string]bool)
+	if dryRun {
+		for _, f := range prev.Targets[userTarget] {
+			user[f] = false
+		}
+	}
+	for _, f := range merged {
+		user[f] = true
+	}
+	return user
+}
+
+// personal reports whether the source at label (repo-relative) was merged
+// from the user layer. Its outputs are local to the clone: git-excluded,
+// and kept out of files shared with the repo's own rules.
+func (r sourceRenderer) personal(label string) bool {
+	return r.user[label]
+}
+
+// leftover reports whether the source at label is a user-layer file this
+// run didn't merge; see userSources.
+func (r sourceRenderer) leftover(label string) bool {
+	merged, ok := r.user[label]
+	return ok && !merged
+}
+
+// syncGitExclude lists the user-layer files, and the outputs built from
+// them, in a managed block of the repo's .git/info/exclude, so they are
+// never committed by accident. The exclude file is local to the clone,
+// unlike .gitignore. Linked worktrees share it, so each worktree keeps its
+// own block, marked with its path; every block applies to every worktree.
+// Blocks of worktrees that no longer exist are dropped. Repos that aren't
+// git work trees are left alone.
+func syncGitExclude(repoPath string, files []string, dryRun bool, logger interface{ Debug(string, ...any) }) error {
+	infoDir, ok := gitInfoDir(repoPath)
+	if !ok {
+		logger.Debug("not a git work tree; user layer files are not excluded", "repo", repoPath)
+		return nil
+	}
+	path := filepath.Join(infoDir, "exclude")
+	old, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+
+	updated := replaceExcludeBlock(old, filepath.ToSlash(repoPath), files, func(worktree string) bool {
+		return isDirectory(filepath.FromSlash(worktree))
+	})
+	if bytes.Equal(updated, old) {
+		return nil
+	}
+	if dryRun {
+		logger.Debug("dry-run: would update git exclude", "file", path, "files", len(files))
+		return nil
+	}
+	return writeFile(path, updated)
+}
+
+// replaceExcludeBlock returns exclude with worktree's block replaced by one
+// listing files, or removed when files is empty. Other worktrees' blocks are
+// kept while live reports them present; an unmarked block from before blocks
+// were keyed by worktree is dropped.
+func replaceExcludeBlock(exclude []byte, worktree string, files []string, live func(worktree string) bool) []byte {
+	var out bytes.Buffer
+	inBlock, keep := false, false
+	sc := bufio.NewScanner(bytes.NewReader(exclude))
+	for sc.Scan() {
+		line := sc.Text()
+		if owner, ok := strings.CutPrefix(line, excludeBegin); ok && !inBlock {
+			owner = strings.TrimPrefix(owner, ": ")
+			inBlock, keep = true, owner != "" && owner != worktree && live(owner)
+		}
+		if !inBlock || keep {
+			out.WriteString(line + "\n")
+		}
+		if inBlock && strings.HasPrefix(line, excludeEnd) {
+			inBlock = false
+		}
+	}
+	if len(files) > 0 {
+		out.WriteString(excludeBegin + ": " + worktree + "\n")
+		for _, f := range slices.Sorted(slices.Values(files)) {
+			out.WriteString("/" + f + "\n")
+		}
+		out.WriteString(excludeEnd + ": " + worktree + "\n")
+	}
+	return out.Bytes()
+}
+
+// gitInfoDir returns the info directory of the git repo whose work tree is
+// repoPath. Linked worktrees (.git is a file) share the main repo's.
+func gitInfoDir(repoPath string) (string, bool) {
+	dotGit := filepath.Join(repoPath, ".git")
+	info, err := os.Stat(dotGit)
+	if err != nil {
+		return "", false
+	}
+	if info.IsDir() {
+		return filepath.Join(dotGit, "info"), true
+	}
+
+	data, err := os.ReadFile(dotGit)
+	if err != nil {
+		return "", false
+	}
+	gitDir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+	if !ok {
+		return "", false
+	}
+	gitDir = strings.TrimSpace(gitDir)
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(repoPath, gitDir)
+	}
+	if common, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir := strings.TrimSpace(string(common))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+		gitDir = commonDir
+	}
+	return filepath.Join(gitDir, "info"), true
+}
@@ -0,0 +1,251 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// userLayerRepo creates a git work tree with a local rule and a herd, and a
+// user layer that overlaps both.
+func userLayerRepo(t *testing.T) (repo, user string) {
+	t.Helper()
+	repo, user = t.TempDir(), t.TempDir()
+	createTestFile(t, repo, ".git/info/exclude", "# local excludes\n*.log\n")
+	createTestFile(t, repo, ".promptherder/agent/rules/shared.md", "# Shared (repo)\n")
+	createTestFile(t, repo, ".promptherder/herds/team/herd.json", `{"name":"team"}`)
+	createTestFile(t, repo, ".promptherder/herds/team/rules/team.md", "# Team (herd)\n")
+
+	createTestFile(t, user, "rules/personal.md", "# Personal\n")
+	createTestFile(t, user, "rules/shared.md", "# Shared (user)\n")
+	createTestFile(t, user, "rules/team.md", "# Team (user)\n")
+	createTestFile(t, user, "workflows/mine.md", "---\ndescription: Mine\n---\n# Mine\n")
+	createTestFile(t, user, "README.md", "# not content\n")
+	return repo, user
+}
+
+func TestRunAll_UserLayer(t *testing.T) {
+	t.Parallel()
+	repo, user := userLayerRepo(t)
+	cfg := Config{RepoPath: repo, UserDir: user, Logger: testLogger(t)}
+	targets := []Target{CopilotTarget{}, AntigravityTarget{}}
+	if err := RunAll(context.Background(), targets, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// The repo and herds win over the user layer.
+	assertContains(t, readOutput(t, repo, ".promptherder/agent/rules/shared.md"), "Shared (repo)")
+	assertContains(t, readOutput(t, repo, ".promptherder/agent/rules/team.md"), "Team (herd)")
+
+	// User rules get outputs of their own, never the shared ones.
+	assertNotContains(t, readOutput(t, repo, ".github/copilot-instructions.md"), "# Personal")
+	assertContains(t, readOutput(t, repo, ".github/instructions/personal.instructions.md"), "applyTo: \"**\"\n")
+	assertContains(t, readOutput(t, repo, ".agent/rules/personal.md"), "# Personal")
+	if fileExists(filepath.Join(repo, agentDir, "README.md")) {
+		t.Error("files outside the content dirs should not be merged")
+	}
+
+	m := readManifest(repo, testLogger(t))
+	want := []string{".promptherder/agent/rules/personal.md", ".promptherder/agent/workflows/mine.md"}
+	if !slices.Equal(m.Targets[userTarget], want) {
+		t.Errorf("manifest user files = %v, want %v", m.Targets[userTarget], want)
+	}
+	local := []string{".agent/rules/personal.md", ".agent/workflows/mine.md", ".github/instructions/personal.instructions.md", ".github/prompts/mine.prompt.md"}
+	if !slices.Equal(m.Local, local) {
+		t.Errorf("manifest local outputs = %v, want %v", m.Local, local)
+	}
+
+	exclude := string(readOutput(t, repo, ".git/info/exclude"))
+	for _, line := range slices.Concat([]string{"*.log", excludeBegin + ": " + filepath.ToSlash(repo)}, prefixAll("/", want), prefixAll("/", local)) {
+		if !strings.Contains(exclude, line+"\n") {
+			t.Errorf("exclude should contain %q, got:\n%s", line, exclude)
+		}
+	}
+
+	// A second run re-merges the same files without duplicating the block.
+	if err := RunAll(context.Background(), targets, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if again := string(readOutput(t, repo, ".git/info/exclude")); again != exclude {
+		t.Errorf("exclude changed on a repeat run:\n%s", again)
+	}
+
+	// Opting out removes the user files, their outputs and the exclude block.
+	cfg.NoUserLayer = true
+	if err := RunAll(context.Background(), targets, cfg); err != nil {
+		t.Fatal(err)
+	}
+	for _, rel := range slices.Concat(want, local) {
+		if fileExists(filepath.Join(repo, filepath.FromSlash(rel))) {
+			t.Errorf("%s should be removed when the user layer is off", rel)
+		}
+	}
+	assertContains(t, readOutput(t, repo, ".promptherder/agent/rules/shared.md"), "Shared (repo)")
+	if m := readManifest(repo, testLogger(t)); m.hasTarget(userTarget) {
+		t.Errorf("manifest should not track user files, got %v", m.Targets[userTarget])
+	}
+	if got := string(readOutput(t, repo, ".git/info/exclude")); got != "# local excludes\n*.log\n" {
+		t.Errorf("exclude should be restored, got:\n%s", got)
+	}
+}
+
+func TestRunAll_UserLayerDryRun(t *testing.T) {
+	t.Parallel()
+	repo, user := userLayerRepo(t)
+	cfg := Config{RepoPath: repo, UserDir: user, DryRun: true, Logger: testLogger(t)}
+	if err := RunAll(context.Background(), []Target{CopilotTarget{}}, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if fileExists(filepath.Join(repo, agentDir, "rules", "personal.md")) {
+		t.Error("dry run should not merge files")
+	}
+	if got := string(readOutput(t, repo, ".git/info/exclude")); got != "# local excludes\n*.log\n" {
+		t.Errorf("dry run should not touch exclude, got:\n%s", got)
+	}
+}
+
+// prefixAll returns each of items with prefix prepended.
+func prefixAll(prefix string, items []string) []string {
+	out := make([]string, len(items))
+	for i, s := range items {
+		out[i] = prefix + s
+	}
+	return out
+}
+
+func TestRunAll_UserLayerDryRunSkipsDroppedFiles(t *testing.T) {
+	t.Parallel()
+	repo, user := userLayerRepo(t)
+	targets := []Target{CopilotTarget{}, AntigravityTarget{}}
+	cfg := Config{RepoPath: repo, UserDir: user, Logger: testLogger(t)}
+	if err := RunAll(context.Background(), targets, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// The dropped layer's files stay on disk in a dry run, but the targets
+	// must render as if they were gone.
+	var out bytes.Buffer
+	cfg.NoUserLayer, cfg.DryRun, cfg.Diff = true, true, &out
+	if err := RunAll(context.Background(), targets, cfg); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"--- a/.agent/rules/personal.md\n+++ /dev/null\n",
+		"--- a/.github/instructions/personal.instructions.md\n+++ /dev/null\n",
+		"--- a/.github/prompts/mine.prompt.md\n+++ /dev/null\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("diff should contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestReplaceExcludeBlock(t *testing.T) {
+	t.Parallel()
+	blockFor := func(worktree string, files ...string) string {
+		return excludeBegin + ": " + worktree + "\n" + strings.Join(prefixAll("/", files), "\n") + "\n" + excludeEnd + ": " + worktree + "\n"
+	}
+	block := blockFor("/wt/a", "a.md")
+	other := blockFor("/wt/b", "b.md")
+	legacy := excludeBegin + "\n/old.md\n" + excludeEnd + "\n"
+	live := func(worktree string) bool { return worktree != "/wt/gone" }
+	tests := []struct {
+		name    string
+		exclude string
+		files   []string
+		want    string
+	}{
+		{"empty file, no files", "", nil, ""},
+		{"adds block", "*.log\n", []string{"a.md"}, "*.log\n" + block},
+		{"sorts files", "", []string{"b.md", "a.md"}, blockFor("/wt/a", "a.md", "b.md")},
+		{"replaces block", "*.log\n" + blockFor("/wt/a", "old.md") + ".env\n", []string{"a.md"}, "*.log\n.env\n" + block},
+		{"removes block", "*.log\n" + block, nil, "*.log\n"},
+		{"keeps other worktrees", other + block, nil, other},
+		{"drops gone worktrees", blockFor("/wt/gone", "x.md") + other, []string{"a.md"}, other + block},
+		{"drops unmarked block", "*.log\n" + legacy, []string{"a.md"}, "*.log\n" + block},
+		{"adds trailing newline", "*.log", nil, "*.log\n"},
+	}
+	for _, tt := range tests {
+		if got := string(replaceExcludeBlock([]byte(tt.exclude), "/wt/a", tt.files, live)); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGitInfoDir(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	if _, ok := gitInfoDir(filepath.Join(dir, "plain")); ok {
+		t.Error("a directory without .git is not a work tree")
+	}
+
+	main := filepath.Join(dir, "main")
+	mustMkdir(t, filepath.Join(main, ".git", "worktrees", "wt"))
+	if got, _ := gitInfoDir(main); got != filepath.Join(main, ".git", "info") {
+		t.Errorf("main work tree: got %s", got)
+	}
+
+	// Linked worktrees point at their own git dir, which names the shared one.
+	wt := filepath.Join(dir, "wt")
+	createTestFile(t, wt, ".git", "gitdir: "+filepath.Join(main, ".git", "worktrees", "wt")+"\n")
+	createTestFile(t, main, ".git/worktrees/wt/commondir", "../..\n")
+	if got, _ := gitInfoDir(wt); got != filepath.Join(main, ".git", "info") {
+		t.Errorf("linked worktree: got %s", got)
+	}
+}
+
+func TestSyncGitExclude_Worktrees(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	main, wt := filepath.Join(dir, "main"), filepath.Join(dir, "wt")
+	createTestFile(t, main, ".git/info/exclude", "*.log\n")
+	createTestFile(t, main, ".git/worktrees/wt/commondir", "../..\n")
+	createTestFile(t, wt, ".git", "gitdir: "+filepath.Join(main, ".git", "worktrees", "wt")+"\n")
+
+	if err := syncGitExclude(main, []string{"main.md"}, false, testLogger(t)); err != nil {
+		t.Fatal(err)
+	}
+	if err := syncGitExclude(wt, []string{"wt.md"}, false, testLogger(t)); err != nil {
+		t.Fatal(err)
+	}
+	exclude := string(readOutput(t, main, ".git/info/exclude"))
+	for _, line := range []string{"*.log", "/main.md", "/wt.md", excludeBegin + ": " + filepath.ToSlash(main), excludeBegin + ": " + filepath.ToSlash(wt)} {
+		if !strings.Contains(exclude, line+"\n") {
+			t.Errorf("exclude should contain %q, got:\n%s", line, exclude)
+		}
+	}
+
+	// Dropping one worktree's files leaves the other's block alone.
+	if err := syncGitExclude(wt, nil, false, testLogger(t)); err != nil {
+		t.Fatal(err)
+	}
+	exclude = string(readOutput(t, main, ".git/info/exclude"))
+	if !strings.Contains(exclude, "/main.md\n") || strings.Contains(exclude, "/wt.md") {
+		t.Errorf("only the worktree's own block should go, got:\n%s", exclude)
+	}
+}
+
+func TestResolveUserDir(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"explicit dir", Config{UserDir: dir}, dir},
+		{"opted out", Config{UserDir: dir, NoUserLayer: true}, ""},
+		{"missing dir", Config{UserDir: filepath.Join(dir, "nope")}, ""},
+		{"default dir absent", Config{}, ""}, // TestMain points it at an empty dir
+	}
+	for _, tt := range tests {
+		tt.cfg.Logger = testLogger(t)
+		if got := resolveUserDir(tt.cfg); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}